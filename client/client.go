@@ -0,0 +1,393 @@
+// Package client is a Go client for the webring's public API and the JSON
+// endpoints of the dashboard, for bots and tooling that work with a ring.
+//
+//	c := client.New("https://ring.example.com")
+//	next, err := c.Next(ctx, 12, "")
+//
+// Requests take a context and GET requests are retried on network errors,
+// 429 and 5xx responses.
+//
+// The package shares the server's internal models, so it can only be
+// imported from within the webring module, e.g. by commands under cmd/.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"webring/internal/audit"
+	"webring/internal/models"
+	"webring/internal/search"
+)
+
+// The response types are shared with the server's handlers.
+type (
+	Site         = models.PublicSite
+	AdminSite    = models.Site
+	SiteData     = models.SiteData
+	Neighbors    = models.Neighbors
+	RingData     = models.RingData
+	SearchResult = search.Result
+	Change       = audit.Entry
+)
+
+// Navigation is the result of Next, Prev and Random.
+type Navigation struct {
+	Site     *Site
+	Position int
+	Total    int
+}
+
+// Error is returned for responses other than 2xx.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("webring: %d %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from the ring.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+const (
+	defaultRetries = 3
+	defaultBackoff = 500 * time.Millisecond
+	maxRetryAfter  = time.Minute
+)
+
+// Client talks to one ring. The exported fields may be changed before the
+// first request.
+type Client struct {
+	// BaseURL is the public root of the ring, e.g. https://ring.example.com.
+	BaseURL string
+	// AdminURL is where the dashboard is served, when it is moved off the
+	// public domain with ADMIN_HOST or ADMIN_PORT. Defaults to BaseURL.
+	AdminURL string
+
+	// APIKey is sent as X-API-Key, for higher rate limits and /ring/data.
+	APIKey string
+	// Dashboard credentials, required for the admin methods.
+	User     string
+	Password string
+
+	HTTPClient *http.Client
+	// Retries is how many times a failed GET is repeated.
+	Retries int
+	// Backoff is the delay before the first retry; it doubles every time.
+	Backoff time.Duration
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Retries:    defaultRetries,
+		Backoff:    defaultBackoff,
+	}
+}
+
+// Sites returns the members in ring order.
+func (c *Client) Sites(ctx context.Context) ([]Site, error) {
+	var sites []Site
+	err := c.get(ctx, "/sites", nil, &sites)
+	return sites, err
+}
+
+// Data returns a member together with its neighbours. token is the
+// navigation token of the member, or empty if the ring doesn't require them;
+// the response carries a renewed one.
+func (c *Client) Data(ctx context.Context, id int, token string) (*SiteData, error) {
+	var data SiteData
+	if err := c.get(ctx, "/"+strconv.Itoa(id)+"/data", tokenQuery(token), &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// Next returns the member after id. token is as for Data.
+func (c *Client) Next(ctx context.Context, id int, token string) (*Navigation, error) {
+	return c.navigate(ctx, id, "next", token)
+}
+
+// Prev returns the member before id. token is as for Data.
+func (c *Client) Prev(ctx context.Context, id int, token string) (*Navigation, error) {
+	return c.navigate(ctx, id, "prev", token)
+}
+
+// Random returns a random member other than id. token is as for Data.
+func (c *Client) Random(ctx context.Context, id int, token string) (*Navigation, error) {
+	return c.navigate(ctx, id, "random", token)
+}
+
+// tokenQuery returns the query of a request with the navigation token, if
+// any.
+func tokenQuery(token string) url.Values {
+	if token == "" {
+		return nil
+	}
+	return url.Values{"token": {token}}
+}
+
+func (c *Client) navigate(ctx context.Context, id int, direction, token string) (*Navigation, error) {
+	var response struct {
+		Next     *Site `json:"next"`
+		Previous *Site `json:"previous"`
+		Random   *Site `json:"random"`
+		Position int   `json:"position"`
+		Total    int   `json:"total"`
+	}
+	if err := c.get(ctx, "/"+strconv.Itoa(id)+"/"+direction+"/", tokenQuery(token), &response); err != nil {
+		return nil, err
+	}
+
+	nav := &Navigation{Position: response.Position, Total: response.Total}
+	switch direction {
+	case "next":
+		nav.Site = response.Next
+	case "prev":
+		nav.Site = response.Previous
+	default:
+		nav.Site = response.Random
+	}
+	return nav, nil
+}
+
+// Neighbors returns up to depth members before and after id. token is as for
+// Data.
+func (c *Client) Neighbors(ctx context.Context, id, depth int, token string) (*Neighbors, error) {
+	var neighbors Neighbors
+	query := url.Values{"depth": {strconv.Itoa(depth)}}
+	if token != "" {
+		query.Set("token", token)
+	}
+	if err := c.get(ctx, "/"+strconv.Itoa(id)+"/neighbors", query, &neighbors); err != nil {
+		return nil, err
+	}
+	return &neighbors, nil
+}
+
+// Ring returns every member with the IDs of its neighbours.
+func (c *Client) Ring(ctx context.Context) (*RingData, error) {
+	var data RingData
+	if err := c.get(ctx, "/ring/data", nil, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// Search looks up members by name and URL.
+func (c *Client) Search(ctx context.Context, q string, page, perPage int) (*SearchResult, error) {
+	var result SearchResult
+	query := url.Values{"q": {q}, "page": {strconv.Itoa(page)}, "per_page": {strconv.Itoa(perPage)}}
+	if err := c.get(ctx, "/search", query, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Stats returns the public ring statistics. Which fields are present depends
+// on the ring's settings.
+func (c *Client) Stats(ctx context.Context) (map[string]interface{}, error) {
+	var stats map[string]interface{}
+	err := c.get(ctx, "/stats", nil, &stats)
+	return stats, err
+}
+
+// Changes returns the most recent changes to the ring, newest first.
+func (c *Client) Changes(ctx context.Context, limit int) ([]Change, error) {
+	var changes []Change
+	query := url.Values{"format": {"json"}, "limit": {strconv.Itoa(limit)}}
+	err := c.get(ctx, "/changes", query, &changes)
+	return changes, err
+}
+
+// Report files a visitor report against a member.
+func (c *Client) Report(ctx context.Context, id int, reason, contact string) error {
+	body := map[string]string{"reason": reason, "contact": contact}
+	return c.send(ctx, http.MethodPost, c.BaseURL+"/"+strconv.Itoa(id)+"/report", body, nil)
+}
+
+// SiteInput holds the fields of a member as edited on the dashboard. Empty
+// optional fields are cleared on update.
+type SiteInput struct {
+	ID           int    `json:"id,omitempty"`
+	Name         string `json:"name"`
+	URL          string `json:"url"`
+	Notes        string `json:"notes,omitempty"`
+	DisplayOrder *int   `json:"display_order,omitempty"`
+	SupportURL   string `json:"support_url,omitempty"`
+	WebhookURL   string `json:"webhook_url,omitempty"`
+	CheckType    string `json:"check_type,omitempty"`
+	CheckTarget  string `json:"check_target,omitempty"`
+	// Maintenance window in the dashboard's format, 2006-01-02T15:04 in the
+	// server's time zone
+	MaintenanceStart      string `json:"maintenance_start,omitempty"`
+	MaintenanceEnd        string `json:"maintenance_end,omitempty"`
+	MaintenanceKeepInRing bool   `json:"maintenance_keep_in_ring,omitempty"`
+	// MonitorURL receives every check result; MonitorKind is "webhook"
+	// (the default) or "ping"
+	MonitorURL  string `json:"monitor_url,omitempty"`
+	MonitorKind string `json:"monitor_kind,omitempty"`
+}
+
+// AddSite adds a member to the ring.
+func (c *Client) AddSite(ctx context.Context, site SiteInput) (*AdminSite, error) {
+	var stored AdminSite
+	if err := c.send(ctx, http.MethodPost, c.adminURL()+"/dashboard/add", site, &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// UpdateSite replaces the fields of member id.
+func (c *Client) UpdateSite(ctx context.Context, id int, site SiteInput) (*AdminSite, error) {
+	var stored AdminSite
+	if err := c.send(ctx, http.MethodPost, c.adminURL()+"/dashboard/update/"+strconv.Itoa(id), site, &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// RemoveSite removes member id from the ring.
+func (c *Client) RemoveSite(ctx context.Context, id int) error {
+	return c.send(ctx, http.MethodPost, c.adminURL()+"/dashboard/remove/"+strconv.Itoa(id), nil, nil)
+}
+
+func (c *Client) adminURL() string {
+	if c.AdminURL != "" {
+		return strings.TrimRight(c.AdminURL, "/")
+	}
+	return c.BaseURL
+}
+
+// get fetches a public endpoint, retrying on temporary failures.
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		wait, err := c.do(ctx, http.MethodGet, target, nil, out)
+		if err == nil || wait < 0 || attempt >= c.Retries {
+			return err
+		}
+		if wait == 0 {
+			wait = backoff
+			backoff *= 2
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// send makes a single request with a JSON body. Writes are not retried, as
+// they may have been applied before the connection failed.
+func (c *Client) send(ctx context.Context, method, target string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	_, err := c.do(ctx, method, target, payload, out)
+	return err
+}
+
+// do makes one request and decodes the response into out. On failure it
+// returns how long to wait before a retry: 0 for the default backoff and -1
+// if the request should not be retried.
+func (c *Client) do(ctx context.Context, method, target string, payload []byte, out interface{}) (time.Duration, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	if c.User != "" {
+		req.SetBasicAuth(c.User, c.Password)
+	}
+	// Makes the dashboard answer removals with 204 instead of a redirect
+	req.Header.Set("X-Requested-With", "fetch")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return -1, ctx.Err()
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return retryAfter(resp), responseError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return 0, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return -1, fmt.Errorf("webring: decoding %s: %w", target, err)
+	}
+	return 0, nil
+}
+
+// retryAfter tells do's caller whether and when a failed response may be
+// retried.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return -1
+	}
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	wait := time.Duration(seconds) * time.Second
+	if wait > maxRetryAfter {
+		// Not worth blocking the caller for
+		return -1
+	}
+	return wait
+}
+
+// responseError reads the message of an error response, which is either
+// {"error": "..."} or plain text.
+func responseError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	var body struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		message = body.Error
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: message}
+}
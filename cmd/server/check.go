@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"webring"
+
+	"webring/internal/database"
+	"webring/internal/templates"
+)
+
+type checkResult struct {
+	name string
+	err  error
+}
+
+// runSelfCheck validates the deployment (configuration, database, schema,
+// media folder and templates), prints a report and returns false if any check
+// failed.
+func runSelfCheck() bool {
+	var results []checkResult
+	add := func(name string, err error) {
+		results = append(results, checkResult{name, err})
+	}
+
+	add("configuration", checkConfig())
+
+	db, err := database.Connect()
+	if err == nil {
+		defer db.Close()
+		err = db.Ping()
+	}
+	add("database connectivity", err)
+	if err == nil {
+		add("schema version", checkSchemaVersion(db))
+	}
+
+	add("media folder writable", checkMediaFolder())
+
+	_, err = templates.Parse()
+	add("template parsing", err)
+
+	ok := true
+	for _, res := range results {
+		if res.err != nil {
+			ok = false
+			fmt.Printf("[FAIL] %s: %v\n", res.name, res.err)
+		} else {
+			fmt.Printf("[ OK ] %s\n", res.name)
+		}
+	}
+	return ok
+}
+
+func checkConfig() error {
+	var missing []string
+	for _, key := range []string{"DB_CONNECTION_STRING", "DASHBOARD_USER", "DASHBOARD_PASSWORD"} {
+		if os.Getenv(key) == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing environment variables: %s", strings.Join(missing, ", "))
+	}
+
+	if port := os.Getenv("PORT"); port != "" {
+		if _, err := strconv.Atoi(port); err != nil {
+			return fmt.Errorf("invalid PORT: %s", port)
+		}
+	}
+	return nil
+}
+
+// checkSchemaVersion compares the version recorded by golang-migrate with the
+// newest migration embedded in the binary.
+func checkSchemaVersion(db *sql.DB) error {
+	expected, err := latestMigrationVersion()
+	if err != nil {
+		return err
+	}
+
+	var version int
+	var dirty bool
+	err = db.QueryRow("SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err != nil {
+		return fmt.Errorf("reading schema_migrations: %v (did you run make migrate-up?)", err)
+	}
+
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty", version)
+	}
+	if version != expected {
+		return fmt.Errorf("schema version is %d, expected %d", version, expected)
+	}
+	return nil
+}
+
+func latestMigrationVersion() (int, error) {
+	entries, err := fs.ReadDir(webring.Files, "migrations")
+	if err != nil {
+		return 0, err
+	}
+
+	latest := 0
+	for _, entry := range entries {
+		prefix, _, found := strings.Cut(entry.Name(), "_")
+		if !found {
+			continue
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			continue
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+
+	if latest == 0 {
+		return 0, errors.New("no migrations embedded")
+	}
+	return latest, nil
+}
+
+func checkMediaFolder() error {
+	mediaFolder := os.Getenv("MEDIA_FOLDER")
+	if mediaFolder == "" {
+		mediaFolder = "media"
+	}
+
+	if err := os.MkdirAll(mediaFolder, os.ModePerm); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(mediaFolder, ".check-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Clean(name))
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+
+	"webring/internal/database"
+	"webring/internal/ring"
+)
+
+// listenForChanges reloads the ring cache whenever the sites table changes,
+// including changes made by other instances and by hand in the database.
+func listenForChanges(rc *ring.Cache) {
+	err := database.Listen("sites_changed", func(string) {
+		rc.Invalidate()
+	})
+	if err != nil {
+		log.Printf("Error listening for site changes, relying on the cache TTL: %v", err)
+	}
+}
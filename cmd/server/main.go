@@ -2,21 +2,37 @@ package main
 
 import (
 	"database/sql"
+	"flag"
 	"fmt"
-	"html/template"
 	"io"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
+	// Embedded so the timezone setting works in images without zoneinfo
+	_ "time/tzdata"
 	"webring"
 	"webring/internal/public"
 
-	"webring/internal/api"
+	"webring/internal/apikey"
+	"webring/internal/archive"
+	"webring/internal/blocklist"
 	"webring/internal/dashboard"
 	"webring/internal/database"
+	"webring/internal/duplicates"
+	"webring/internal/favicon"
+	"webring/internal/hiatus"
+	"webring/internal/jobs"
+	"webring/internal/notify"
+	"webring/internal/ratelimit"
+	"webring/internal/ring"
+	"webring/internal/settings"
+	"webring/internal/templates"
 	"webring/internal/uptime"
+	"webring/internal/visits"
+	"webring/internal/wayback"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
@@ -47,12 +63,67 @@ func setupLogging() (*os.File, error) {
 	return logFile, nil
 }
 
+// registerFileHandlers serves the embedded static assets and the media folder.
+func registerFileHandlers(r *mux.Router, mediaFolder string) error {
+	staticFiles, err := fs.Sub(webring.Files, "static")
+	if err != nil {
+		return err
+	}
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.FS(staticFiles))))
+	r.PathPrefix("/media/").Handler(http.StripPrefix("/media/", http.FileServer(http.Dir(mediaFolder))))
+	return nil
+}
+
+// runFaviconRebuild re-fetches all favicons from the command line, printing
+// progress while it runs.
+func runFaviconRebuild(db *sql.DB, st *settings.Store) {
+	rb := favicon.NewRebuilder(db, favicon.MediaFolder(), st)
+	if err := os.MkdirAll(favicon.MediaFolder(), os.ModePerm); err != nil {
+		log.Fatalf("Failed to create media folder: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				p := rb.Progress()
+				log.Printf("Rebuilding favicons: %d/%d done, %d failed", p.Done, p.Total, p.Failed)
+			}
+		}
+	}()
+
+	if err := rb.Run(); err != nil {
+		log.Fatalf("Favicon rebuild failed: %v", err)
+	}
+	close(done)
+
+	p := rb.Progress()
+	log.Printf("Favicon rebuild finished: %d/%d done, %d failed", p.Done, p.Total, p.Failed)
+}
+
 func main() {
+	check := flag.Bool("check", false, "validate configuration, database, media folder and templates, then exit")
+	rebuildFavicons := flag.Bool("rebuild-favicons", false, "re-fetch the favicons of all sites, then exit")
+	seed := flag.Int("seed", 0, "add this many fake sites with history to a development database, then exit")
+	flag.Parse()
+
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("Error loading .env file:", err)
 	}
 
+	if *check {
+		if !runSelfCheck() {
+			os.Exit(1)
+		}
+		return
+	}
+
 	logFile, err := setupLogging()
 	if err != nil {
 		log.Fatal("Failed to set up logging:", err)
@@ -77,22 +148,65 @@ func main() {
 		}
 	}(db)
 
-	checker := uptime.NewChecker(db)
-	go checker.Start()
+	reads, err := database.ConnectReplica(db)
+	if err != nil {
+		log.Fatalf("Failed to connect to the read replica: %v", err)
+	}
 
-	r := mux.NewRouter()
-	api.RegisterHandlers(r, db)
-	dashboard.RegisterHandlers(r, db)
+	bl := blocklist.New(db)
+	st := settings.New(db)
+	notifier := notify.New(db, st)
+	rc := ring.NewCache(db, st)
 
-	// Serve static files
-	staticFiles, err := fs.Sub(webring.Files, "static")
-	if err != nil {
-		log.Fatalf("Error accessing static files: %v", err)
+	if *rebuildFavicons {
+		runFaviconRebuild(db, st)
+		return
+	}
+	if *seed > 0 {
+		if err := runSeed(db, *seed); err != nil {
+			log.Fatalf("Seeding failed: %v", err)
+		}
+		return
 	}
-	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.FS(staticFiles))))
+
+	startOutbox(db, st, rc, notifier)
+	go listenForChanges(rc)
+	guard := ratelimit.NewAuthGuard(db, notifier)
+	gc := favicon.NewGarbageCollector(db, favicon.MediaFolder())
+
+	// Work touching the database runs once per cluster through the worker
+	worker := jobs.NewWorker(db)
+	guard.Register(worker, time.Hour)
+	gc.Register(worker, 24*time.Hour)
+	// An archived ring keeps its last statuses and its whole uptime history
+	if archive.Enabled() {
+		log.Println("The ring is archived, serving it read-only without checks")
+	} else {
+		checker := uptime.NewChecker(db, reads, rc, st)
+		checker.Register(worker)
+		uptime.NewRetention(db).Register(worker, time.Hour)
+		wayback.NewUpdater(db).Register(worker, time.Hour)
+		duplicates.Register(worker, db, time.Hour)
+		hiatus.Register(worker, db, func(siteID int) {
+			rc.Invalidate()
+			if err := checker.CheckSite(siteID); err != nil {
+				log.Printf("Error checking site %d after its hiatus: %v", siteID, err)
+			}
+		})
+	}
+	go worker.Start(5 * time.Second)
+
+	// Each instance keeps these in memory, so each one flushes or prunes its own
+	keys := apikey.New(db)
+	go keys.Start(time.Minute)
+	rl := ratelimit.NewLimiter(keys)
+	go rl.Start(time.Minute)
+	vc := visits.New(db, st)
+	go vc.Start(time.Minute)
+	go guard.Start(time.Hour)
 
 	// Parse templates
-	t, err := template.ParseFS(webring.Files, "internal/dashboard/templates/*.html", "internal/public/templates/*.html")
+	t, err := templates.Parse()
 	if err != nil {
 		log.Fatalf("Error parsing templates: %v", err)
 	}
@@ -103,20 +217,38 @@ func main() {
 	// Initialize public templates
 	public.InitTemplates(t)
 
-	mediaFolder := os.Getenv("MEDIA_FOLDER")
-	if mediaFolder == "" {
-		mediaFolder = "media"
-	}
+	mediaFolder := favicon.MediaFolder()
 	err = os.MkdirAll(mediaFolder, os.ModePerm)
 	if err != nil {
 		return
 	}
 
-	// Serve media files
-	r.PathPrefix("/media/").Handler(http.StripPrefix("/media/", http.FileServer(http.Dir(mediaFolder))))
+	r, adminRouter, err := newRouters(services{
+		db:       db,
+		reads:    reads,
+		bl:       bl,
+		st:       st,
+		rc:       rc,
+		notifier: notifier,
+		keys:     keys,
+		rl:       rl,
+		sprite:   favicon.NewSprite(mediaFolder, rc.Sites),
+		vc:       vc,
+		rb:       favicon.NewRebuilder(db, mediaFolder, st),
+		gc:       gc,
+		guard:    guard,
+	}, mediaFolder)
+	if err != nil {
+		log.Fatalf("Error accessing static files: %v", err)
+	}
 
-	// Register public handlers
-	public.RegisterHandlers(r, db)
+	adminPort := os.Getenv("ADMIN_PORT")
+	if adminPort != "" {
+		go func() {
+			log.Printf("Starting admin server on :%s", adminPort)
+			log.Fatal(http.ListenAndServe(":"+adminPort, adminRouter))
+		}()
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"webring/internal/favicon"
+	"webring/internal/monitor"
+	"webring/internal/notify"
+	"webring/internal/outbox"
+	"webring/internal/ring"
+	"webring/internal/settings"
+)
+
+// startOutbox registers the handlers for outbox events and starts
+// delivering them.
+func startOutbox(db *sql.DB, st *settings.Store, rc *ring.Cache, notifier *notify.Notifier) {
+	d := outbox.NewDispatcher(db)
+
+	d.Handle(outbox.EventNotify, func(payload json.RawMessage) error {
+		var event notify.Event
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return err
+		}
+		return notifier.Deliver(event)
+	})
+
+	d.Handle(outbox.EventNotifySend, func(payload json.RawMessage) error {
+		var send notify.Send
+		if err := json.Unmarshal(payload, &send); err != nil {
+			return err
+		}
+		return notifier.Send(send)
+	})
+
+	d.Handle(outbox.EventNotifySummary, func(payload json.RawMessage) error {
+		var summary notify.Summary
+		if err := json.Unmarshal(payload, &summary); err != nil {
+			return err
+		}
+		return notifier.DeliverSummary(summary)
+	})
+
+	d.Handle(outbox.EventMonitor, func(payload json.RawMessage) error {
+		var delivery monitor.Delivery
+		if err := json.Unmarshal(payload, &delivery); err != nil {
+			return err
+		}
+		return monitor.Send(delivery)
+	})
+
+	d.Handle(outbox.EventSiteChanged, func(payload json.RawMessage) error {
+		var change outbox.SiteChanged
+		if err := json.Unmarshal(payload, &change); err != nil {
+			return err
+		}
+		// Another instance may have made the change
+		rc.Invalidate()
+		if !change.RefreshFavicon || change.Removed {
+			return nil
+		}
+		err := favicon.Refresh(db, change.SiteID, change.URL, favicon.MediaFolder(), st.Get(settings.FaviconFallback))
+		if err != nil {
+			// Plenty of sites have no usable favicon; retrying won't help
+			log.Printf("Error retrieving favicon for %s: %v", change.URL, err)
+			return nil
+		}
+		rc.Invalidate()
+		return nil
+	})
+
+	go d.Start(time.Second)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+
+	"webring/internal/api"
+	"webring/internal/api/middleware"
+	"webring/internal/apikey"
+	"webring/internal/archive"
+	"webring/internal/blocklist"
+	"webring/internal/dashboard"
+	"webring/internal/favicon"
+	"webring/internal/navtoken"
+	"webring/internal/notify"
+	"webring/internal/public"
+	"webring/internal/ratelimit"
+	"webring/internal/ring"
+	"webring/internal/settings"
+	"webring/internal/stats"
+	"webring/internal/tour"
+	"webring/internal/visits"
+
+	"github.com/gorilla/mux"
+)
+
+// services are what the handlers are built from.
+type services struct {
+	db, reads *sql.DB
+	bl        *blocklist.Blocklist
+	st        *settings.Store
+	rc        *ring.Cache
+	notifier  *notify.Notifier
+	keys      *apikey.Store
+	rl        *ratelimit.Limiter
+	sprite    *favicon.Sprite
+	vc        *visits.Counter
+	rb        *favicon.Rebuilder
+	gc        *favicon.GarbageCollector
+	guard     *ratelimit.AuthGuard
+}
+
+// newRouters registers every handler and returns the public router and the
+// dashboard's, which is the same one unless ADMIN_PORT is set.
+func newRouters(s services, mediaFolder string) (*mux.Router, *mux.Router, error) {
+	r := mux.NewRouter()
+	r.Use(archive.Middleware)
+	// Keep the settings page writable so maintenance mode can be turned off again
+	readOnly := middleware.ReadOnlyMiddleware(s.st.ReadOnly, "/dashboard/settings")
+	r.Use(readOnly)
+	api.RegisterHandlers(r, s.db, s.reads, s.bl, s.st, s.rc, stats.NewCache(s.reads, s.st), s.rl, s.notifier, s.sprite, navtoken.New(), s.vc)
+
+	// The dashboard can be moved off the public domain, either to its own
+	// listener (ADMIN_PORT) or to a dedicated hostname (ADMIN_HOST).
+	adminRouter := r
+	if os.Getenv("ADMIN_PORT") != "" {
+		adminRouter = mux.NewRouter()
+		adminRouter.Use(archive.Middleware)
+		adminRouter.Use(readOnly)
+	} else if adminHost := os.Getenv("ADMIN_HOST"); adminHost != "" {
+		adminRouter = r.Host(adminHost).Subrouter()
+	}
+	dashboard.RegisterHandlers(adminRouter, s.db, s.bl, s.st, s.rc, s.rb, s.gc, s.guard, s.keys)
+
+	if err := registerFileHandlers(r, mediaFolder); err != nil {
+		return nil, nil, err
+	}
+	if os.Getenv("ADMIN_PORT") != "" {
+		if err := registerFileHandlers(adminRouter, mediaFolder); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	public.RegisterHandlers(r, s.db, s.reads, s.bl, s.st, s.rc, s.notifier, s.sprite, tour.New())
+	return r, adminRouter, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql"
+	"net/http/httptest"
+	"testing"
+
+	"webring/internal/apikey"
+	"webring/internal/blocklist"
+	"webring/internal/favicon"
+	"webring/internal/notify"
+	"webring/internal/ratelimit"
+	"webring/internal/ring"
+	"webring/internal/settings"
+	"webring/internal/visits"
+
+	"github.com/gorilla/mux"
+)
+
+func testRouters(t *testing.T) (*mux.Router, *mux.Router) {
+	t.Setenv("ADMIN_PORT", "")
+	t.Setenv("ADMIN_HOST", "")
+
+	// Nothing listens there: the routes are only matched, never served
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	st := settings.NewStatic(nil)
+	rc := ring.NewCache(db, st)
+	keys := apikey.New(db)
+	notifier := notify.New(db, st)
+	media := t.TempDir()
+	r, admin, err := newRouters(services{
+		db:       db,
+		reads:    db,
+		bl:       blocklist.New(db),
+		st:       st,
+		rc:       rc,
+		notifier: notifier,
+		keys:     keys,
+		rl:       ratelimit.NewLimiter(keys),
+		sprite:   favicon.NewSprite(media, rc.Sites),
+		vc:       visits.New(db, st),
+		rb:       favicon.NewRebuilder(db, media, st),
+		gc:       favicon.NewGarbageCollector(db, media),
+		guard:    ratelimit.NewAuthGuard(db, notifier),
+	}, media)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, admin
+}
+
+func TestRoutes(t *testing.T) {
+	r, _ := testRouters(t)
+	for _, tc := range []struct {
+		method, path, route string
+	}{
+		{"GET", "/tour", "/tour"},
+		{"GET", "/tour/next", "/tour/next"},
+		{"GET", "/tour/start", "/tour/start"},
+		{"GET", "/ring/data", "/ring/data"},
+		{"GET", "/12/next", "/{id:[0-9]+}/next"},
+		{"GET", "/12/next/", "/{id:[0-9]+}/next/"},
+		{"GET", "/12/data", "/{id:[0-9]+}/data"},
+		{"GET", "/v2/12/data", "/v2/{id:[0-9]+}/data"},
+		{"GET", "/12/home", "/{id:[0-9]+}/{relation}"},
+		{"GET", "/report/12", "/report/{id}"},
+		{"GET", "/dashboard", "/dashboard"},
+	} {
+		var match mux.RouteMatch
+		if !r.Match(httptest.NewRequest(tc.method, tc.path, nil), &match) || match.Route == nil {
+			t.Errorf("%s %s matches no route", tc.method, tc.path)
+			continue
+		}
+		if tmpl, _ := match.Route.GetPathTemplate(); tmpl != tc.route {
+			t.Errorf("%s %s matches %s, want %s", tc.method, tc.path, tmpl, tc.route)
+		}
+	}
+}
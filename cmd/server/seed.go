@@ -0,0 +1,85 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+var seedWords = []string{"Moss", "Lantern", "Orbit", "Pixel", "Harbor", "Quill", "Static", "Meadow", "Circuit", "Fern", "Comet", "Garden"}
+
+// runSeed fills a development database with n fake members, together with
+// uptime history, check errors, reports and audit log entries, so the
+// dashboard and API have something to show. The sites use the reserved .test
+// TLD and will be reported down by the checker.
+func runSeed(db *sql.DB, n int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var firstID int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(id), 0) + 1 FROM sites").Scan(&firstID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		id := firstID + i
+		name := fmt.Sprintf("%s %s %d", seedWords[rand.Intn(len(seedWords))], seedWords[rand.Intn(len(seedWords))], id)
+		url := fmt.Sprintf("https://site-%d.test", id)
+		createdAt := now.Add(-time.Duration(rand.Intn(365*24)) * time.Hour)
+		isUp := i%5 != 4
+
+		var downSince *time.Time
+		if !isUp {
+			t := now.Add(-time.Duration(rand.Intn(72)+1) * time.Hour)
+			downSince = &t
+		}
+
+		_, err := tx.Exec("INSERT INTO sites (id, name, url, is_up, last_check, down_since, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+			id, name, url, isUp, rand.Float64()*0.8+0.05, downSince, createdAt)
+		if err != nil {
+			return fmt.Errorf("inserting site %d: %v", id, err)
+		}
+
+		// A week of hourly history, mostly up
+		_, err = tx.Exec(`
+            INSERT INTO uptime_hourly (site_id, hour, checks, up_checks, avg_response_time)
+            SELECT $1::integer, h, 12, CASE WHEN random() < $2::float THEN 12 ELSE floor(random() * 12) END, random() * 0.8 + 0.05
+            FROM generate_series(date_trunc('hour', NOW()) - interval '7 days', date_trunc('hour', NOW()) - interval '1 hour', interval '1 hour') h
+        `, id, 0.97)
+		if err != nil {
+			return fmt.Errorf("inserting uptime history of site %d: %v", id, err)
+		}
+
+		if _, err := tx.Exec("INSERT INTO audit_log (site_id, site_name, action, new_value, created_at) VALUES ($1, $2, 'added', $3, $4)", id, name, url, createdAt); err != nil {
+			return fmt.Errorf("inserting audit log entry of site %d: %v", id, err)
+		}
+
+		if !isUp {
+			_, err := tx.Exec("INSERT INTO check_errors (site_id, category, message, created_at) VALUES ($1, 'dns', $2, $3)",
+				id, fmt.Sprintf("Error checking site: dial tcp: lookup site-%d.test: no such host", id), *downSince)
+			if err != nil {
+				return fmt.Errorf("inserting check error of site %d: %v", id, err)
+			}
+		}
+
+		if i%10 == 9 {
+			_, err := tx.Exec("INSERT INTO reports (site_id, reason, contact, ip) VALUES ($1, $2, $3, $4)",
+				id, "The site links to a page full of ads.", "visitor@example.com", "192.0.2.1")
+			if err != nil {
+				return fmt.Errorf("inserting report of site %d: %v", id, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Printf("Seeded %d sites (IDs %d-%d)", n, firstID, firstID+n-1)
+	return nil
+}
@@ -4,5 +4,5 @@ import (
 	"embed"
 )
 
-//go:embed static internal/dashboard/templates internal/public/templates
+//go:embed static internal/dashboard/templates internal/public/templates migrations
 var Files embed.FS
@@ -0,0 +1,177 @@
+// Package analytics summarizes admin work for the dashboard's Analytics
+// page: members joining per week, how long reports and guestbook entries
+// wait for an admin, and when admins change the ring.
+package analytics
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"webring/internal/audit"
+)
+
+// Weeks is how many weeks the weekly series cover, the current one included.
+const Weeks = 12
+
+// activityDays is how far back the activity heatmap looks.
+const activityDays = 90
+
+// Week is one bar of a weekly series.
+type Week struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+	// MedianHours is the median wait of the items handled, for the latency
+	// series; nil when none were.
+	MedianHours *float64 `json:"median_hours,omitempty"`
+	// Share is Count relative to the busiest week, in percent, for the bar
+	// width.
+	Share float64 `json:"-"`
+}
+
+// Median returns MedianHours, or 0 when it is not set.
+func (w Week) Median() float64 {
+	if w.MedianHours == nil {
+		return 0
+	}
+	return *w.MedianHours
+}
+
+// Cell is one hour of the activity heatmap.
+type Cell struct {
+	Count int `json:"count"`
+	// Level is 0 for no activity and 1 to 4 relative to the busiest hour.
+	Level int `json:"-"`
+}
+
+type Report struct {
+	// Joins counts members added per week.
+	Joins []Week `json:"joins"`
+	// Reports and GuestbookEntries count the items handled per week, by the
+	// week they came in, with the median time until an admin resolved or
+	// approved them.
+	Reports          []Week `json:"reports"`
+	GuestbookEntries []Week `json:"guestbook_entries"`
+	// Activity counts audit log entries by weekday (Monday first) and hour
+	// over the last 90 days.
+	Activity [7][24]Cell `json:"activity"`
+}
+
+// Build computes the report.
+func Build(db *sql.DB) (*Report, error) {
+	now := time.Now()
+	since := weekStart(now).AddDate(0, 0, -7*(Weeks-1))
+
+	var r Report
+	var err error
+	if r.Joins, err = weekly(db, since, `
+		SELECT date_trunc('week', created_at), COUNT(*), NULL::float8 FROM audit_log
+		WHERE action = $2 AND created_at >= $1 GROUP BY 1`, audit.ActionAdded); err != nil {
+		return nil, fmt.Errorf("joins: %w", err)
+	}
+	if r.Reports, err = weekly(db, since, latencyQuery("reports", "resolved_at")); err != nil {
+		return nil, fmt.Errorf("reports: %w", err)
+	}
+	if r.GuestbookEntries, err = weekly(db, since, latencyQuery("guestbook_entries", "approved_at")); err != nil {
+		return nil, fmt.Errorf("guestbook entries: %w", err)
+	}
+	if err := activity(db, now.AddDate(0, 0, -activityDays), &r.Activity); err != nil {
+		return nil, fmt.Errorf("activity: %w", err)
+	}
+	return &r, nil
+}
+
+// latencyQuery counts the rows of table handled so far per week they were
+// created, with the median hours until column was set.
+func latencyQuery(table, column string) string {
+	return fmt.Sprintf(`
+		SELECT date_trunc('week', created_at), COUNT(*),
+		       (percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM %[2]s - created_at)) / 3600)::float8
+		FROM %[1]s WHERE %[2]s IS NOT NULL AND created_at >= $1 GROUP BY 1`, table, column)
+}
+
+// weekStart returns the Monday starting the week of t, as date_trunc does.
+func weekStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// weekly runs a query returning (week, count, median) rows from since and
+// fills in the weeks without any.
+func weekly(db *sql.DB, since time.Time, query string, args ...interface{}) ([]Week, error) {
+	rows, err := db.Query(query, append([]interface{}{since}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	byWeek := make(map[string]Week)
+	for rows.Next() {
+		var w Week
+		if err := rows.Scan(&w.Start, &w.Count, &w.MedianHours); err != nil {
+			return nil, err
+		}
+		byWeek[w.Start.Format(time.DateOnly)] = w
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	weeks := make([]Week, Weeks)
+	busiest := 0
+	for i := range weeks {
+		start := since.AddDate(0, 0, 7*i)
+		weeks[i] = byWeek[start.Format(time.DateOnly)]
+		weeks[i].Start = start
+		busiest = max(busiest, weeks[i].Count)
+	}
+	for i := range weeks {
+		if busiest > 0 {
+			weeks[i].Share = float64(weeks[i].Count) * 100 / float64(busiest)
+		}
+	}
+	return weeks, nil
+}
+
+func activity(db *sql.DB, since time.Time, cells *[7][24]Cell) error {
+	rows, err := db.Query(`
+		SELECT EXTRACT(ISODOW FROM created_at)::int, EXTRACT(HOUR FROM created_at)::int, COUNT(*)
+		FROM audit_log WHERE created_at >= $1 GROUP BY 1, 2`, since)
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	busiest := 0
+	for rows.Next() {
+		var day, hour, count int
+		if err := rows.Scan(&day, &hour, &count); err != nil {
+			return err
+		}
+		cells[day-1][hour].Count = count
+		busiest = max(busiest, count)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for day := range cells {
+		for hour := range cells[day] {
+			if c := &cells[day][hour]; c.Count > 0 {
+				c.Level = 1 + (c.Count*4-1)/busiest
+			}
+		}
+	}
+	return nil
+}
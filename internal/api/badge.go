@@ -0,0 +1,56 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/badge"
+	"webring/internal/stats"
+	"webring/internal/templates"
+)
+
+func membersBadgeHandler(sc *stats.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := sc.Get()
+		if err != nil {
+			log.Printf("Error computing stats: %v", err)
+			http.Error(w, "Error computing stats", http.StatusInternalServerError)
+			return
+		}
+		writeBadge(w, "members", strconv.Itoa(s.Members), badge.ColorBlue)
+	}
+}
+
+func uptimeBadgeHandler(sc *stats.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := sc.Get()
+		if err != nil {
+			log.Printf("Error computing stats: %v", err)
+			http.Error(w, "Error computing stats", http.StatusInternalServerError)
+			return
+		}
+
+		if s.UptimeAverage == nil {
+			writeBadge(w, "uptime", "unknown", badge.ColorGrey)
+			return
+		}
+		writeBadge(w, "uptime", templates.Percent(*s.UptimeAverage), badge.UptimeColor(*s.UptimeAverage))
+	}
+}
+
+func writeBadge(w http.ResponseWriter, label, value, color string) {
+	svg, err := badge.Render(label, value, color)
+	if err != nil {
+		log.Printf("Error rendering badge: %v", err)
+		http.Error(w, "Error rendering badge", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	// Matches the stats cache; also keeps GitHub's image proxy from caching for days
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if _, err := w.Write(svg); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/ring"
+	"webring/internal/visits"
+
+	"github.com/gorilla/mux"
+)
+
+// beaconGIF is a transparent 1x1 GIF.
+var beaconGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// beaconETag never changes, so browsers keep the image and only revalidate.
+const beaconETag = `"webring-beacon-1"`
+
+// beaconHandler serves the impression beacon members can embed next to their
+// widget. Browsers may keep the image but must revalidate it on every view,
+// which is counted and answered with an empty 304.
+func beaconHandler(rc *ring.Cache, vc *visits.Counter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		sites, err := rc.Sites()
+		if err != nil {
+			log.Printf("Error fetching sites: %v", err)
+			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+			return
+		}
+		// Only members are counted, so made up IDs can't pile up counters
+		if ring.Index(sites, id) < 0 {
+			http.NotFound(w, r)
+			return
+		}
+		vc.RecordImpression(id)
+
+		w.Header().Set("Cache-Control", "no-cache, private")
+		w.Header().Set("ETag", beaconETag)
+		w.Header().Set("Cross-Origin-Resource-Policy", "cross-origin")
+		if r.Header.Get("If-None-Match") == beaconETag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/gif")
+		w.Header().Set("Content-Length", strconv.Itoa(len(beaconGIF)))
+		if _, err := w.Write(beaconGIF); err != nil {
+			log.Printf("Error writing response: %v", err)
+		}
+	}
+}
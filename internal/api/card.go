@@ -0,0 +1,85 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"webring/internal/badge"
+	"webring/internal/favicon"
+	"webring/internal/settings"
+	"webring/internal/stats"
+
+	"github.com/gorilla/mux"
+)
+
+// cardHandler renders a link preview card for a member, for use as og:image.
+func cardHandler(db *sql.DB, st *settings.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+
+		var card badge.Card
+		var faviconFile sql.NullString
+		err = db.QueryRow("SELECT name, url, favicon FROM sites WHERE id = $1", id).Scan(&card.Name, &card.URL, &faviconFile)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error fetching site: %v", err)
+			http.Error(w, "Error fetching site", http.StatusInternalServerError)
+			return
+		}
+
+		card.Ring = st.Get(settings.RingName)
+		if card.Ring == "" {
+			card.Ring = "the webring"
+		}
+		if faviconFile.Valid {
+			card.Favicon = faviconDataURI(faviconFile.String)
+		}
+		uptime, err := stats.SiteUptime(db, id)
+		if err != nil {
+			log.Printf("Error computing uptime of site %d: %v", id, err)
+		}
+		card.Uptime = uptime
+
+		svg, err := badge.RenderCard(card)
+		if err != nil {
+			log.Printf("Error rendering card: %v", err)
+			http.Error(w, "Error rendering card", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		if _, err := w.Write(svg); err != nil {
+			log.Printf("Error writing response: %v", err)
+		}
+	}
+}
+
+// faviconDataURI inlines a stored favicon, since unfurlers don't load
+// external resources referenced from an SVG.
+func faviconDataURI(name string) template.URL {
+	data, err := os.ReadFile(filepath.Join(favicon.MediaFolder(), filepath.Base(name)))
+	if err != nil {
+		log.Printf("Error reading favicon %s: %v", name, err)
+		return ""
+	}
+	contentType := http.DetectContentType(data)
+	if filepath.Ext(name) == ".svg" {
+		contentType = "image/svg+xml"
+	}
+	return template.URL("data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data))
+}
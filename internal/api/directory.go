@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"webring/internal/ring"
+)
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`*`, `\*`,
+	`_`, `\_`,
+	"`", "\\`",
+)
+
+// listSitesTextHandler renders the member list as plain text, one
+// "Name - URL" line per site.
+func listSitesTextHandler(rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sites, err := rc.Sites()
+		if err != nil {
+			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+			return
+		}
+
+		writeText(w, sitesText(sites))
+	}
+}
+
+// listSitesMarkdownHandler renders the member list as a Markdown list of
+// links, ready to be pasted into a README.
+func listSitesMarkdownHandler(rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sites, err := rc.Sites()
+		if err != nil {
+			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+			return
+		}
+
+		var b strings.Builder
+		for _, site := range sites {
+			// Parentheses and spaces would end the link target early
+			target := strings.NewReplacer("(", "%28", ")", "%29", " ", "%20").Replace(site.URL)
+			fmt.Fprintf(&b, "- [%s](%s)\n", markdownEscaper.Replace(site.Name), target)
+		}
+
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		if _, err := w.Write([]byte(b.String())); err != nil {
+			log.Printf("Error writing response: %v", err)
+		}
+	}
+}
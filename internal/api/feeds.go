@@ -0,0 +1,192 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webring/internal/api/middleware"
+	"webring/internal/settings"
+)
+
+// feedSite is a member as listed in the OPML and RSS feeds. Unlike /sites,
+// the feeds list every member, with the status of its last check.
+type feedSite struct {
+	ID          int
+	Name        string
+	URL         string
+	Description *string
+	FeedURL     *string
+	CreatedAt   *time.Time
+	Status      string
+}
+
+// feedSites loads every member in the order they joined the ring. Members
+// added before created_at was recorded come first, by ID.
+func feedSites(db *sql.DB) ([]feedSite, error) {
+	rows, err := db.Query(`
+		SELECT id, name, url, description, feed_url, created_at,
+		       CASE WHEN (hiatus_until > NOW()) IS TRUE THEN 'hiatus' WHEN is_up THEN 'up' ELSE 'down' END
+		FROM sites
+		ORDER BY created_at NULLS FIRST, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	var sites []feedSite
+	for rows.Next() {
+		var s feedSite
+		if err := rows.Scan(&s.ID, &s.Name, &s.URL, &s.Description, &s.FeedURL, &s.CreatedAt, &s.Status); err != nil {
+			return nil, err
+		}
+		sites = append(sites, s)
+	}
+	return sites, rows.Err()
+}
+
+func ringTitle(st *settings.Store) string {
+	if name := st.Get(settings.RingName); name != "" {
+		return name
+	}
+	return "Webring"
+}
+
+// opmlOutline is a member in the OPML export. Members with a registered feed
+// are rss outlines readers can subscribe to, the others links to the site.
+// slug and status are the ring's own attributes, which OPML allows.
+type opmlOutline struct {
+	Type    string `xml:"type,attr"`
+	Text    string `xml:"text,attr"`
+	Title   string `xml:"title,attr"`
+	XMLURL  string `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL string `xml:"htmlUrl,attr,omitempty"`
+	URL     string `xml:"url,attr,omitempty"`
+	Desc    string `xml:"description,attr,omitempty"`
+	Slug    string `xml:"slug,attr"`
+	Status  string `xml:"status,attr"`
+}
+
+type opmlDocument struct {
+	XMLName  xml.Name      `xml:"opml"`
+	Version  string        `xml:"version,attr"`
+	Title    string        `xml:"head>title"`
+	Docs     string        `xml:"head>docs"`
+	Outlines []opmlOutline `xml:"body>outline"`
+}
+
+// sitesOPMLHandler exports the members as an OPML subscription list.
+func sitesOPMLHandler(db *sql.DB, st *settings.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sites, err := feedSites(db)
+		if err != nil {
+			log.Printf("Error fetching sites: %v", err)
+			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+			return
+		}
+
+		doc := opmlDocument{
+			Version: "2.0",
+			Title:   ringTitle(st),
+			Docs:    "https://opml.org/spec2.opml",
+		}
+		for _, s := range sites {
+			o := opmlOutline{Type: "link", Text: s.Name, Title: s.Name, URL: s.URL, Slug: strconv.Itoa(s.ID), Status: s.Status}
+			if s.FeedURL != nil && *s.FeedURL != "" {
+				o.Type, o.XMLURL, o.HTMLURL, o.URL = "rss", *s.FeedURL, s.URL, ""
+			}
+			if s.Description != nil {
+				o.Desc = *s.Description
+			}
+			doc.Outlines = append(doc.Outlines, o)
+		}
+		writeFeed(w, r, "text/x-opml; charset=utf-8", doc)
+	}
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description,omitempty"`
+	Category    string  `xml:"category"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+}
+
+type rssDocument struct {
+	XMLName     xml.Name  `xml:"rss"`
+	Version     string    `xml:"version,attr"`
+	Title       string    `xml:"channel>title"`
+	Link        string    `xml:"channel>link"`
+	Description string    `xml:"channel>description"`
+	Items       []rssItem `xml:"channel>item"`
+}
+
+// sitesRSSHandler publishes the members as an RSS feed, newest first, so
+// subscribers learn about members joining. The category of an item is the
+// member's status: up, down or hiatus.
+func sitesRSSHandler(db *sql.DB, st *settings.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sites, err := feedSites(db)
+		if err != nil {
+			log.Printf("Error fetching sites: %v", err)
+			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+			return
+		}
+
+		base := middleware.BaseURL(r)
+		title := ringTitle(st)
+		doc := rssDocument{
+			Version:     "2.0",
+			Title:       title,
+			Link:        base + "/",
+			Description: "Members of " + title,
+		}
+		for i := len(sites) - 1; i >= 0; i-- {
+			s := sites[i]
+			item := rssItem{
+				Title:    s.Name,
+				Link:     s.URL,
+				Category: s.Status,
+				GUID:     rssGUID{Value: fmt.Sprintf("%s/%d", base, s.ID)},
+			}
+			if s.Description != nil {
+				item.Description = *s.Description
+			}
+			if s.CreatedAt != nil {
+				item.PubDate = s.CreatedAt.UTC().Format(time.RFC1123Z)
+			}
+			doc.Items = append(doc.Items, item)
+		}
+		writeFeed(w, r, "application/rss+xml; charset=utf-8", doc)
+	}
+}
+
+// writeFeed writes v as an XML document of the given content type, with an
+// ETag so readers polling the feed get a 304 while nothing changed.
+func writeFeed(w http.ResponseWriter, r *http.Request, contentType string, v interface{}) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Printf("Error encoding feed: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeWithETag(w, r, []byte(xml.Header+string(body)+"\n"))
+}
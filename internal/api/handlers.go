@@ -7,30 +7,79 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"webring/internal/api/middleware"
+	"webring/internal/blocklist"
+	"webring/internal/favicon"
 	"webring/internal/models"
+	"webring/internal/navtoken"
+	"webring/internal/notify"
+	"webring/internal/ratelimit"
+	"webring/internal/ring"
+	"webring/internal/settings"
+	"webring/internal/stats"
+	"webring/internal/visits"
 
 	"github.com/gorilla/mux"
 )
 
-func RegisterHandlers(r *mux.Router, db *sql.DB) {
+// RegisterHandlers adds the API. Endpoints that only read use reads, which
+// may be a replica; reports are written to db.
+func RegisterHandlers(r *mux.Router, db, reads *sql.DB, bl *blocklist.Blocklist, st *settings.Store, rc *ring.Cache, sc *stats.Cache, rl *ratelimit.Limiter, notifier *notify.Notifier, sprite *favicon.Sprite, signer *navtoken.Signer, vc *visits.Counter) {
 	apiRouter := r.PathPrefix("").Subrouter()
 	apiRouter.Use(middleware.CORSMiddleware)
+	apiRouter.Use(bl.Middleware)
+	apiRouter.Use(rl.Middleware)
 
-	apiRouter.HandleFunc("/{id}/prev/", previousSiteHandler(db)).Methods("GET")
-	apiRouter.HandleFunc("/{id}/next/", nextSiteHandler(db)).Methods("GET")
-	apiRouter.HandleFunc("/{id}/prev", previousSiteRedirectHandler(db)).Methods("GET")
-	apiRouter.HandleFunc("/{id}/next", nextSiteRedirectHandler(db)).Methods("GET")
-	apiRouter.HandleFunc("/{id}/data", siteDataHandler(db)).Methods("GET")
-	apiRouter.HandleFunc("/{id}/random/", randomSiteHandler(db)).Methods("GET")
-	apiRouter.HandleFunc("/{id}/random", randomSiteRedirectHandler(db)).Methods("GET")
-	apiRouter.HandleFunc("/sites", listPublicSitesHandler(db)).Methods("GET")
+	requireKey := ratelimit.RequireKey(func() bool { return st.Bool(settings.RingDataRequiresKey) })
+	apiRouter.Handle("/ring/data", requireKey(ringDataHandler(rc))).Methods("GET")
+
+	navGuard := requireNavToken(st, signer)
+	apiRouter.Handle("/{id:[0-9]+}/prev/", navGuard(previousSiteHandler(rc))).Methods("GET")
+	apiRouter.Handle("/{id:[0-9]+}/next/", navGuard(nextSiteHandler(rc))).Methods("GET")
+	apiRouter.Handle("/{id:[0-9]+}/prev", navGuard(previousSiteRedirectHandler(rc, vc))).Methods("GET")
+	apiRouter.Handle("/{id:[0-9]+}/next", navGuard(nextSiteRedirectHandler(rc, vc))).Methods("GET")
+	apiRouter.Handle("/{id:[0-9]+}/data", navGuard(siteDataHandler(rc, st, signer))).Methods("GET")
+	apiRouter.Handle("/{id:[0-9]+}/neighbors", navGuard(neighborsHandler(rc))).Methods("GET")
+	apiRouter.Handle("/{id:[0-9]+}/random/", navGuard(randomSiteHandler(rc))).Methods("GET")
+	apiRouter.Handle("/{id:[0-9]+}/random", navGuard(randomSiteRedirectHandler(rc, vc))).Methods("GET")
+	apiRouter.HandleFunc("/{id:[0-9]+}/snippet", snippetHandler(reads)).Methods("GET")
+	apiRouter.HandleFunc("/{id:[0-9]+}/card", cardHandler(reads, st)).Methods("GET")
+	apiRouter.HandleFunc("/{id:[0-9]+}/qr.png", qrHandler(reads)).Methods("GET")
+	registerV2(apiRouter, st, rc, navGuard, signer)
+	apiRouter.HandleFunc("/{id:[0-9]+}/report", reportHandler(db, notifier)).Methods("POST")
+	apiRouter.HandleFunc("/{id:[0-9]+}/hiatus", hiatusHandler(db, rc)).Methods("POST")
+	apiRouter.HandleFunc("/{id:[0-9]+}/profile", profileHandler(db)).Methods("POST")
+	// The member lists get around the navigation tokens, so they are rate
+	// limited more tightly while tokens are required
+	listLimit := rl.ListLimit(func() bool { return st.Bool(settings.NavigationTokens) })
+	apiRouter.Handle("/sites", listLimit(listPublicSitesHandler(rc))).Methods("GET")
+	apiRouter.Handle("/sites.txt", listLimit(listSitesTextHandler(rc))).Methods("GET")
+	apiRouter.Handle("/sites.md", listLimit(listSitesMarkdownHandler(rc))).Methods("GET")
+	apiRouter.Handle("/sites.opml", listLimit(sitesOPMLHandler(reads, st))).Methods("GET")
+	apiRouter.Handle("/sites.rss", listLimit(sitesRSSHandler(reads, st))).Methods("GET")
+	apiRouter.HandleFunc("/qr.png", ringQRHandler()).Methods("GET")
+	apiRouter.HandleFunc("/widget.js", widgetHandler(reads, st, signer)).Methods("GET")
+	apiRouter.HandleFunc("/b/{id:[0-9]+}.gif", beaconHandler(rc, vc)).Methods("GET")
+	apiRouter.HandleFunc("/favicons/sprite.png", spriteImageHandler(sprite)).Methods("GET")
+	apiRouter.HandleFunc("/favicons/sprite.json", spriteOffsetsHandler(sprite)).Methods("GET")
+	apiRouter.Handle("/search", listLimit(searchHandler(reads))).Methods("GET")
+	apiRouter.HandleFunc("/stats", statsHandler(sc)).Methods("GET")
+	apiRouter.HandleFunc("/badge/members.svg", membersBadgeHandler(sc)).Methods("GET")
+	apiRouter.HandleFunc("/badge/uptime.svg", uptimeBadgeHandler(sc)).Methods("GET")
+
+	// Registered last so the built-in endpoints always win
+	apiRouter.HandleFunc("/{id:[0-9]+}/{relation}", customRelationHandler(reads, st)).Methods("GET")
 }
 
-func previousSiteHandler(db *sql.DB) http.HandlerFunc {
+func previousSiteHandler(rc *ring.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		rc, ok := orderedRing(w, r, rc)
+		if !ok {
+			return
+		}
 		id := mux.Vars(r)["id"]
-		site, err := getPreviousSite(db, id)
+		site, position, total, err := navigate(r, rc, id, rc.PrevIndex)
 		if err != nil {
 			http.Error(w, "Site not found", http.StatusNotFound)
 			return
@@ -38,8 +87,12 @@ func previousSiteHandler(db *sql.DB) http.HandlerFunc {
 
 		response := struct {
 			Previous *models.PublicSite `json:"previous"`
+			Position int                `json:"position"`
+			Total    int                `json:"total"`
 		}{
 			Previous: site,
+			Position: position,
+			Total:    total,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -51,19 +104,27 @@ func previousSiteHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func nextSiteHandler(db *sql.DB) http.HandlerFunc {
+func nextSiteHandler(rc *ring.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		rc, ok := orderedRing(w, r, rc)
+		if !ok {
+			return
+		}
 		id := mux.Vars(r)["id"]
-		site, err := getNextSite(db, id)
+		site, position, total, err := navigate(r, rc, id, rc.NextIndex)
 		if err != nil {
 			http.Error(w, "Site not found", http.StatusNotFound)
 			return
 		}
 
 		response := struct {
-			Next *models.PublicSite `json:"next"`
+			Next     *models.PublicSite `json:"next"`
+			Position int                `json:"position"`
+			Total    int                `json:"total"`
 		}{
-			Next: site,
+			Next:     site,
+			Position: position,
+			Total:    total,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -75,12 +136,16 @@ func nextSiteHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func randomSiteHandler(db *sql.DB) http.HandlerFunc {
+func randomSiteHandler(rc *ring.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		rc, ok := orderedRing(w, r, rc)
+		if !ok {
+			return
+		}
 		currentID := mux.Vars(r)["id"]
-		site, err := getRandomSite(db, currentID)
+		site, position, total, err := navigate(r, rc, currentID, rc.RandomIndex)
 		if err != nil {
-			if err.Error() == "no available sites found" {
+			if errors.Is(err, ring.ErrNotFound) {
 				http.Error(w, "No available sites found", http.StatusNotFound)
 			} else {
 				log.Printf("Error fetching random site: %v", err)
@@ -90,9 +155,13 @@ func randomSiteHandler(db *sql.DB) http.HandlerFunc {
 		}
 
 		response := struct {
-			Random *models.PublicSite `json:"random"`
+			Random   *models.PublicSite `json:"random"`
+			Position int                `json:"position"`
+			Total    int                `json:"total"`
 		}{
-			Random: site,
+			Random:   site,
+			Position: position,
+			Total:    total,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -103,13 +172,49 @@ func randomSiteHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func siteDataHandler(db *sql.DB) http.HandlerFunc {
+func siteDataHandler(rc *ring.Cache, st *settings.Store, signer *navtoken.Signer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		rc, ok := orderedRing(w, r, rc)
+		if !ok {
+			return
+		}
 		id := mux.Vars(r)["id"]
 
-		data, err := getSiteData(db, id)
+		data, err := getSiteData(r, rc, id)
 		if err != nil {
-			http.Error(w, "Error fetching site data", http.StatusInternalServerError)
+			if errors.Is(err, ring.ErrNotFound) {
+				http.Error(w, "Site not found", http.StatusNotFound)
+			} else {
+				log.Printf("Error fetching site data: %v", err)
+				http.Error(w, "Error fetching site data", http.StatusInternalServerError)
+			}
+			return
+		}
+		if st.Bool(settings.NavigationTokens) {
+			data.Token = signer.Issue(data.Curr.ID)
+		}
+
+		if wantsScript(r) {
+			writeScript(w, r, data)
+			return
+		}
+		format, ok := responseFormat(w, r)
+		if !ok {
+			return
+		}
+		switch format {
+		case formatXML:
+			writeXML(w, xmlSiteData{
+				Position: data.Position,
+				Total:    data.Total,
+				Prev:     toXMLSite(data.Prev),
+				Curr:     toXMLSite(data.Curr),
+				Next:     toXMLSite(data.Next),
+				Token:    data.Token,
+			})
+			return
+		case formatText:
+			writeText(w, siteDataText(data))
 			return
 		}
 
@@ -122,36 +227,103 @@ func siteDataHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func previousSiteRedirectHandler(db *sql.DB) http.HandlerFunc {
+const (
+	defaultNeighborDepth = 2
+	maxNeighborDepth     = 10
+)
+
+// neighborsHandler returns the next and previous sites up to ?depth= steps
+// away, so widgets can navigate without a request per step.
+func neighborsHandler(rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc, ok := orderedRing(w, r, rc)
+		if !ok {
+			return
+		}
+		depth := defaultNeighborDepth
+		if value := r.URL.Query().Get("depth"); value != "" {
+			d, err := strconv.Atoi(value)
+			if err != nil || d < 1 || d > maxNeighborDepth {
+				http.Error(w, fmt.Sprintf("depth must be between 1 and %d", maxNeighborDepth), http.StatusBadRequest)
+				return
+			}
+			depth = d
+		}
+
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		sites, err := rc.Sites()
+		if err != nil {
+			log.Printf("Error fetching sites: %v", err)
+			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+			return
+		}
+		neighbors, err := ring.Neighbors(sites, id, depth, rc.Wraps())
+		if err != nil {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(neighbors); err != nil {
+			log.Printf("Error encoding response: %v", err)
+		}
+	}
+}
+
+func previousSiteRedirectHandler(rc *ring.Cache, vc *visits.Counter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		rc, ok := orderedRing(w, r, rc)
+		if !ok {
+			return
+		}
 		id := mux.Vars(r)["id"]
-		site, err := getPreviousSite(db, id)
+		site, _, _, err := navigate(r, rc, id, rc.PrevIndex)
 		if err != nil {
 			http.Error(w, "Site not found", http.StatusNotFound)
 			return
 		}
+		// Reaching the directory at an end of the ring is no member visit
+		if site.ID != 0 {
+			vc.Record(site.ID, middleware.ClientIP(r))
+		}
 		http.Redirect(w, r, site.URL, http.StatusFound)
 	}
 }
 
-func nextSiteRedirectHandler(db *sql.DB) http.HandlerFunc {
+func nextSiteRedirectHandler(rc *ring.Cache, vc *visits.Counter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		rc, ok := orderedRing(w, r, rc)
+		if !ok {
+			return
+		}
 		id := mux.Vars(r)["id"]
-		site, err := getNextSite(db, id)
+		site, _, _, err := navigate(r, rc, id, rc.NextIndex)
 		if err != nil {
 			http.Error(w, "Site not found", http.StatusNotFound)
 			return
 		}
+		// Reaching the directory at an end of the ring is no member visit
+		if site.ID != 0 {
+			vc.Record(site.ID, middleware.ClientIP(r))
+		}
 		http.Redirect(w, r, site.URL, http.StatusFound)
 	}
 }
 
-func randomSiteRedirectHandler(db *sql.DB) http.HandlerFunc {
+func randomSiteRedirectHandler(rc *ring.Cache, vc *visits.Counter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		rc, ok := orderedRing(w, r, rc)
+		if !ok {
+			return
+		}
 		currentID := mux.Vars(r)["id"]
-		site, err := getRandomSite(db, currentID)
+		site, _, _, err := navigate(r, rc, currentID, rc.RandomIndex)
 		if err != nil {
-			if err.Error() == "no available sites found" {
+			if errors.Is(err, ring.ErrNotFound) {
 				http.Error(w, "No available sites found", http.StatusNotFound)
 			} else {
 				log.Printf("Error fetching random site: %v", err)
@@ -159,172 +331,113 @@ func randomSiteRedirectHandler(db *sql.DB) http.HandlerFunc {
 			}
 			return
 		}
+		vc.Record(site.ID, middleware.ClientIP(r))
 		http.Redirect(w, r, site.URL, http.StatusFound)
 	}
 }
 
-func listPublicSitesHandler(db *sql.DB) http.HandlerFunc {
+// listPublicSitesHandler serves the member list as serialized by the ring
+// cache, which only changes when the ring does, or as XML or plain text when
+// asked for.
+func listPublicSitesHandler(rc *ring.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		sites, err := getRespondingSites(db)
-		if err != nil {
-			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+		format, ok := responseFormat(w, r)
+		if !ok {
+			return
+		}
+		if format != formatJSON {
+			sites, err := rc.Sites()
+			if err != nil {
+				log.Printf("Error fetching sites: %v", err)
+				http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+				return
+			}
+			if format == formatXML {
+				list := xmlSites{Sites: make([]xmlSite, len(sites))}
+				for i, s := range sites {
+					list.Sites[i] = toXMLSite(s)
+				}
+				writeXML(w, list)
+			} else {
+				writeText(w, sitesText(sites))
+			}
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		err = json.NewEncoder(w).Encode(sites)
+		body, err := rc.SitesJSON()
 		if err != nil {
-			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			log.Printf("Error fetching sites: %v", err)
+			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
 			return
 		}
+
+		w.Header().Set("Content-Type", "application/json")
+		writeWithETag(w, r, body)
+	}
+}
+
+// orderedRing returns the ring in the ordering asked for with ?order=, so
+// widgets can traverse it differently without changing the ring_order
+// setting. It writes an error and returns false for unknown orderings.
+func orderedRing(w http.ResponseWriter, r *http.Request, rc *ring.Cache) (*ring.Cache, bool) {
+	order := r.URL.Query().Get("order")
+	if !ring.ValidOrder(order) {
+		http.Error(w, fmt.Sprintf("unknown order %q", order), http.StatusBadRequest)
+		return nil, false
 	}
+	return rc.WithOrder(order), true
 }
 
-func getRespondingSites(db *sql.DB) ([]models.PublicSite, error) {
-	rows, err := db.Query("SELECT id, name, url, favicon FROM sites WHERE is_up = true ORDER BY id")
+// navigate resolves the site reached by step from currentID in the cached ring
+// ordering. It also returns the 1-based position of that site and the ring size.
+// Steps past the ends of a ring that doesn't wrap around reach the directory,
+// at position 0.
+func navigate(r *http.Request, rc *ring.Cache, currentID string, step func([]models.PublicSite, int) (int, error)) (*models.PublicSite, int, int, error) {
+	id, err := strconv.Atoi(currentID)
 	if err != nil {
-		return nil, err
+		return nil, 0, 0, ring.ErrNotFound
 	}
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			log.Printf("Error closing rows: %v", err)
-		}
-	}(rows)
 
-	var sites []models.PublicSite
-	for rows.Next() {
-		var site models.PublicSite
-		if err := rows.Scan(&site.ID, &site.Name, &site.URL, &site.Favicon); err != nil {
-			return nil, err
-		}
-		sites = append(sites, site)
+	sites, err := rc.Sites()
+	if err != nil {
+		return nil, 0, 0, err
 	}
-	return sites, nil
-}
 
-func getNextSite(db *sql.DB, currentID string) (*models.PublicSite, error) {
-	var site models.PublicSite
-	err := db.QueryRow(`
-        WITH ring AS (
-            SELECT id, name, url, favicon, is_up,
-                   LEAD(id) OVER (ORDER BY id) AS next_id,
-                   LAG(id) OVER (ORDER BY id) AS prev_id
-            FROM sites
-            WHERE is_up = true
-        )
-        SELECT id, name, url, favicon
-        FROM ring
-        WHERE (id = $1 AND next_id IS NOT NULL AND next_id = (SELECT MIN(id) FROM ring))
-           OR (id > $1 AND is_up = true)
-           OR (id = (SELECT MIN(id) FROM ring WHERE is_up = true) AND $1 = (SELECT MAX(id) FROM ring WHERE is_up = true))
-        ORDER BY CASE
-            WHEN id > $1 THEN id
-            ELSE (SELECT MAX(id) FROM ring) + 1
-        END
-        LIMIT 1
-    `, currentID).Scan(&site.ID, &site.Name, &site.URL, &site.Favicon)
+	i, err := step(sites, id)
+	if errors.Is(err, ring.ErrEnd) {
+		return directory(r), 0, len(sites), nil
+	}
 	if err != nil {
-		return nil, err
+		return nil, 0, 0, err
 	}
-	return &site, nil
+	return &sites[i], i + 1, len(sites), nil
+}
+
+// directory stands in for the missing neighbour at the ends of a ring that
+// doesn't wrap around. Its ID is 0.
+func directory(r *http.Request) *models.PublicSite {
+	return &models.PublicSite{Name: "Back to the directory", URL: middleware.BaseURL(r) + "/"}
 }
 
-func getPreviousSite(db *sql.DB, currentID string) (*models.PublicSite, error) {
-	var site models.PublicSite
-	err := db.QueryRow(`
-        WITH ring AS (
-            SELECT id, name, url, favicon, is_up,
-                   LEAD(id) OVER (ORDER BY id) AS next_id,
-                   LAG(id) OVER (ORDER BY id) AS prev_id
-            FROM sites
-            WHERE is_up = true
-        )
-        SELECT id, name, url, favicon
-        FROM ring
-        WHERE (id = $1 AND prev_id IS NOT NULL AND prev_id = (SELECT MAX(id) FROM ring))
-           OR (id < $1 AND is_up = true)
-           OR (id = (SELECT MAX(id) FROM ring WHERE is_up = true) AND $1 = (SELECT MIN(id) FROM ring WHERE is_up = true))
-        ORDER BY CASE
-            WHEN id < $1 THEN id
-            ELSE 0
-        END DESC
-        LIMIT 1
-    `, currentID).Scan(&site.ID, &site.Name, &site.URL, &site.Favicon)
+func getSiteData(r *http.Request, rc *ring.Cache, currentID string) (*models.SiteData, error) {
+	id, err := strconv.Atoi(currentID)
 	if err != nil {
-		return nil, err
+		return nil, ring.ErrNotFound
 	}
-	return &site, nil
-}
 
-func getSiteData(db *sql.DB, id string) (*models.SiteData, error) {
-	var data models.SiteData
-	err := db.QueryRow(`
-        WITH ring AS (
-            SELECT id, name, url, favicon, is_up,
-                   LAG(id) OVER (ORDER BY id) AS prev_id,
-                   LAG(name) OVER (ORDER BY id) AS prev_name,
-                   LAG(url) OVER (ORDER BY id) AS prev_url,
-                   LAG(favicon) OVER (ORDER BY id) AS prev_favicon,
-                   LEAD(id) OVER (ORDER BY id) AS next_id,
-                   LEAD(name) OVER (ORDER BY id) AS next_name,
-                   LEAD(url) OVER (ORDER BY id) AS next_url,
-                   LEAD(favicon) OVER (ORDER BY id) AS next_favicon
-            FROM sites
-            WHERE is_up = true
-        ),
-        wrapped AS (
-            SELECT *,
-                   FIRST_VALUE(id) OVER (ORDER BY id) AS first_id,
-                   FIRST_VALUE(name) OVER (ORDER BY id) AS first_name,
-                   FIRST_VALUE(url) OVER (ORDER BY id) AS first_url,
-                   FIRST_VALUE(favicon) OVER (ORDER BY id) AS first_favicon,
-                   LAST_VALUE(id) OVER (ORDER BY id RANGE BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS last_id,
-                   LAST_VALUE(name) OVER (ORDER BY id RANGE BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS last_name,
-                   LAST_VALUE(url) OVER (ORDER BY id RANGE BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS last_url,
-                   LAST_VALUE(favicon) OVER (ORDER BY id RANGE BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS last_favicon
-            FROM ring
-        )
-        SELECT 
-            COALESCE(prev_id, last_id) AS prev_id,
-            COALESCE(prev_name, last_name) AS prev_name,
-            COALESCE(prev_url, last_url) AS prev_url,
-            COALESCE(prev_favicon, last_favicon) AS prev_favicon,
-            id AS curr_id,
-            name AS curr_name,
-            url AS curr_url,
-            favicon AS curr_favicon,
-            COALESCE(next_id, first_id) AS next_id,
-            COALESCE(next_name, first_name) AS next_name,
-            COALESCE(next_url, first_url) AS next_url,
-            COALESCE(next_favicon, first_favicon) AS next_favicon
-        FROM wrapped
-        WHERE id = $1
-    `, id).Scan(
-		&data.Prev.ID, &data.Prev.Name, &data.Prev.URL, &data.Prev.Favicon,
-		&data.Curr.ID, &data.Curr.Name, &data.Curr.URL, &data.Curr.Favicon,
-		&data.Next.ID, &data.Next.Name, &data.Next.URL, &data.Next.Favicon,
-	)
+	sites, err := rc.Sites()
 	if err != nil {
 		return nil, err
 	}
-	return &data, nil
-}
-
-func getRandomSite(db *sql.DB, currentID string) (*models.PublicSite, error) {
-	var site models.PublicSite
-	err := db.QueryRow(`
-        SELECT id, name, url, favicon
-        FROM sites
-        WHERE is_up = true AND id != $1
-        ORDER BY RANDOM()
-        LIMIT 1
-    `, currentID).Scan(&site.ID, &site.Name, &site.URL, &site.Favicon)
+	data, err := ring.Data(sites, id, rc.Wraps())
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("no available sites found")
-		}
-		return nil, fmt.Errorf("database error: %v", err)
+		return nil, err
+	}
+	if data.First {
+		data.Prev = *directory(r)
+	}
+	if data.Last {
+		data.Next = *directory(r)
 	}
-	return &site, nil
+	return data, nil
 }
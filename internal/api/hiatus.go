@@ -0,0 +1,111 @@
+package api
+
+import (
+	"crypto/hmac"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webring/internal/hiatus"
+	"webring/internal/notify"
+	"webring/internal/ring"
+
+	"github.com/gorilla/mux"
+)
+
+// signatureTolerance is how far the timestamp of a signed request may be
+// from the server's clock.
+const signatureTolerance = 5 * time.Minute
+
+// hiatusRequest is the body of the hiatus endpoint. A null until ends the
+// hiatus.
+type hiatusRequest struct {
+	Until *time.Time `json:"until"`
+}
+
+// hiatusHandler lets owners put their site on hiatus, with a request signed
+// as described at ownerRequest.
+func hiatusHandler(db *sql.DB, rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, body, ok := ownerRequest(w, r, db)
+		if !ok {
+			return
+		}
+
+		var req hiatusRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		err := hiatus.Set(db, id, req.Until)
+		if errors.Is(err, hiatus.ErrInvalid) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Error setting the hiatus of site %d: %v", id, err)
+			http.Error(w, "Error setting hiatus", http.StatusInternalServerError)
+			return
+		}
+		rc.Invalidate()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ownerRequest authenticates a request from the owner of the site in the
+// path and returns its ID and the request body. Requests are signed like the
+// webhooks the ring sends them: X-Webring-Timestamp is the Unix time, and
+// X-Webring-Signature the HMAC-SHA256 of the timestamp, a dot and the body
+// with the site's webhook secret. It writes an error and returns false when
+// the request can't be authenticated.
+func ownerRequest(w http.ResponseWriter, r *http.Request, db *sql.DB) (int, []byte, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Site not found", http.StatusNotFound)
+		return 0, nil, false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 16<<10))
+	if err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return 0, nil, false
+	}
+
+	var secret sql.NullString
+	err = db.QueryRow("SELECT webhook_secret FROM sites WHERE id = $1", id).Scan(&secret)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Site not found", http.StatusNotFound)
+		return 0, nil, false
+	}
+	if err != nil {
+		log.Printf("Error fetching site %d: %v", id, err)
+		http.Error(w, "Error fetching site", http.StatusInternalServerError)
+		return 0, nil, false
+	}
+	if !secret.Valid || secret.String == "" {
+		http.Error(w, "The site has no webhook secret to sign requests with, ask the ring admins", http.StatusForbidden)
+		return 0, nil, false
+	}
+	if !validSignature(r, secret.String, body) {
+		http.Error(w, "Invalid or expired signature", http.StatusUnauthorized)
+		return 0, nil, false
+	}
+	return id, body, true
+}
+
+func validSignature(r *http.Request, secret string, body []byte) bool {
+	timestamp := r.Header.Get("X-Webring-Timestamp")
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if d := time.Since(time.Unix(unix, 0)); d > signatureTolerance || d < -signatureTolerance {
+		return false
+	}
+	expected := notify.Sign(secret, timestamp, body)
+	return hmac.Equal([]byte(r.Header.Get("X-Webring-Signature")), []byte(expected))
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+)
+
+// callbackRegex only accepts plain (optionally dotted) JavaScript identifiers,
+// so the callback name cannot be used to inject script.
+var callbackRegex = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+const maxCallbackLength = 64
+
+// wantsScript reports whether the client asked for the script-embed output
+// mode with ?format=js.
+func wantsScript(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "js"
+}
+
+// writeScript renders v as JavaScript for legacy <script src> embeds. With a
+// callback the data is passed to it (JSONP), otherwise it is assigned to
+// window.webringData.
+func writeScript(w http.ResponseWriter, r *http.Request, v interface{}) {
+	callback := r.URL.Query().Get("callback")
+	if callback != "" && (len(callback) > maxCallbackLength || !callbackRegex.MatchString(callback)) {
+		http.Error(w, "Invalid callback", http.StatusBadRequest)
+		return
+	}
+
+	// json.Marshal escapes <, >, & and U+2028/U+2029, so the output is safe
+	// to embed in a script context.
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	var script string
+	if callback != "" {
+		script = "/**/" + callback + "(" + string(data) + ");"
+	} else {
+		script = "window.webringData = " + string(data) + ";"
+	}
+
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := w.Write([]byte(script)); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
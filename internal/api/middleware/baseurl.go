@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// BaseURL returns the public root URL of the ring without a trailing slash.
+// BASE_URL takes precedence; otherwise it is derived from the request.
+func BaseURL(r *http.Request) string {
+	if base := os.Getenv("BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if trust, _ := strconv.ParseBool(os.Getenv("TRUST_PROXY_HEADERS")); trust {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ClientIP returns the address of the client that made the request.
+// X-Forwarded-For and X-Real-IP are only honored when TRUST_PROXY_HEADERS is
+// enabled, since they are trivially spoofable when the service is exposed directly.
+//
+// Each proxy appends the address it received the request from to
+// X-Forwarded-For, so only the entries on the right, written by our own
+// proxies, can be trusted; anything to their left was sent by the client.
+// TRUSTED_PROXY_HOPS is the number of proxies in front of the service (1 by
+// default), and the entry added by the outermost one is the client.
+func ClientIP(r *http.Request) string {
+	if trust, _ := strconv.ParseBool(os.Getenv("TRUST_PROXY_HEADERS")); trust {
+		if ip := forwardedFor(r.Header.Values("X-Forwarded-For"), trustedHops()); ip != "" {
+			return ip
+		}
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+			return realIP
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func trustedHops() int {
+	hops, err := strconv.Atoi(os.Getenv("TRUSTED_PROXY_HOPS"))
+	if err != nil || hops < 1 {
+		return 1
+	}
+	return hops
+}
+
+// forwardedFor returns the hops-th address from the right of the
+// X-Forwarded-For headers, or the leftmost one if there are fewer entries.
+func forwardedFor(headers []string, hops int) string {
+	var entries []string
+	for _, h := range headers {
+		for _, entry := range strings.Split(h, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+	return entries[max(len(entries)-hops, 0)]
+}
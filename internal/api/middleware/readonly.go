@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ReadOnlyMiddleware rejects every non-GET request with 503 while readOnly
+// reports true. Requests whose path starts with one of the exempt prefixes are
+// always let through so the mode can be switched off again.
+func ReadOnlyMiddleware(readOnly func() bool, exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if readOnly() && r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions {
+				for _, prefix := range exempt {
+					if strings.HasPrefix(r.URL.Path, prefix) {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+
+				w.Header().Set("Retry-After", "600")
+				http.Error(w, "The webring is in maintenance mode and is read-only right now. Please try again later.", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"webring/internal/navtoken"
+	"webring/internal/settings"
+	"webring/internal/validate"
+
+	"github.com/gorilla/mux"
+)
+
+// requireNavToken guards the navigation and data endpoints when the
+// navigation_tokens setting is on. Requests need a token for the member
+// (?token=), which only the widget script hands out, to pages embedding it on
+// the member's own host; /{id}/data then renews it. The guard is best-effort: the
+// embedding page is known from its Referer, which browsers set honestly but
+// any other client can forge. It keeps other sites from linking into the
+// navigation, not a determined script from walking it.
+func requireNavToken(st *settings.Store, signer *navtoken.Signer) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !st.Bool(settings.NavigationTokens) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			id, err := strconv.Atoi(mux.Vars(r)["id"])
+			if err != nil {
+				http.Error(w, "Site not found", http.StatusNotFound)
+				return
+			}
+			token := r.URL.Query().Get("token")
+			if token == "" {
+				http.Error(w, "A navigation token is required", http.StatusForbidden)
+				return
+			}
+			if err := signer.Verify(token, id); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// embeddedByMember reports whether the page that loads the widget is on the
+// host of the member, the only origin the member's tokens are issued to. It
+// trusts the Referer header, see requireNavToken.
+func embeddedByMember(db *sql.DB, r *http.Request, id int) bool {
+	referer, err := url.Parse(r.Referer())
+	if err != nil || referer.Host == "" {
+		return false
+	}
+
+	var siteURL string
+	if err := db.QueryRow("SELECT url FROM sites WHERE id = $1", id).Scan(&siteURL); err != nil {
+		return false
+	}
+	u, err := url.Parse(validate.WithScheme(siteURL))
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Hostname(), referer.Hostname())
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"webring/internal/models"
+)
+
+// Output formats of the negotiated endpoints.
+const (
+	formatJSON = "json"
+	formatXML  = "xml"
+	formatText = "text"
+)
+
+// mediaFormats maps the media types clients can ask for to output formats.
+var mediaFormats = map[string]string{
+	"application/json": formatJSON,
+	// Browsers accept XML with a high q-value; opened in a browser, the
+	// endpoints keep showing JSON
+	"text/html":       formatJSON,
+	"application/xml": formatXML,
+	"text/xml":        formatXML,
+	"text/plain":      formatText,
+}
+
+// responseFormat picks the output format from ?format= (json, xml or text),
+// or else from the Accept header, preferring the highest q-value and JSON
+// when nothing matches. It writes an error and returns false for unknown
+// ?format= values. ?format=js is handled by the callers before.
+func responseFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	w.Header().Add("Vary", "Accept")
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "":
+	case formatJSON, formatXML, formatText:
+		return format, true
+	case "txt":
+		return formatText, true
+	default:
+		http.Error(w, fmt.Sprintf("unknown format %q, expected json, xml or text", format), http.StatusBadRequest)
+		return "", false
+	}
+
+	best, bestQ := formatJSON, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		format, ok := mediaFormats[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		// Earlier types win ties
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best, true
+}
+
+// xmlSite is a member in the XML output. Unknown favicons and support URLs
+// are left out.
+type xmlSite struct {
+	ID          int    `xml:"id,attr"`
+	Name        string `xml:"name"`
+	URL         string `xml:"url"`
+	Favicon     string `xml:"favicon,omitempty"`
+	SupportURL  string `xml:"support_url,omitempty"`
+	Maintenance bool   `xml:"maintenance,omitempty"`
+}
+
+func toXMLSite(s models.PublicSite) xmlSite {
+	v := xmlSite{ID: s.ID, Name: s.Name, URL: s.URL, Maintenance: s.Maintenance}
+	if s.Favicon != nil {
+		v.Favicon = *s.Favicon
+	}
+	if s.SupportURL != nil {
+		v.SupportURL = *s.SupportURL
+	}
+	return v
+}
+
+type xmlSites struct {
+	XMLName xml.Name  `xml:"sites"`
+	Sites   []xmlSite `xml:"site"`
+}
+
+type xmlSiteData struct {
+	XMLName  xml.Name `xml:"data"`
+	Position int      `xml:"position,attr"`
+	Total    int      `xml:"total,attr"`
+	Prev     xmlSite  `xml:"prev"`
+	Curr     xmlSite  `xml:"curr"`
+	Next     xmlSite  `xml:"next"`
+	Token    string   `xml:"token,omitempty"`
+}
+
+// writeXML writes v as an XML document.
+func writeXML(w http.ResponseWriter, v interface{}) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	if _, err := w.Write([]byte(xml.Header + string(body) + "\n")); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// writeText writes a plain text body.
+func writeText(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write([]byte(body)); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// sitesText renders the member list as one "Name - URL" line per site.
+func sitesText(sites []models.PublicSite) string {
+	var b strings.Builder
+	for _, site := range sites {
+		fmt.Fprintf(&b, "%s - %s\n", site.Name, site.URL)
+	}
+	return b.String()
+}
+
+// siteDataText renders site data as key=value lines, which shell scripts
+// can read with grep and cut, e.g. grep ^next_url= | cut -d= -f2-.
+func siteDataText(data *models.SiteData) string {
+	var b strings.Builder
+	for _, s := range []struct {
+		prefix string
+		site   models.PublicSite
+	}{{"prev", data.Prev}, {"curr", data.Curr}, {"next", data.Next}} {
+		fmt.Fprintf(&b, "%s_id=%d\n%s_name=%s\n%s_url=%s\n", s.prefix, s.site.ID, s.prefix, oneLine(s.site.Name), s.prefix, s.site.URL)
+	}
+	fmt.Fprintf(&b, "position=%d\ntotal=%d\n", data.Position, data.Total)
+	if data.Token != "" {
+		fmt.Fprintf(&b, "token=%s\n", data.Token)
+	}
+	return b.String()
+}
+
+// oneLine keeps a value on its line in the key=value output.
+func oneLine(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
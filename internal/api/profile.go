@@ -0,0 +1,52 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"webring/internal/validate"
+)
+
+// profileRequest is the body of the profile endpoint. Empty values clear the
+// field.
+type profileRequest struct {
+	Description string `json:"description"`
+	FeedURL     string `json:"feed_url"`
+}
+
+// profileHandler lets owners set the description and feed of their site,
+// with a request signed as described at ownerRequest.
+func profileHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, body, ok := ownerRequest(w, r, db)
+		if !ok {
+			return
+		}
+
+		var req profileRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		description, err := validate.Description(req.Description)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		feedURL, err := validate.SupportURL(req.FeedURL)
+		if err != nil {
+			http.Error(w, "Invalid feed URL: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		_, err = db.Exec("UPDATE sites SET description = NULLIF($1, ''), feed_url = NULLIF($2, '') WHERE id = $3", description, feedURL, id)
+		if err != nil {
+			log.Printf("Error updating the profile of site %d: %v", id, err)
+			http.Error(w, "Error updating profile", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
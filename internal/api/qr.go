@@ -0,0 +1,92 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/api/middleware"
+	"webring/internal/qr"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultQRScale = 8
+	maxQRScale     = 32
+)
+
+// qrHandler returns a QR code for a member. By default it points to the
+// member's own site; ?to=next, prev or random points to the ring redirect
+// from the member instead.
+func qrHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+
+		var siteURL string
+		err = db.QueryRow("SELECT url FROM sites WHERE id = $1", id).Scan(&siteURL)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error fetching site: %v", err)
+			http.Error(w, "Error fetching site", http.StatusInternalServerError)
+			return
+		}
+
+		target := siteURL
+		switch to := r.URL.Query().Get("to"); to {
+		case "", "site":
+		case "next", "prev", "random":
+			target = middleware.BaseURL(r) + "/" + strconv.Itoa(id) + "/" + to
+		default:
+			http.Error(w, "to must be site, next, prev or random", http.StatusBadRequest)
+			return
+		}
+		writeQR(w, r, target)
+	}
+}
+
+// ringQRHandler returns a QR code pointing to the ring's homepage.
+func ringQRHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeQR(w, r, middleware.BaseURL(r)+"/")
+	}
+}
+
+// writeQR answers with a PNG QR code for target, ?scale= pixels per module.
+func writeQR(w http.ResponseWriter, r *http.Request, target string) {
+	scale := defaultQRScale
+	if s := r.URL.Query().Get("scale"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 1 || v > maxQRScale {
+			http.Error(w, "scale must be between 1 and "+strconv.Itoa(maxQRScale), http.StatusBadRequest)
+			return
+		}
+		scale = v
+	}
+
+	img, err := qr.PNG(target, scale)
+	if errors.Is(err, qr.ErrTooLong) {
+		http.Error(w, "URL too long for a QR code", http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		log.Printf("Error rendering QR code: %v", err)
+		http.Error(w, "Error rendering QR code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	if _, err := w.Write(img); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"webring/internal/ring"
+	"webring/internal/settings"
+
+	"github.com/gorilla/mux"
+)
+
+// customRelationHandler redirects /{id}/{relation} according to the
+// relations defined in the custom_relations setting.
+func customRelationHandler(db *sql.DB, st *settings.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		relations, err := ring.ParseRelations(st.Get(settings.CustomRelations))
+		if err != nil {
+			log.Printf("Error parsing custom relations: %v", err)
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		target, ok := relations[strings.ToLower(vars["relation"])]
+		if !ok {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		var name, siteURL string
+		err = db.QueryRow("SELECT name, url FROM sites WHERE id = $1", id).Scan(&name, &siteURL)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error fetching site: %v", err)
+			http.Error(w, "Error fetching site", http.StatusInternalServerError)
+			return
+		}
+
+		// A leading {url} is the base of the target and is kept as is;
+		// anywhere else it is a value, e.g. a query parameter of another site
+		prefix := ""
+		if rest, ok := strings.CutPrefix(target, "{url}"); ok {
+			prefix, target = siteURL, rest
+		}
+		location := prefix + strings.NewReplacer(
+			"{id}", strconv.Itoa(id),
+			"{name}", url.PathEscape(name),
+			"{url}", url.QueryEscape(siteURL),
+		).Replace(target)
+		http.Redirect(w, r, location, http.StatusFound)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/api/middleware"
+	"webring/internal/notify"
+	"webring/internal/report"
+
+	"github.com/gorilla/mux"
+)
+
+// reportHandler files a content report against a member. It expects a JSON
+// body like {"reason": "...", "contact": "..."}.
+func reportHandler(db *sql.DB, notifier *notify.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+
+		var body struct {
+			Reason  string `json:"reason"`
+			Contact string `json:"contact"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&body); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		err = report.Submit(db, notifier, id, body.Reason, body.Contact, middleware.ClientIP(r))
+		if errors.Is(err, report.ErrSiteNotFound) {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, report.ErrInvalid) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Error filing report against site %d: %v", id, err)
+			http.Error(w, "Error filing report", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"webring/internal/models"
+	"webring/internal/ring"
+)
+
+// ringDataHandler returns the whole ring with precomputed neighbours so static
+// site generators can bake navigation into member sites with one request.
+func ringDataHandler(rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sites, err := rc.Sites()
+		if err != nil {
+			log.Printf("Error fetching ring: %v", err)
+			http.Error(w, "Error fetching ring", http.StatusInternalServerError)
+			return
+		}
+
+		data := models.RingData{
+			Total: len(sites),
+			Sites: make([]models.RingMember, 0, len(sites)),
+		}
+		for i, site := range sites {
+			member := models.RingMember{PublicSite: site, Position: i + 1}
+			// The ends of a ring that doesn't wrap around keep 0
+			if prev, err := rc.PrevIndex(sites, site.ID); err == nil {
+				member.Prev = sites[prev].ID
+			}
+			if next, err := rc.NextIndex(sites, site.ID); err == nil {
+				member.Next = sites[next].ID
+			}
+			data.Sites = append(data.Sites, member)
+		}
+
+		body, err := json.Marshal(data)
+		if err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		writeWithETag(w, r, body)
+	}
+}
+
+// writeWithETag writes body with a strong ETag derived from its content and
+// answers conditional requests with 304 Not Modified. Unless the handler set
+// a Cache-Control header, clients revalidate on every use.
+func writeWithETag(w http.ResponseWriter, r *http.Request, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/search"
+)
+
+// searchHandler runs a full-text search over member names and URLs.
+// Parameters: q (required), page (from 1) and per_page (up to 100).
+func searchHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		if q == "" {
+			http.Error(w, "Missing q parameter", http.StatusBadRequest)
+			return
+		}
+
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+		perPage, err := strconv.Atoi(r.URL.Query().Get("per_page"))
+		if err != nil || perPage < 1 {
+			perPage = search.DefaultPerPage
+		}
+		if perPage > search.MaxPerPage {
+			perPage = search.MaxPerPage
+		}
+
+		result, err := search.Sites(db, q, page, perPage)
+		if err != nil {
+			log.Printf("Error searching sites: %v", err)
+			http.Error(w, "Error searching sites", http.StatusInternalServerError)
+			return
+		}
+
+		response := struct {
+			Query   string       `json:"query"`
+			Page    int          `json:"page"`
+			PerPage int          `json:"per_page"`
+			Total   int          `json:"total"`
+			Results []search.Hit `json:"results"`
+		}{q, page, perPage, result.Total, result.Hits}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Printf("Error encoding response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"database/sql"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"webring/internal/api/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+var snippetTemplate = template.Must(template.New("snippet").Parse(`<nav class="webring" aria-label="Webring">
+  <a href="{{.Base}}/{{.ID}}/prev" rel="prev">&larr; Previous</a>
+  <a href="{{.Base}}/" rel="home">Webring</a>
+  <a href="{{.Base}}/{{.ID}}/random">Random</a>
+  <a href="{{.Base}}/{{.ID}}/next" rel="next">Next &rarr;</a>
+</nav>
+`))
+
+// snippetHandler returns a copy-pasteable HTML fragment with the navigation
+// links for a member. It is served as plain text so it can be copied
+// straight from the browser.
+func snippetHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+
+		var exists bool
+		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM sites WHERE id = $1)", id).Scan(&exists)
+		if err != nil {
+			log.Printf("Error checking site %d: %v", id, err)
+			http.Error(w, "Error fetching site", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := w.Write([]byte(Snippet(middleware.BaseURL(r), id))); err != nil {
+			log.Printf("Error writing snippet: %v", err)
+		}
+	}
+}
+
+// Snippet returns the navigation fragment for a member of the ring served at
+// base.
+func Snippet(base string, id int) string {
+	data := struct {
+		Base string
+		ID   int
+	}{base, id}
+
+	var b strings.Builder
+	if err := snippetTemplate.Execute(&b, data); err != nil {
+		log.Printf("Error rendering snippet: %v", err)
+	}
+	return b.String()
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"webring/internal/favicon"
+)
+
+// spriteImageHandler serves the favicons of all members as one image.
+func spriteImageHandler(sprite *favicon.Sprite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sheet, err := sprite.Sheet()
+		if err != nil {
+			log.Printf("Error building favicon sprite: %v", err)
+			http.Error(w, "Error building favicon sprite", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		writeWithETag(w, r, sheet.PNG)
+	}
+}
+
+// spriteOffsetsHandler serves the position of every member's icon in the
+// sprite, keyed by site ID.
+func spriteOffsetsHandler(sprite *favicon.Sprite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sheet, err := sprite.Sheet()
+		if err != nil {
+			log.Printf("Error building favicon sprite: %v", err)
+			http.Error(w, "Error building favicon sprite", http.StatusInternalServerError)
+			return
+		}
+
+		body, err := json.Marshal(sheet)
+		if err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		writeWithETag(w, r, body)
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"webring/internal/stats"
+)
+
+// statsHandler returns aggregate ring statistics for badges and third-party
+// dashboards.
+func statsHandler(sc *stats.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := sc.Public()
+		if err != nil {
+			log.Printf("Error computing stats: %v", err)
+			http.Error(w, "Error computing stats", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			log.Printf("Error encoding response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/models"
+	"webring/internal/navtoken"
+	"webring/internal/ring"
+	"webring/internal/settings"
+
+	"github.com/gorilla/mux"
+)
+
+// siteV2 is a member as the v2 endpoints return it. Unlike PublicSite, an
+// unknown favicon or support URL is left out rather than sent as null.
+type siteV2 struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Favicon     string `json:"favicon,omitempty"`
+	SupportURL  string `json:"support_url,omitempty"`
+	Maintenance bool   `json:"maintenance,omitempty"`
+}
+
+func toV2(s models.PublicSite) siteV2 {
+	v := siteV2{ID: s.ID, Name: s.Name, URL: s.URL, Maintenance: s.Maintenance}
+	if s.Favicon != nil {
+		v.Favicon = *s.Favicon
+	}
+	if s.SupportURL != nil {
+		v.SupportURL = *s.SupportURL
+	}
+	return v
+}
+
+func toV2List(sites []models.PublicSite) []siteV2 {
+	list := make([]siteV2, len(sites))
+	for i, s := range sites {
+		list[i] = toV2(s)
+	}
+	return list
+}
+
+// envelopeV2 is the response of every v2 navigation endpoint. Site is the
+// member the endpoint resolved to and Position its 1-based place in the ring.
+// Prev and Next are only set by data and neighbors, closest first.
+type envelopeV2 struct {
+	Site     siteV2   `json:"site"`
+	Position int      `json:"position"`
+	Total    int      `json:"total"`
+	Prev     []siteV2 `json:"prev,omitempty"`
+	Next     []siteV2 `json:"next,omitempty"`
+	// Token allows navigating from Site when navigation tokens are required.
+	Token string `json:"token,omitempty"`
+}
+
+// registerV2 adds the v2 navigation endpoints under /v2. The v1 endpoints
+// keep their response shapes for existing widgets.
+func registerV2(r *mux.Router, st *settings.Store, rc *ring.Cache, navGuard mux.MiddlewareFunc, signer *navtoken.Signer) {
+	r.Handle("/v2/{id:[0-9]+}/prev", navGuard(stepV2Handler(rc, (*ring.Cache).PrevIndex))).Methods("GET")
+	r.Handle("/v2/{id:[0-9]+}/next", navGuard(stepV2Handler(rc, (*ring.Cache).NextIndex))).Methods("GET")
+	r.Handle("/v2/{id:[0-9]+}/random", navGuard(stepV2Handler(rc, (*ring.Cache).RandomIndex))).Methods("GET")
+	r.Handle("/v2/{id:[0-9]+}/data", navGuard(neighborsV2Handler(rc, st, signer, false))).Methods("GET")
+	r.Handle("/v2/{id:[0-9]+}/neighbors", navGuard(neighborsV2Handler(rc, st, signer, true))).Methods("GET")
+}
+
+// stepFunc finds the index of the member one step away from id in the
+// ordering of the cache.
+type stepFunc func(c *ring.Cache, sites []models.PublicSite, id int) (int, error)
+
+// stepV2Handler returns the member one step away, in the ring ordering asked
+// for with ?order=.
+func stepV2Handler(rc *ring.Cache, step stepFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc, ok := orderedRing(w, r, rc)
+		if !ok {
+			return
+		}
+		site, position, total, err := navigate(r, rc, mux.Vars(r)["id"], func(sites []models.PublicSite, id int) (int, error) {
+			return step(rc, sites, id)
+		})
+		if err != nil {
+			if errors.Is(err, ring.ErrNotFound) {
+				http.Error(w, "Site not found", http.StatusNotFound)
+			} else {
+				log.Printf("Error navigating the ring: %v", err)
+				http.Error(w, "Error navigating the ring", http.StatusInternalServerError)
+			}
+			return
+		}
+		writeV2(w, envelopeV2{Site: toV2(*site), Position: position, Total: total})
+	}
+}
+
+// neighborsV2Handler returns the member itself with the members around it:
+// one step each way for data, up to ?depth= steps for neighbors. Data also
+// renews the navigation token.
+func neighborsV2Handler(rc *ring.Cache, st *settings.Store, signer *navtoken.Signer, withDepth bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc, ok := orderedRing(w, r, rc)
+		if !ok {
+			return
+		}
+		depth := 1
+		if withDepth {
+			depth = defaultNeighborDepth
+			if value := r.URL.Query().Get("depth"); value != "" {
+				d, err := strconv.Atoi(value)
+				if err != nil || d < 1 || d > maxNeighborDepth {
+					http.Error(w, fmt.Sprintf("depth must be between 1 and %d", maxNeighborDepth), http.StatusBadRequest)
+					return
+				}
+				depth = d
+			}
+		}
+
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		sites, err := rc.Sites()
+		if err != nil {
+			log.Printf("Error fetching sites: %v", err)
+			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+			return
+		}
+		n, err := ring.Neighbors(sites, id, depth, rc.Wraps())
+		if err != nil {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+
+		response := envelopeV2{
+			Site:     toV2(n.Curr),
+			Position: n.Position,
+			Total:    n.Total,
+			Prev:     toV2List(n.Prev),
+			Next:     toV2List(n.Next),
+		}
+		if !withDepth && st.Bool(settings.NavigationTokens) {
+			response.Token = signer.Issue(id)
+		}
+		writeV2(w, response)
+	}
+}
+
+func writeV2(w http.ResponseWriter, response envelopeV2) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"webring"
+	"webring/internal/navtoken"
+	"webring/internal/settings"
+)
+
+// tokenPlaceholder is the argument of the widget script's function that
+// receives the navigation token.
+var tokenPlaceholder = []byte("/* token */ null")
+
+// widgetScript is static/widget.js. It reads the member and theme from its
+// own URL, so one response serves every member and can be cached.
+var widgetScript = sync.OnceValues(func() ([]byte, error) {
+	return webring.Files.ReadFile("static/widget.js")
+})
+
+// widgetHandler serves the navigation widget members embed with
+// <script src="/widget.js?slug={id}">. When navigation tokens are required,
+// the script carries a token for the member if it is embedded on the member's
+// own host, and is not cached.
+func widgetHandler(db *sql.DB, st *settings.Store, signer *navtoken.Signer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := widgetScript()
+		if err != nil {
+			log.Printf("Error reading widget script: %v", err)
+			http.Error(w, "Error reading widget script", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if !st.Bool(settings.NavigationTokens) {
+			w.Header().Set("Cache-Control", "public, max-age=3600")
+			writeWithETag(w, r, body)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		if id, err := strconv.Atoi(r.URL.Query().Get("slug")); err == nil && embeddedByMember(db, r, id) {
+			token, _ := json.Marshal(signer.Issue(id))
+			body = bytes.Replace(body, tokenPlaceholder, token, 1)
+		}
+		if _, err := w.Write(body); err != nil {
+			log.Printf("Error writing response: %v", err)
+		}
+	}
+}
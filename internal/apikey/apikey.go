@@ -0,0 +1,180 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prefixLength is how much of a key is stored in clear text so admins can
+// tell keys apart.
+const prefixLength = 8
+
+type Key struct {
+	ID         int
+	Name       string
+	Prefix     string
+	Requests   int64
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// Store keeps the hashes of all API keys in memory and counts their usage.
+// Counts are written to the database by Flush.
+type Store struct {
+	db *sql.DB
+
+	mu     sync.RWMutex
+	hashes map[string]int
+
+	usageMu sync.Mutex
+	usage   map[int]int64
+}
+
+func New(db *sql.DB) *Store {
+	s := &Store{db: db, hashes: make(map[string]int), usage: make(map[int]int64)}
+	if err := s.Reload(); err != nil {
+		log.Printf("Error loading API keys: %v", err)
+	}
+	return s
+}
+
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Reload re-reads the keys from the database. It should be called after
+// every change to the api_keys table.
+func (s *Store) Reload() error {
+	rows, err := s.db.Query("SELECT id, key_hash FROM api_keys")
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	hashes := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var h string
+		if err := rows.Scan(&id, &h); err != nil {
+			return err
+		}
+		hashes[h] = id
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hashes = hashes
+	return nil
+}
+
+// FromRequest returns the key sent in the X-API-Key header or the api_key
+// query parameter, if any.
+func FromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+// Lookup returns the ID of a valid key and counts the request against it.
+func (s *Store) Lookup(key string) (int, bool) {
+	s.mu.RLock()
+	id, ok := s.hashes[hash(key)]
+	s.mu.RUnlock()
+	if !ok {
+		return 0, false
+	}
+
+	s.usageMu.Lock()
+	s.usage[id]++
+	s.usageMu.Unlock()
+	return id, true
+}
+
+// Start flushes the usage counters every interval until the process exits.
+func (s *Store) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		s.Flush()
+	}
+}
+
+func (s *Store) Flush() {
+	s.usageMu.Lock()
+	usage := s.usage
+	s.usage = make(map[int]int64)
+	s.usageMu.Unlock()
+
+	for id, count := range usage {
+		_, err := s.db.Exec("UPDATE api_keys SET requests = requests + $1, last_used_at = NOW() WHERE id = $2", count, id)
+		if err != nil {
+			log.Printf("Error recording usage of API key %d: %v", id, err)
+		}
+	}
+}
+
+// Create generates a new key. The key itself is returned only here; the
+// database stores its hash.
+func Create(db *sql.DB, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("name is required")
+	}
+
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	key := "wr_" + hex.EncodeToString(b)
+
+	_, err := db.Exec("INSERT INTO api_keys (name, key_hash, prefix) VALUES ($1, $2, $3)", name, hash(key), key[:prefixLength])
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func Remove(db *sql.DB, id int) error {
+	_, err := db.Exec("DELETE FROM api_keys WHERE id = $1", id)
+	return err
+}
+
+func List(db *sql.DB) ([]Key, error) {
+	rows, err := db.Query("SELECT id, name, prefix, requests, last_used_at, created_at FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	var keys []Key
+	for rows.Next() {
+		var k Key
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &k.Requests, &k.LastUsedAt, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,32 @@
+// Package archive implements the archive mode of a retired ring, turned on
+// with RING_ARCHIVED=true. The directory and navigation keep working from the
+// ring as it was when the server started, while reports, guestbook entries
+// and dashboard logins are refused and the background jobs that change the
+// ring stay off.
+package archive
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Enabled reports whether the ring is archived. RING_ARCHIVED is read once,
+// after the environment has been loaded.
+var Enabled = sync.OnceValue(func() bool {
+	archived, _ := strconv.ParseBool(os.Getenv("RING_ARCHIVED"))
+	return archived
+})
+
+// Middleware rejects every non-GET request with 410 while the ring is
+// archived.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if Enabled() && r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions {
+			http.Error(w, "The webring is archived and no longer accepts changes.", http.StatusGone)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
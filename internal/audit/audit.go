@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"webring/internal/models"
+)
+
+// Actions recorded in the audit log. They only describe what members and
+// visitors can see anyway, so the log is public on /changes.
+const (
+	ActionAdded      = "added"
+	ActionRemoved    = "removed"
+	ActionRenamed    = "renamed"
+	ActionURLChanged = "url_changed"
+	ActionReordered  = "reordered"
+	// ActionMerged is a duplicate entry removed in favor of another one.
+	ActionMerged = "merged"
+	// ActionRingOrder is a change of the ring_order setting, not tied to a site.
+	ActionRingOrder = "ring_order"
+)
+
+// MaxEntries caps the number of entries returned by Recent.
+const MaxEntries = 200
+
+type Entry struct {
+	ID        int       `json:"id"`
+	SiteID    *int      `json:"site_id"`
+	SiteName  string    `json:"site_name,omitempty"`
+	Action    string    `json:"action"`
+	OldValue  string    `json:"old_value,omitempty"`
+	NewValue  string    `json:"new_value,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Summary describes the change in a sentence, for the changes page and feed.
+func (e Entry) Summary() string {
+	switch e.Action {
+	case ActionAdded:
+		return fmt.Sprintf("%s joined the ring", e.SiteName)
+	case ActionRemoved:
+		return fmt.Sprintf("%s left the ring", e.SiteName)
+	case ActionRenamed:
+		return fmt.Sprintf("%s was renamed to %s", e.OldValue, e.NewValue)
+	case ActionURLChanged:
+		return fmt.Sprintf("%s moved from %s to %s", e.SiteName, e.OldValue, e.NewValue)
+	case ActionMerged:
+		return fmt.Sprintf("%s was merged into %s", e.SiteName, e.NewValue)
+	case ActionReordered:
+		return fmt.Sprintf("%s moved to a different place in the ring", e.SiteName)
+	case ActionRingOrder:
+		return fmt.Sprintf("The ring order changed from %s to %s", orDefault(e.OldValue), orDefault(e.NewValue))
+	default:
+		return fmt.Sprintf("%s: %s", e.Action, e.SiteName)
+	}
+}
+
+func orDefault(order string) string {
+	if order == "" {
+		return "the default"
+	}
+	return order
+}
+
+// Record stores entries. The change they describe has already happened, so
+// failures are only logged.
+func Record(db *sql.DB, entries ...Entry) {
+	for _, e := range entries {
+		_, err := db.Exec("INSERT INTO audit_log (site_id, site_name, action, old_value, new_value) VALUES ($1, $2, $3, $4, $5)",
+			e.SiteID, e.SiteName, e.Action, e.OldValue, e.NewValue)
+		if err != nil {
+			log.Printf("Error recording %s in the audit log: %v", e.Action, err)
+		}
+	}
+}
+
+// SiteChanges returns the entries for the public differences between two
+// versions of a site.
+func SiteChanges(old, updated models.Site) []Entry {
+	id := updated.ID
+	var entries []Entry
+	if old.Name != updated.Name {
+		entries = append(entries, Entry{SiteID: &id, SiteName: updated.Name, Action: ActionRenamed, OldValue: old.Name, NewValue: updated.Name})
+	}
+	if old.URL != updated.URL {
+		entries = append(entries, Entry{SiteID: &id, SiteName: updated.Name, Action: ActionURLChanged, OldValue: old.URL, NewValue: updated.URL})
+	}
+	if orderValue(old.DisplayOrder) != orderValue(updated.DisplayOrder) {
+		entries = append(entries, Entry{SiteID: &id, SiteName: updated.Name, Action: ActionReordered, OldValue: orderValue(old.DisplayOrder), NewValue: orderValue(updated.DisplayOrder)})
+	}
+	return entries
+}
+
+func orderValue(order *int) string {
+	if order == nil {
+		return ""
+	}
+	return strconv.Itoa(*order)
+}
+
+// Recent returns the latest limit entries, newest first.
+func Recent(db *sql.DB, limit int) ([]Entry, error) {
+	if limit <= 0 || limit > MaxEntries {
+		limit = MaxEntries
+	}
+
+	rows, err := db.Query("SELECT id, site_id, site_name, action, old_value, new_value, created_at FROM audit_log ORDER BY created_at DESC, id DESC LIMIT $1", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.SiteID, &e.SiteName, &e.Action, &e.OldValue, &e.NewValue, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
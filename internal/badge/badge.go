@@ -0,0 +1,87 @@
+package badge
+
+import (
+	"bytes"
+	"html/template"
+	"unicode/utf8"
+)
+
+// Colors used by shields.io, so the badges sit well next to other README badges.
+const (
+	ColorBrightGreen = "#4c1"
+	ColorGreen       = "#97ca00"
+	ColorYellow      = "#dfb317"
+	ColorOrange      = "#fe7d37"
+	ColorRed         = "#e05d44"
+	ColorBlue        = "#007ec6"
+	ColorGrey        = "#9f9f9f"
+)
+
+// charWidth approximates the advance of a character in 11px Verdana.
+const (
+	charWidth = 7
+	padding   = 10
+)
+
+var svgTemplate = template.Must(template.New("badge").Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="{{.Label}}: {{.Value}}">
+<title>{{.Label}}: {{.Value}}</title>
+<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="{{.Width}}" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)">
+<rect width="{{.LabelWidth}}" height="20" fill="#555"/>
+<rect x="{{.LabelWidth}}" width="{{.ValueWidth}}" height="20" fill="{{.Color}}"/>
+<rect width="{{.Width}}" height="20" fill="url(#s)"/>
+</g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="{{.LabelX}}" y="15" fill="#010101" fill-opacity=".3">{{.Label}}</text>
+<text x="{{.LabelX}}" y="14">{{.Label}}</text>
+<text x="{{.ValueX}}" y="15" fill="#010101" fill-opacity=".3">{{.Value}}</text>
+<text x="{{.ValueX}}" y="14">{{.Value}}</text>
+</g>
+</svg>`))
+
+type layout struct {
+	Label, Value, Color           string
+	Width, LabelWidth, ValueWidth int
+	LabelX, ValueX                float64
+}
+
+// Render returns a shields-style SVG badge.
+func Render(label, value, color string) ([]byte, error) {
+	l := layout{
+		Label:      label,
+		Value:      value,
+		Color:      color,
+		LabelWidth: textWidth(label),
+		ValueWidth: textWidth(value),
+	}
+	l.Width = l.LabelWidth + l.ValueWidth
+	l.LabelX = float64(l.LabelWidth) / 2
+	l.ValueX = float64(l.LabelWidth) + float64(l.ValueWidth)/2
+
+	var buf bytes.Buffer
+	if err := svgTemplate.Execute(&buf, l); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func textWidth(s string) int {
+	return utf8.RuneCountInString(s)*charWidth + padding
+}
+
+// UptimeColor picks a badge color for an uptime percentage.
+func UptimeColor(percent float64) string {
+	switch {
+	case percent >= 99:
+		return ColorBrightGreen
+	case percent >= 95:
+		return ColorGreen
+	case percent >= 90:
+		return ColorYellow
+	case percent >= 75:
+		return ColorOrange
+	default:
+		return ColorRed
+	}
+}
@@ -0,0 +1,66 @@
+package badge
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestUptimeColor(t *testing.T) {
+	for _, tc := range []struct {
+		percent float64
+		want    string
+	}{
+		{100, ColorBrightGreen},
+		{99, ColorBrightGreen},
+		{98.99, ColorGreen},
+		{95, ColorGreen},
+		{94.5, ColorYellow},
+		{90, ColorYellow},
+		{89.9, ColorOrange},
+		{75, ColorOrange},
+		{74.99, ColorRed},
+		{0, ColorRed},
+	} {
+		if got := UptimeColor(tc.percent); got != tc.want {
+			t.Errorf("UptimeColor(%v) = %s, want %s", tc.percent, got, tc.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	for _, tc := range []struct {
+		label, value string
+		width        int
+	}{
+		{"webring", "42 members", 7*7 + 10 + 10*7 + 10},
+		{"uptime", "99.9%", 6*7 + 10 + 5*7 + 10},
+		// Widths count characters, not bytes
+		{"кольцо", "ок", 6*7 + 10 + 2*7 + 10},
+		{`<a&"b">`, "</svg>", 7*7 + 10 + 6*7 + 10},
+	} {
+		out, err := Render(tc.label, tc.value, ColorBlue)
+		if err != nil {
+			t.Fatalf("Render(%q, %q) = %v", tc.label, tc.value, err)
+		}
+
+		var svg struct {
+			Width int      `xml:"width,attr"`
+			Title string   `xml:"title"`
+			Text  []string `xml:"g>text"`
+		}
+		if err := xml.Unmarshal(out, &svg); err != nil {
+			t.Fatalf("Render(%q, %q) is not well-formed: %v", tc.label, tc.value, err)
+		}
+		if svg.Width != tc.width {
+			t.Errorf("Render(%q, %q) width = %d, want %d", tc.label, tc.value, svg.Width, tc.width)
+		}
+		if want := tc.label + ": " + tc.value; svg.Title != want {
+			t.Errorf("Render(%q, %q) title = %q, want %q", tc.label, tc.value, svg.Title, want)
+		}
+		// Each text is drawn twice, once as its shadow
+		if want := []string{tc.label, tc.label, tc.value, tc.value}; strings.Join(svg.Text, "\x00") != strings.Join(want, "\x00") {
+			t.Errorf("Render(%q, %q) texts = %q", tc.label, tc.value, svg.Text)
+		}
+	}
+}
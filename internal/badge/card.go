@@ -0,0 +1,47 @@
+package badge
+
+import (
+	"bytes"
+	"html/template"
+
+	"webring/internal/templates"
+)
+
+// Card is the content of a link preview card.
+type Card struct {
+	Ring string
+	Name string
+	URL  string
+	// Uptime is the percentage over 30 days; nil to leave it out.
+	Uptime *float64
+	// Favicon is a data: URI of the site's favicon, or empty.
+	Favicon template.URL
+}
+
+// maxNameLength keeps long names from running off the card.
+const maxNameLength = 28
+
+var cardTemplate = template.Must(template.New("card").Funcs(templates.FuncMap).Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="1200" height="630" viewBox="0 0 1200 630" role="img" aria-label="{{.Name}} - {{.Ring}}">
+<rect width="1200" height="630" fill="#0b0b0f"/>
+<rect x="40" y="40" width="1120" height="550" rx="24" fill="#16161d" stroke="#2a2a35" stroke-width="2"/>
+<g font-family="Inter,Helvetica,Arial,sans-serif" fill="#fafafa">
+{{if .Favicon}}<image href="{{.Favicon}}" x="100" y="150" width="96" height="96"/>{{else}}<rect x="100" y="150" width="96" height="96" rx="12" fill="#2a2a35"/>{{end}}
+<text x="230" y="220" font-size="72" font-weight="700">{{.Name}}</text>
+<text x="100" y="330" font-size="36" fill="#a1a1aa">{{.URL}}</text>
+{{with .Uptime}}<text x="100" y="400" font-size="32" fill="#4ade80">{{percent .}} uptime over 30 days</text>{{end}}
+<text x="100" y="530" font-size="32" fill="#a1a1aa">Member of {{.Ring}}</text>
+</g>
+</svg>`))
+
+// RenderCard returns a 1200x630 SVG card, the size link unfurlers expect for
+// large previews.
+func RenderCard(c Card) ([]byte, error) {
+	c.Name = templates.Truncate(maxNameLength, c.Name)
+	c.URL = templates.Truncate(2*maxNameLength, c.URL)
+
+	var buf bytes.Buffer
+	if err := cardTemplate.Execute(&buf, c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
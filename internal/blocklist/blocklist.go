@@ -0,0 +1,186 @@
+package blocklist
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"webring/internal/api/middleware"
+)
+
+const (
+	KindIP        = "ip"
+	KindCIDR      = "cidr"
+	KindUserAgent = "user_agent"
+)
+
+type Entry struct {
+	ID        int
+	Kind      string
+	Value     string
+	Reason    *string
+	CreatedAt time.Time
+}
+
+// Blocklist keeps an in-memory copy of the blocklist table so that the
+// middleware does not hit the database on every request.
+type Blocklist struct {
+	db *sql.DB
+
+	mu     sync.RWMutex
+	ips    map[string]bool
+	nets   []*net.IPNet
+	agents []string
+}
+
+func New(db *sql.DB) *Blocklist {
+	b := &Blocklist{db: db, ips: make(map[string]bool)}
+	if err := b.Reload(); err != nil {
+		log.Printf("Error loading blocklist: %v", err)
+	}
+	return b
+}
+
+// Reload re-reads the blocklist from the database. It should be called after
+// every change to the blocklist table.
+func (b *Blocklist) Reload() error {
+	entries, err := List(b.db)
+	if err != nil {
+		return err
+	}
+
+	ips := make(map[string]bool)
+	var nets []*net.IPNet
+	var agents []string
+	for _, e := range entries {
+		switch e.Kind {
+		case KindIP:
+			if ip := net.ParseIP(e.Value); ip != nil {
+				ips[ip.String()] = true
+			}
+		case KindCIDR:
+			if _, ipNet, err := net.ParseCIDR(e.Value); err == nil {
+				nets = append(nets, ipNet)
+			}
+		case KindUserAgent:
+			agents = append(agents, strings.ToLower(e.Value))
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ips = ips
+	b.nets = nets
+	b.agents = agents
+	return nil
+}
+
+func (b *Blocklist) IsBlocked(r *http.Request) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if ip := net.ParseIP(middleware.ClientIP(r)); ip != nil {
+		if b.ips[ip.String()] {
+			return true
+		}
+		for _, ipNet := range b.nets {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	userAgent := strings.ToLower(r.UserAgent())
+	for _, agent := range b.agents {
+		if strings.Contains(userAgent, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Blocklist) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if b.IsBlocked(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func List(db *sql.DB) ([]Entry, error) {
+	rows, err := db.Query("SELECT id, kind, value, reason, created_at FROM blocklist ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.Kind, &e.Value, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Normalize validates a blocklist value for the given kind and returns it in
+// its canonical form.
+func Normalize(kind, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", errors.New("value is required")
+	}
+
+	switch kind {
+	case KindIP:
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return "", fmt.Errorf("invalid IP address: %s", value)
+		}
+		return ip.String(), nil
+	case KindCIDR:
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid CIDR: %s", value)
+		}
+		return ipNet.String(), nil
+	case KindUserAgent:
+		return value, nil
+	default:
+		return "", fmt.Errorf("unknown blocklist kind: %s", kind)
+	}
+}
+
+func Add(db *sql.DB, kind, value, reason string) error {
+	value, err := Normalize(kind, value)
+	if err != nil {
+		return err
+	}
+
+	var reasonArg *string
+	if reason != "" {
+		reasonArg = &reason
+	}
+	_, err = db.Exec("INSERT INTO blocklist (kind, value, reason) VALUES ($1, $2, $3) ON CONFLICT (kind, value) DO UPDATE SET reason = EXCLUDED.reason", kind, value, reasonArg)
+	return err
+}
+
+func Remove(db *sql.DB, id int) error {
+	_, err := db.Exec("DELETE FROM blocklist WHERE id = $1", id)
+	return err
+}
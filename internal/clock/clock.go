@@ -0,0 +1,82 @@
+// Package clock abstracts the current time, timers and randomness, so code
+// depending on them can run against Fake and seeded sources and behave the
+// same way on every run.
+package clock
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Clock tells the time and schedules work, like the functions of the time
+// package.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f once d has elapsed, as time.AfterFunc does.
+	AfterFunc(d time.Duration, f func()) Timer
+	// NewTicker delivers the time on its channel every d, as time.NewTicker
+	// does, dropping ticks for slow receivers.
+	NewTicker(d time.Duration) Ticker
+}
+
+type Timer interface {
+	// Stop prevents the timer from firing and reports whether it did so.
+	Stop() bool
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Since returns the time elapsed since t according to c.
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Until returns the duration until t according to c.
+func Until(c Clock, t time.Time) time.Duration {
+	return t.Sub(c.Now())
+}
+
+// Real is the system clock.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// Rand picks random numbers. *rand.Rand implements it; a source created with
+// a fixed seed repeats its picks on every run.
+type Rand interface {
+	// Intn returns a number in [0, n).
+	Intn(n int) int
+}
+
+// Global draws from the shared source of math/rand, which is safe for
+// concurrent use.
+var Global Rand = globalRand{}
+
+type globalRand struct{}
+
+func (globalRand) Intn(n int) int {
+	return rand.Intn(n)
+}
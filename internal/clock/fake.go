@@ -0,0 +1,132 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when told to. Timers and tickers fire
+// while Advance passes their deadline, in deadline order, so scheduling,
+// expiry and throttling windows can be simulated without waiting.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// NewFake returns a Fake showing start.
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+// fakeWaiter is a pending AfterFunc or ticker.
+type fakeWaiter struct {
+	clock  *Fake
+	when   time.Time
+	f      func()
+	period time.Duration
+	ch     chan time.Time
+}
+
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc schedules f. Unlike time.AfterFunc, f runs on the goroutine
+// calling Advance, so it has finished when Advance returns.
+func (c *Fake) AfterFunc(d time.Duration, f func()) Timer {
+	return fakeTimer{c.add(&fakeWaiter{clock: c, when: c.Now().Add(d), f: f})}
+}
+
+func (c *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return fakeTicker{c.add(&fakeWaiter{clock: c, when: c.Now().Add(d), period: d, ch: make(chan time.Time, 1)})}
+}
+
+func (c *Fake) add(w *fakeWaiter) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+// Set moves the clock to t, firing what is due on the way. The clock never
+// goes backwards.
+func (c *Fake) Set(t time.Time) {
+	for {
+		c.mu.Lock()
+		sort.SliceStable(c.waiters, func(i, j int) bool { return c.waiters[i].when.Before(c.waiters[j].when) })
+		if len(c.waiters) == 0 || c.waiters[0].when.After(t) {
+			if t.After(c.now) {
+				c.now = t
+			}
+			c.mu.Unlock()
+			return
+		}
+
+		w := c.waiters[0]
+		if w.when.After(c.now) {
+			c.now = w.when
+		}
+		if w.period > 0 {
+			w.when = w.when.Add(w.period)
+		} else {
+			c.waiters = c.waiters[1:]
+		}
+		now := c.now
+		c.mu.Unlock()
+
+		if w.ch != nil {
+			select {
+			case w.ch <- now:
+			default:
+			}
+		} else {
+			w.f()
+		}
+	}
+}
+
+// Advance moves the clock forward by d, firing what is due on the way.
+func (c *Fake) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// stop removes the waiter and reports whether it was still pending.
+func (w *fakeWaiter) stop() bool {
+	c := w.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.waiters {
+		if other == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	*fakeWaiter
+}
+
+func (t fakeTimer) Stop() bool {
+	return t.stop()
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t fakeTicker) Stop() {
+	t.stop()
+}
@@ -0,0 +1,527 @@
+package dashboard
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"webring/internal/audit"
+	"webring/internal/hiatus"
+	"webring/internal/models"
+	"webring/internal/monitor"
+	"webring/internal/outbox"
+	"webring/internal/ring"
+	"webring/internal/settings"
+	"webring/internal/uptime"
+	"webring/internal/validate"
+
+	"github.com/gorilla/mux"
+)
+
+// siteSpec is the editable part of a site as managed through the admin API.
+// Its fields are named like the ones of models.Site.
+type siteSpec struct {
+	Name                  string     `json:"name"`
+	URL                   string     `json:"url"`
+	Notes                 string     `json:"notes"`
+	DisplayOrder          *int       `json:"display_order"`
+	SupportURL            string     `json:"support_url"`
+	WebhookURL            string     `json:"webhook_url"`
+	CheckType             string     `json:"check_type"`
+	CheckTarget           string     `json:"check_target"`
+	MaintenanceStart      *time.Time `json:"maintenance_start"`
+	MaintenanceEnd        *time.Time `json:"maintenance_end"`
+	MaintenanceKeepInRing bool       `json:"maintenance_keep_in_ring"`
+	MonitorURL            string     `json:"monitor_url"`
+	MonitorKind           string     `json:"monitor_kind"`
+}
+
+// normalize validates the spec the same way the dashboard forms are, and
+// fills in defaults.
+func (s *siteSpec) normalize(st *settings.Store) error {
+	if s.Name == "" || s.URL == "" {
+		return errors.New("name and url are required")
+	}
+
+	var err error
+	if s.Name, err = validate.SiteName(s.Name, st.Bool(settings.StripEmojiInNames)); err != nil {
+		return fmt.Errorf("invalid name: %w", err)
+	}
+	if s.URL, err = validate.SiteURL(s.URL, urlPolicy(st)); err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if s.SupportURL, err = validate.SupportURL(s.SupportURL); err != nil {
+		return fmt.Errorf("invalid support_url: %w", err)
+	}
+
+	if s.CheckType == "" {
+		s.CheckType = uptime.CheckHTTP
+	}
+	if !uptime.ValidCheckType(s.CheckType) {
+		return fmt.Errorf("invalid check_type: %s", s.CheckType)
+	}
+
+	if (s.MaintenanceStart == nil) != (s.MaintenanceEnd == nil) {
+		return errors.New("maintenance_start and maintenance_end must be set together")
+	}
+	if s.MaintenanceStart != nil && !s.MaintenanceEnd.After(*s.MaintenanceStart) {
+		return errors.New("maintenance_end must be after maintenance_start")
+	}
+
+	if s.MonitorKind == "" {
+		s.MonitorKind = monitor.KindWebhook
+	}
+	if !monitor.ValidKind(s.MonitorKind) {
+		return fmt.Errorf("invalid monitor_kind: %s", s.MonitorKind)
+	}
+	if s.MonitorURL, err = validate.SupportURL(s.MonitorURL); err != nil {
+		return fmt.Errorf("invalid monitor_url: %w", err)
+	}
+	return nil
+}
+
+// specOf returns the spec a stored site matches.
+func specOf(site models.Site) siteSpec {
+	spec := siteSpec{
+		Name:                  site.Name,
+		URL:                   site.URL,
+		Notes:                 site.Notes,
+		DisplayOrder:          site.DisplayOrder,
+		CheckType:             site.CheckType,
+		CheckTarget:           site.CheckTarget,
+		MaintenanceStart:      site.MaintenanceStart,
+		MaintenanceEnd:        site.MaintenanceEnd,
+		MaintenanceKeepInRing: site.MaintenanceKeepInRing,
+		MonitorKind:           site.MonitorKind,
+	}
+	if site.SupportURL != nil {
+		spec.SupportURL = *site.SupportURL
+	}
+	if site.WebhookURL != nil {
+		spec.WebhookURL = *site.WebhookURL
+	}
+	if site.MonitorURL != nil {
+		spec.MonitorURL = *site.MonitorURL
+	}
+	return spec
+}
+
+// siteETag identifies the managed state of a site. Status fields updated by
+// the checker don't change it.
+func siteETag(site models.Site) string {
+	data, err := json.Marshal(specOf(site))
+	if err != nil {
+		log.Printf("Error encoding site %d: %v", site.ID, err)
+	}
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// matchesIfMatch checks an If-Match header against the current version of a
+// site, which is nil if the site doesn't exist.
+func matchesIfMatch(header string, current *models.Site) bool {
+	if header == "" {
+		return true
+	}
+	if current == nil {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	etag := siteETag(*current)
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// lockSite returns the current version of a site, locked until the end of
+// the transaction, or nil if there is none.
+func lockSite(tx *sql.Tx, id int) (*models.Site, error) {
+	site, err := scanSite(tx.QueryRow("SELECT "+siteColumns+" FROM sites WHERE id = $1 FOR UPDATE", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &site, nil
+}
+
+// putSite creates site id or replaces its managed fields with spec. old is
+// the current version from lockSite.
+func putSite(tx *sql.Tx, id int, spec siteSpec, old *models.Site) error {
+	if old == nil {
+		_, err := tx.Exec(`
+			INSERT INTO sites (id, name, url, notes, webhook_url, webhook_secret, check_type, check_target, display_order, support_url,
+			                   maintenance_start, maintenance_end, maintenance_keep_in_ring, monitor_url, monitor_kind)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, NULLIF($10, ''), $11, $12, $13, NULLIF($14, ''), $15)`,
+			id, spec.Name, spec.URL, spec.Notes, spec.WebhookURL, newWebhookSecret(spec.WebhookURL), spec.CheckType, spec.CheckTarget,
+			spec.DisplayOrder, spec.SupportURL, spec.MaintenanceStart, spec.MaintenanceEnd, spec.MaintenanceKeepInRing, spec.MonitorURL, spec.MonitorKind)
+		return err
+	}
+
+	// As on the dashboard, the webhook secret survives edits
+	_, err := tx.Exec(`
+		UPDATE sites
+		SET name = $1, url = $2, notes = $3,
+		    webhook_url = NULLIF($4, ''),
+		    webhook_secret = CASE WHEN $4 = '' THEN NULL ELSE COALESCE(webhook_secret, $5) END,
+		    check_type = $6, check_target = $7, display_order = $8,
+		    support_url = NULLIF($9, ''),
+		    maintenance_start = $10, maintenance_end = $11, maintenance_keep_in_ring = $12,
+		    monitor_url = NULLIF($13, ''), monitor_kind = $14
+		WHERE id = $15`,
+		spec.Name, spec.URL, spec.Notes, spec.WebhookURL, newWebhookSecret(spec.WebhookURL), spec.CheckType, spec.CheckTarget,
+		spec.DisplayOrder, spec.SupportURL, spec.MaintenanceStart, spec.MaintenanceEnd, spec.MaintenanceKeepInRing,
+		spec.MonitorURL, spec.MonitorKind, id)
+	return err
+}
+
+// enqueueSiteChanged queues the side effects of a putSite, fetching the
+// favicon of new sites and sites that moved.
+func enqueueSiteChanged(tx *sql.Tx, id int, spec siteSpec, old *models.Site) error {
+	refresh := old == nil || old.URL != spec.URL
+	return outbox.Enqueue(tx, outbox.EventSiteChanged, outbox.SiteChanged{SiteID: id, URL: spec.URL, RefreshFavicon: refresh})
+}
+
+// siteAuditEntries describes a putSite for the audit log.
+func siteAuditEntries(id int, spec siteSpec, old *models.Site) []audit.Entry {
+	if old == nil {
+		return []audit.Entry{{SiteID: &id, SiteName: spec.Name, Action: audit.ActionAdded, NewValue: spec.URL}}
+	}
+	return audit.SiteChanges(*old, models.Site{ID: id, Name: spec.Name, URL: spec.URL, DisplayOrder: spec.DisplayOrder})
+}
+
+// apiError writes an admin API error as {"error": "..."}.
+func apiError(w http.ResponseWriter, message string, code int) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
+
+// getSiteAPIHandler returns a site with its ETag, for use with If-Match.
+func getSiteAPIHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(mux.Vars(r)["id"])
+		site, err := getSite(db, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			apiError(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error fetching site: %v", err)
+			apiError(w, "Error fetching site", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("ETag", siteETag(site))
+		writeJSON(w, http.StatusOK, site)
+	}
+}
+
+// putSiteAPIHandler creates or updates a site to match the request body
+// exactly: fields left out are reset to their defaults. It answers 201 when
+// the site was created and 200 when it was updated, and honours If-Match.
+func putSiteAPIHandler(db *sql.DB, st *settings.Store, rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+		// Read-only fields of the GET representation, like is_up, are ignored
+		var spec siteSpec
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBody)).Decode(&spec); err != nil {
+			apiError(w, "Invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := spec.normalize(st); err != nil {
+			apiError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Error starting transaction: %v", err)
+			apiError(w, "Error saving site", http.StatusInternalServerError)
+			return
+		}
+		defer func(tx *sql.Tx) {
+			if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+				log.Printf("Error rolling back transaction: %v", err)
+			}
+		}(tx)
+
+		old, err := lockSite(tx, id)
+		if err != nil {
+			log.Printf("Error fetching site: %v", err)
+			apiError(w, "Error fetching site", http.StatusInternalServerError)
+			return
+		}
+		if !matchesIfMatch(r.Header.Get("If-Match"), old) {
+			apiError(w, "Site does not match If-Match", http.StatusPreconditionFailed)
+			return
+		}
+		if err := putSite(tx, id, spec, old); err != nil {
+			log.Printf("Error saving site %d: %v", id, err)
+			apiError(w, "Error saving site", http.StatusInternalServerError)
+			return
+		}
+		if err := enqueueSiteChanged(tx, id, spec, old); err != nil {
+			log.Printf("Error queueing changes of site %d: %v", id, err)
+			apiError(w, "Error saving site", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			log.Printf("Error committing site %d: %v", id, err)
+			apiError(w, "Error saving site", http.StatusInternalServerError)
+			return
+		}
+		rc.Invalidate()
+		audit.Record(db, siteAuditEntries(id, spec, old)...)
+
+		site, err := getSite(db, id)
+		if err != nil {
+			log.Printf("Error fetching site: %v", err)
+			apiError(w, "Error fetching site", http.StatusInternalServerError)
+			return
+		}
+		code := http.StatusOK
+		if old == nil {
+			code = http.StatusCreated
+			w.Header().Set("Location", r.URL.Path)
+		}
+		w.Header().Set("ETag", siteETag(site))
+		writeJSON(w, code, site)
+	}
+}
+
+// maxBatchOperations bounds the size of a batch request.
+const maxBatchOperations = 500
+
+// batchOperation is one step of a batch request. Depending on Op it uses
+// Site ("add" and "put") or DisplayOrder ("reorder"); "delete" only needs
+// the ID.
+type batchOperation struct {
+	Op           string    `json:"op"`
+	ID           int       `json:"id"`
+	Site         *siteSpec `json:"site,omitempty"`
+	DisplayOrder *int      `json:"display_order,omitempty"`
+}
+
+type batchResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// batchEffects collects the audit entries to record once a batch is
+// committed, and the display orders its reorders replaced.
+type batchEffects struct {
+	audit         []audit.Entry
+	previousOrder []orderEntry
+	reordered     map[int]bool
+}
+
+// applyOperation runs op inside the batch transaction and returns the status
+// to report for it.
+func applyOperation(tx *sql.Tx, st *settings.Store, op batchOperation, effects *batchEffects) (string, error) {
+	id := op.ID
+	if id <= 0 {
+		return "", errors.New("id is required")
+	}
+
+	switch op.Op {
+	case "add", "put":
+		if op.Site == nil {
+			return "", errors.New("site is required")
+		}
+		spec := *op.Site
+		if err := spec.normalize(st); err != nil {
+			return "", err
+		}
+		old, err := lockSite(tx, id)
+		if err != nil {
+			return "", err
+		}
+		if old != nil && op.Op == "add" {
+			return "", errors.New("site already exists")
+		}
+		if err := putSite(tx, id, spec, old); err != nil {
+			return "", err
+		}
+		if err := enqueueSiteChanged(tx, id, spec, old); err != nil {
+			return "", err
+		}
+		effects.audit = append(effects.audit, siteAuditEntries(id, spec, old)...)
+		if old == nil {
+			return "created", nil
+		}
+		return "updated", nil
+
+	case "delete":
+		var name, url string
+		err := tx.QueryRow("DELETE FROM sites WHERE id = $1 RETURNING name, url", id).Scan(&name, &url)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", errors.New("site not found")
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := outbox.Enqueue(tx, outbox.EventSiteChanged, outbox.SiteChanged{SiteID: id, URL: url, Removed: true}); err != nil {
+			return "", err
+		}
+		effects.audit = append(effects.audit, audit.Entry{SiteID: &id, SiteName: name, Action: audit.ActionRemoved, OldValue: url})
+		return "deleted", nil
+
+	case "reorder":
+		old, err := lockSite(tx, id)
+		if err != nil {
+			return "", err
+		}
+		if old == nil {
+			return "", errors.New("site not found")
+		}
+		if _, err := tx.Exec("UPDATE sites SET display_order = $1 WHERE id = $2", op.DisplayOrder, id); err != nil {
+			return "", err
+		}
+		updated := *old
+		updated.DisplayOrder = op.DisplayOrder
+		effects.audit = append(effects.audit, audit.SiteChanges(*old, updated)...)
+		// Undo goes back to the order from before the batch
+		if !effects.reordered[id] {
+			if effects.reordered == nil {
+				effects.reordered = make(map[int]bool)
+			}
+			effects.reordered[id] = true
+			effects.previousOrder = append(effects.previousOrder, orderEntry{ID: id, DisplayOrder: old.DisplayOrder})
+		}
+		return "reordered", nil
+
+	default:
+		return "", fmt.Errorf("unknown op %q, expected add, put, delete or reorder", op.Op)
+	}
+}
+
+// batchSitesHandler applies a list of operations atomically: either all of
+// them succeed, or none is applied and the report points at the one that
+// failed.
+func batchSitesHandler(db *sql.DB, st *settings.Store, rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Operations []batchOperation `json:"operations"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBody)).Decode(&body); err != nil {
+			apiError(w, "Invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(body.Operations) == 0 {
+			apiError(w, "operations are required", http.StatusBadRequest)
+			return
+		}
+		if len(body.Operations) > maxBatchOperations {
+			apiError(w, fmt.Sprintf("at most %d operations per batch", maxBatchOperations), http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Error starting transaction: %v", err)
+			apiError(w, "Error applying batch", http.StatusInternalServerError)
+			return
+		}
+		defer func(tx *sql.Tx) {
+			if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+				log.Printf("Error rolling back transaction: %v", err)
+			}
+		}(tx)
+
+		var effects batchEffects
+		results := make([]batchResult, len(body.Operations))
+		failed := -1
+		for i, op := range body.Operations {
+			results[i] = batchResult{Index: i, Op: op.Op, ID: op.ID, Status: "skipped"}
+			if failed >= 0 {
+				continue
+			}
+			status, err := applyOperation(tx, st, op, &effects)
+			if err != nil {
+				results[i].Status, results[i].Error = "failed", err.Error()
+				failed = i
+				continue
+			}
+			results[i].Status = status
+		}
+
+		if failed >= 0 {
+			for i := 0; i < failed; i++ {
+				results[i].Status = "rolled_back"
+			}
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{"applied": false, "results": results})
+			return
+		}
+		if err := recordReorder(tx, effects.previousOrder, "api"); err != nil {
+			log.Printf("Error recording reorder: %v", err)
+			apiError(w, "Error applying batch", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			log.Printf("Error committing batch: %v", err)
+			apiError(w, "Error applying batch", http.StatusInternalServerError)
+			return
+		}
+
+		rc.Invalidate()
+		audit.Record(db, effects.audit...)
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"applied": true, "results": results})
+	}
+}
+
+// putHiatusAPIHandler puts a site on hiatus until {"until": "..."}, or ends
+// the hiatus with {"until": null}, on behalf of its owner.
+func putHiatusAPIHandler(db *sql.DB, rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+		var body struct {
+			Until *time.Time `json:"until"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBody)).Decode(&body); err != nil {
+			apiError(w, "Invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := hiatus.Set(db, id, body.Until)
+		if errors.Is(err, hiatus.ErrNotFound) {
+			apiError(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, hiatus.ErrInvalid) {
+			apiError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Error setting the hiatus of site %d: %v", id, err)
+			apiError(w, "Error setting hiatus", http.StatusInternalServerError)
+			return
+		}
+		rc.Invalidate()
+
+		site, err := getSite(db, id)
+		if err != nil {
+			log.Printf("Error fetching site: %v", err)
+			apiError(w, "Error fetching site", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, site)
+	}
+}
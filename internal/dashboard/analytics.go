@@ -0,0 +1,61 @@
+package dashboard
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"webring/internal/analytics"
+	"webring/internal/render"
+)
+
+// weekdays label the rows of the activity heatmap.
+var weekdays = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+type analyticsPage struct {
+	*analytics.Report
+	Weekdays []string
+}
+
+// analyticsHandler charts members joining, how long reports and guestbook
+// entries wait for an admin, and when admins change the ring.
+func analyticsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		report, err := analytics.Build(db)
+		if err != nil {
+			log.Printf("Error building analytics: %v", err)
+			http.Error(w, "Error building analytics", http.StatusInternalServerError)
+			return
+		}
+
+		render.HTML(w, http.StatusOK, t, "analytics.html", newPage(db, analyticsPage{Report: report, Weekdays: weekdays}))
+	}
+}
+
+// analyticsJSONHandler serves the same report for other tools.
+func analyticsJSONHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, err := analytics.Build(db)
+		if err != nil {
+			log.Printf("Error building analytics: %v", err)
+			http.Error(w, "Error building analytics", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			log.Printf("Error encoding response: %v", err)
+		}
+	}
+}
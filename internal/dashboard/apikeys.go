@@ -0,0 +1,86 @@
+package dashboard
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/apikey"
+	"webring/internal/render"
+
+	"github.com/gorilla/mux"
+)
+
+type apiKeysPage struct {
+	Keys []apikey.Key
+	// NewKey is shown once, right after it was created.
+	NewKey string
+}
+
+func renderAPIKeys(w http.ResponseWriter, db *sql.DB, newKey string) {
+	templatesMu.RLock()
+	t := templates
+	templatesMu.RUnlock()
+
+	if t == nil {
+		log.Println("Templates not initialized")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	keys, err := apikey.List(db)
+	if err != nil {
+		log.Printf("Error fetching API keys: %v", err)
+		http.Error(w, "Error fetching API keys", http.StatusInternalServerError)
+		return
+	}
+
+	render.HTML(w, http.StatusOK, t, "apikeys.html", newPage(db, apiKeysPage{keys, newKey}))
+}
+
+func apiKeysHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		renderAPIKeys(w, db, "")
+	}
+}
+
+func addAPIKeyHandler(db *sql.DB, keys *apikey.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := apikey.Create(db, r.FormValue("name"))
+		if err != nil {
+			http.Error(w, "Error creating API key: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := keys.Reload(); err != nil {
+			log.Printf("Error reloading API keys: %v", err)
+		}
+
+		// Rendered directly instead of redirecting: the key cannot be shown again
+		renderAPIKeys(w, db, key)
+	}
+}
+
+func removeAPIKeyHandler(db *sql.DB, keys *apikey.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := apikey.Remove(db, id); err != nil {
+			http.Error(w, "Error removing API key", http.StatusInternalServerError)
+			return
+		}
+		if err := keys.Reload(); err != nil {
+			log.Printf("Error reloading API keys: %v", err)
+		}
+
+		if isPartial(r) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Redirect(w, r, "/dashboard/api-keys", http.StatusSeeOther)
+	}
+}
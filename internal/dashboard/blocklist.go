@@ -0,0 +1,78 @@
+package dashboard
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/blocklist"
+	"webring/internal/render"
+
+	"github.com/gorilla/mux"
+)
+
+func blocklistHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		entries, err := blocklist.List(db)
+		if err != nil {
+			log.Printf("Error fetching blocklist: %v", err)
+			http.Error(w, "Error fetching blocklist", http.StatusInternalServerError)
+			return
+		}
+
+		render.HTML(w, http.StatusOK, t, "blocklist.html", newPage(db, entries))
+	}
+}
+
+func addBlocklistEntryHandler(db *sql.DB, bl *blocklist.Blocklist) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		kind := r.FormValue("kind")
+		value := r.FormValue("value")
+		reason := r.FormValue("reason")
+
+		if err := blocklist.Add(db, kind, value, reason); err != nil {
+			http.Error(w, "Error adding blocklist entry: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := bl.Reload(); err != nil {
+			log.Printf("Error reloading blocklist: %v", err)
+		}
+
+		http.Redirect(w, r, "/dashboard/blocklist", http.StatusSeeOther)
+	}
+}
+
+func removeBlocklistEntryHandler(db *sql.DB, bl *blocklist.Blocklist) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := blocklist.Remove(db, id); err != nil {
+			http.Error(w, "Error removing blocklist entry", http.StatusInternalServerError)
+			return
+		}
+		if err := bl.Reload(); err != nil {
+			log.Printf("Error reloading blocklist: %v", err)
+		}
+
+		if isPartial(r) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Redirect(w, r, "/dashboard/blocklist", http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,61 @@
+package dashboard
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/models"
+	"webring/internal/render"
+	"webring/internal/uptime"
+)
+
+type checkErrorsData struct {
+	Errors     []uptime.CheckError
+	Sites      []models.Site
+	Categories []string
+	SiteID     int
+	Category   string
+}
+
+func checkErrorsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		// Unknown or empty filters fall back to showing everything
+		siteID, _ := strconv.Atoi(r.URL.Query().Get("site"))
+		category := r.URL.Query().Get("category")
+
+		errs, err := uptime.ListErrors(db, siteID, category)
+		if err != nil {
+			log.Printf("Error fetching check errors: %v", err)
+			http.Error(w, "Error fetching check errors", http.StatusInternalServerError)
+			return
+		}
+
+		sites, err := getAllSites(db)
+		if err != nil {
+			log.Printf("Error fetching sites: %v", err)
+			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+			return
+		}
+
+		data := checkErrorsData{
+			Errors:     errs,
+			Sites:      sites,
+			Categories: uptime.ErrorCategories,
+			SiteID:     siteID,
+			Category:   category,
+		}
+		render.HTML(w, http.StatusOK, t, "errors.html", newPage(db, data))
+	}
+}
@@ -0,0 +1,97 @@
+package dashboard
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/audit"
+	"webring/internal/duplicates"
+	"webring/internal/render"
+	"webring/internal/ring"
+)
+
+func duplicatesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		pairs, err := duplicates.List(db)
+		if err != nil {
+			log.Printf("Error fetching duplicate sites: %v", err)
+			http.Error(w, "Error fetching duplicate sites", http.StatusInternalServerError)
+			return
+		}
+
+		render.HTML(w, http.StatusOK, t, "duplicates.html", newPage(db, pairs))
+	}
+}
+
+// pairValues reads the two site IDs posted by the duplicates page.
+func pairValues(r *http.Request, first, second string) (int, int, bool) {
+	a, err := strconv.Atoi(r.FormValue(first))
+	if err != nil {
+		return 0, 0, false
+	}
+	b, err := strconv.Atoi(r.FormValue(second))
+	if err != nil {
+		return 0, 0, false
+	}
+	return a, b, true
+}
+
+// mergeDuplicateHandler removes a duplicate in favor of the entry to keep,
+// which takes over its history.
+func mergeDuplicateHandler(db *sql.DB, rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keep, remove, ok := pairValues(r, "keep", "remove")
+		if !ok {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+
+		var kept string
+		if err := db.QueryRow("SELECT name FROM sites WHERE id = $1", keep).Scan(&kept); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error fetching site %d: %v", keep, err)
+		}
+		name, url, err := duplicates.Merge(db, keep, remove)
+		if errors.Is(err, duplicates.ErrNotFound) {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error merging site %d into %d: %v", remove, keep, err)
+			http.Error(w, "Error merging sites", http.StatusInternalServerError)
+			return
+		}
+		rc.Invalidate()
+		audit.Record(db, audit.Entry{SiteID: &remove, SiteName: name, Action: audit.ActionMerged, OldValue: url, NewValue: kept})
+
+		http.Redirect(w, r, "/dashboard/duplicates", http.StatusSeeOther)
+	}
+}
+
+func dismissDuplicateHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a, b, ok := pairValues(r, "site", "other")
+		if !ok {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+		if err := duplicates.Dismiss(db, a, b); err != nil {
+			log.Printf("Error dismissing duplicate %d/%d: %v", a, b, err)
+			http.Error(w, "Error dismissing duplicate", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/dashboard/duplicates", http.StatusSeeOther)
+	}
+}
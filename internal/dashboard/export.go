@@ -0,0 +1,176 @@
+package dashboard
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webring/internal/uptime"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// defaultExportDays is the range exported when from is not given.
+	defaultExportDays = 30
+	// exportFlushRows is how many rows are written between flushes.
+	exportFlushRows = 1000
+)
+
+// exportTime reads a from or to parameter, either a date or an RFC 3339
+// time. A date in to includes that whole day.
+func exportTime(value string, end bool) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// exportUptimeHandler streams the uptime history of a site between ?from= and
+// ?to= as CSV or, with ?format=json, as a JSON array, for owners who need to
+// show their hosting provider what happened.
+func exportUptimeHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+
+		query := r.URL.Query()
+		to := time.Now()
+		if value := query.Get("to"); value != "" {
+			if to, err = exportTime(value, true); err != nil {
+				http.Error(w, "Invalid to, expected YYYY-MM-DD or an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+		}
+		from := to.AddDate(0, 0, -defaultExportDays)
+		if value := query.Get("from"); value != "" {
+			if from, err = exportTime(value, false); err != nil {
+				http.Error(w, "Invalid from, expected YYYY-MM-DD or an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+		}
+		if !from.Before(to) {
+			http.Error(w, "from must be before to", http.StatusBadRequest)
+			return
+		}
+
+		format := query.Get("format")
+		if format == "" {
+			format = "csv"
+		}
+		if format != "csv" && format != "json" {
+			http.Error(w, "Invalid format, expected csv or json", http.StatusBadRequest)
+			return
+		}
+
+		var name string
+		err = db.QueryRow("SELECT name FROM sites WHERE id = $1", id).Scan(&name)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error fetching site %d: %v", id, err)
+			http.Error(w, "Error fetching site", http.StatusInternalServerError)
+			return
+		}
+
+		filename := fmt.Sprintf("site-%d-uptime-from-%s.%s", id, from.Format(time.DateOnly), format)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		if format == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			err = exportJSON(w, db, id, from, to)
+		} else {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			err = exportCSV(w, db, id, from, to)
+		}
+		// The status has been sent with the first row; all that is left is
+		// to log the truncated export
+		if err != nil {
+			log.Printf("Error exporting uptime of site %d: %v", id, err)
+		}
+	}
+}
+
+func exportCSV(w http.ResponseWriter, db *sql.DB, siteID int, from, to time.Time) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"start", "resolution", "checks", "up_checks", "uptime_percent", "avg_response_time_ms"}); err != nil {
+		return err
+	}
+	n := 0
+	err := uptime.ExportHistory(db, siteID, from, to, func(row uptime.HistoryRow) error {
+		uptimePercent := 0.0
+		if row.Checks > 0 {
+			uptimePercent = float64(row.UpChecks) * 100 / float64(row.Checks)
+		}
+		if err := cw.Write([]string{
+			row.Start.Format(time.RFC3339),
+			row.Resolution,
+			strconv.Itoa(row.Checks),
+			strconv.Itoa(row.UpChecks),
+			strconv.FormatFloat(uptimePercent, 'f', 2, 64),
+			strconv.FormatFloat(row.ResponseTime*1000, 'f', 0, 64),
+		}); err != nil {
+			return err
+		}
+		if n++; n%exportFlushRows == 0 {
+			flush(w, cw)
+		}
+		return nil
+	})
+	cw.Flush()
+	if err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func exportJSON(w http.ResponseWriter, db *sql.DB, siteID int, from, to time.Time) error {
+	if _, err := w.Write([]byte("[")); err != nil {
+		return err
+	}
+	n := 0
+	err := uptime.ExportHistory(db, siteID, from, to, func(row uptime.HistoryRow) error {
+		data, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			data = append([]byte(",\n"), data...)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if n++; n%exportFlushRows == 0 {
+			flush(w, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte("]\n"))
+	return err
+}
+
+// flush sends what has been written so far to the client.
+func flush(w http.ResponseWriter, cw *csv.Writer) {
+	if cw != nil {
+		cw.Flush()
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
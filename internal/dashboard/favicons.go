@@ -0,0 +1,63 @@
+package dashboard
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"webring/internal/favicon"
+	"webring/internal/render"
+	"webring/internal/ring"
+)
+
+type faviconsPage struct {
+	Rebuild favicon.Progress
+	GC      favicon.GCResult
+}
+
+func faviconsHandler(db *sql.DB, rb *favicon.Rebuilder, gc *favicon.GarbageCollector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		render.HTML(w, http.StatusOK, t, "favicons.html", newPage(db, faviconsPage{rb.Progress(), gc.Last()}))
+	}
+}
+
+func faviconsStatusHandler(rb *favicon.Rebuilder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(rb.Progress())
+		if err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		}
+	}
+}
+
+func collectMediaGarbageHandler(gc *favicon.GarbageCollector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		gc.Run()
+		http.Redirect(w, r, "/dashboard/favicons", http.StatusSeeOther)
+	}
+}
+
+func rebuildFaviconsHandler(rb *favicon.Rebuilder, rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := rb.Start(rc.Invalidate)
+		if err != nil && !errors.Is(err, favicon.ErrRebuildRunning) {
+			http.Error(w, "Error starting favicon rebuild", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/dashboard/favicons", http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,74 @@
+package dashboard
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/guestbook"
+	"webring/internal/render"
+
+	"github.com/gorilla/mux"
+)
+
+// guestbookHandler shows the entries waiting for approval, then the
+// published ones.
+func guestbookHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		entries, err := guestbook.List(db)
+		if err != nil {
+			log.Printf("Error fetching guestbook entries: %v", err)
+			http.Error(w, "Error fetching guestbook entries", http.StatusInternalServerError)
+			return
+		}
+
+		render.HTML(w, http.StatusOK, t, "guestbook-entries.html", newPage(db, entries))
+	}
+}
+
+func approveGuestbookEntryHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := guestbook.Approve(db, id); err != nil {
+			log.Printf("Error approving guestbook entry %d: %v", id, err)
+			http.Error(w, "Error approving guestbook entry", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/dashboard/guestbook", http.StatusSeeOther)
+	}
+}
+
+func removeGuestbookEntryHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := guestbook.Remove(db, id); err != nil {
+			log.Printf("Error removing guestbook entry %d: %v", id, err)
+			http.Error(w, "Error removing guestbook entry", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/dashboard/guestbook", http.StatusSeeOther)
+	}
+}
@@ -1,7 +1,10 @@
 package dashboard
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"html/template"
 	"log"
 	"math"
@@ -9,7 +12,23 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"time"
+	"webring/internal/api/middleware"
+	"webring/internal/apikey"
+	"webring/internal/archive"
+	"webring/internal/audit"
+	"webring/internal/blocklist"
+	"webring/internal/database"
 	"webring/internal/favicon"
+	"webring/internal/monitor"
+	"webring/internal/outbox"
+	"webring/internal/ratelimit"
+	"webring/internal/render"
+	"webring/internal/ring"
+	"webring/internal/settings"
+	"webring/internal/slo"
+	"webring/internal/uptime"
+	"webring/internal/validate"
 
 	"webring/internal/models"
 
@@ -27,29 +46,120 @@ func InitTemplates(t *template.Template) {
 	templates = t
 }
 
-func RegisterHandlers(r *mux.Router, db *sql.DB) {
+func RegisterHandlers(r *mux.Router, db *sql.DB, bl *blocklist.Blocklist, st *settings.Store, rc *ring.Cache, rb *favicon.Rebuilder, gc *favicon.GarbageCollector, guard *ratelimit.AuthGuard, keys *apikey.Store) {
 	dashboardRouter := r.PathPrefix("/dashboard").Subrouter()
-	dashboardRouter.Use(basicAuthMiddleware)
+	dashboardRouter.Use(basicAuthMiddleware(guard))
 
-	dashboardRouter.HandleFunc("", dashboardHandler(db)).Methods("GET")
-	dashboardRouter.HandleFunc("/add", addSiteHandler(db)).Methods("POST")
-	dashboardRouter.HandleFunc("/remove/{id}", removeSiteHandler(db)).Methods("POST")
-	dashboardRouter.HandleFunc("/update/{id}", updateSiteHandler(db)).Methods("POST")
+	dashboardRouter.HandleFunc("", dashboardHandler(db, st)).Methods("GET")
+	dashboardRouter.HandleFunc("/add", jsonBody(addSiteHandler(db, st, rc))).Methods("POST")
+	dashboardRouter.HandleFunc("/remove/{id}", removeSiteHandler(db, rc)).Methods("POST")
+	dashboardRouter.HandleFunc("/update/{id}", jsonBody(updateSiteHandler(db, st, rc))).Methods("POST")
+	dashboardRouter.HandleFunc("/preview", previewSiteHandler(db, st, rc)).Methods("POST")
+	dashboardRouter.HandleFunc("/preview/{id}", previewSiteHandler(db, st, rc)).Methods("POST")
+	dashboardRouter.HandleFunc("/sites/{id}/uptime/export", exportUptimeHandler(db)).Methods("GET")
+
+	dashboardRouter.HandleFunc("/reorder", reorderHandler(db, st)).Methods("GET")
+	dashboardRouter.HandleFunc("/reorder", applyReorderHandler(db, st, rc)).Methods("POST")
+	dashboardRouter.HandleFunc("/reorder/preview", previewReorderHandler(db, st)).Methods("POST")
+	dashboardRouter.HandleFunc("/reorder/undo", undoReorderHandler(db, rc)).Methods("POST")
+
+	dashboardRouter.HandleFunc("/reports", reportsHandler(db)).Methods("GET")
+	dashboardRouter.HandleFunc("/reports/resolve/{id}", resolveReportHandler(db)).Methods("POST")
+
+	dashboardRouter.HandleFunc("/guestbook", guestbookHandler(db)).Methods("GET")
+	dashboardRouter.HandleFunc("/guestbook/approve/{id}", approveGuestbookEntryHandler(db)).Methods("POST")
+	dashboardRouter.HandleFunc("/guestbook/remove/{id}", removeGuestbookEntryHandler(db)).Methods("POST")
+
+	dashboardRouter.HandleFunc("/duplicates", duplicatesHandler(db)).Methods("GET")
+	dashboardRouter.HandleFunc("/duplicates/merge", mergeDuplicateHandler(db, rc)).Methods("POST")
+	dashboardRouter.HandleFunc("/duplicates/dismiss", dismissDuplicateHandler(db)).Methods("POST")
+
+	dashboardRouter.HandleFunc("/errors", checkErrorsHandler(db)).Methods("GET")
+	dashboardRouter.HandleFunc("/visitors", visitorsHandler(db, st)).Methods("GET")
+	dashboardRouter.HandleFunc("/analytics", analyticsHandler(db)).Methods("GET")
+	dashboardRouter.HandleFunc("/analytics.json", analyticsJSONHandler(db)).Methods("GET")
+
+	dashboardRouter.HandleFunc("/favicons", faviconsHandler(db, rb, gc)).Methods("GET")
+	dashboardRouter.HandleFunc("/favicons/status", faviconsStatusHandler(rb)).Methods("GET")
+	dashboardRouter.HandleFunc("/favicons/rebuild", rebuildFaviconsHandler(rb, rc)).Methods("POST")
+	dashboardRouter.HandleFunc("/favicons/gc", collectMediaGarbageHandler(gc)).Methods("POST")
+
+	dashboardRouter.HandleFunc("/blocklist", blocklistHandler(db)).Methods("GET")
+	dashboardRouter.HandleFunc("/blocklist/add", addBlocklistEntryHandler(db, bl)).Methods("POST")
+	dashboardRouter.HandleFunc("/blocklist/remove/{id}", removeBlocklistEntryHandler(db, bl)).Methods("POST")
+
+	dashboardRouter.HandleFunc("/api-keys", apiKeysHandler(db)).Methods("GET")
+	dashboardRouter.HandleFunc("/api-keys/add", addAPIKeyHandler(db, keys)).Methods("POST")
+	dashboardRouter.HandleFunc("/api-keys/remove/{id}", removeAPIKeyHandler(db, keys)).Methods("POST")
+
+	dashboardRouter.HandleFunc("/settings", settingsHandler(db, st)).Methods("GET")
+	dashboardRouter.HandleFunc("/settings", updateSettingsHandler(db, st, rc)).Methods("POST")
+	dashboardRouter.HandleFunc("/settings/preview", previewMarkdownHandler()).Methods("POST")
+
+	dashboardRouter.HandleFunc("/metrics/http", httpClientMetricsHandler()).Methods("GET")
+
+	// JSON API for provisioning the ring from scripts and infrastructure
+	// tools, behind the dashboard credentials
+	adminAPIRouter := r.PathPrefix("/api/v1/admin").Subrouter()
+	adminAPIRouter.Use(basicAuthMiddleware(guard))
+	adminAPIRouter.HandleFunc("/sites/{id:[0-9]+}", getSiteAPIHandler(db)).Methods("GET")
+	adminAPIRouter.HandleFunc("/sites/{id:[0-9]+}", putSiteAPIHandler(db, st, rc)).Methods("PUT")
+	adminAPIRouter.HandleFunc("/sites:batch", batchSitesHandler(db, st, rc)).Methods("POST")
+	adminAPIRouter.HandleFunc("/sites/{id:[0-9]+}/hiatus", putHiatusAPIHandler(db, rc)).Methods("PUT")
+
+	// Quick actions behind the dashboard's keyboard shortcuts
+	adminAPIRouter.HandleFunc("/quick/approve", quickApproveHandler(db)).Methods("POST")
+	adminAPIRouter.HandleFunc("/quick/jump", quickJumpHandler(db)).Methods("GET")
+	adminAPIRouter.HandleFunc("/quick/sites/{id:[0-9]+}/maintenance", quickMaintenanceHandler(db, rc)).Methods("POST")
 }
 
-func basicAuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, pass, ok := r.BasicAuth()
-		if !ok || user != os.Getenv("DASHBOARD_USER") || pass != os.Getenv("DASHBOARD_PASSWORD") {
-			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
+func basicAuthMiddleware(guard *ratelimit.AuthGuard) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if archive.Enabled() {
+				http.Error(w, "The webring is archived, the dashboard is closed.", http.StatusForbidden)
+				return
+			}
+			if remaining := guard.BlockedFor(r); remaining > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(remaining.Seconds()))))
+				http.Error(w, "Too many failed login attempts", http.StatusTooManyRequests)
+				return
+			}
+
+			user, pass, ok := r.BasicAuth()
+			if !ok || user != os.Getenv("DASHBOARD_USER") || pass != os.Getenv("DASHBOARD_PASSWORD") {
+				// Browsers first request without credentials; only count real attempts
+				if ok {
+					guard.Fail(r, user)
+				}
+				w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			guard.Succeed(r)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// sitesPage is the data of the main dashboard page.
+type sitesPage struct {
+	Sites []siteRow
+	// Base is the public URL of the ring, see siteRow.
+	Base string
+	// SLO is nil until the checker has completed a round.
+	SLO *slo.Status
+}
+
+// siteRow is a member as shown on the dashboard. Its links to the public
+// pages start with Base, since the dashboard may be served on ADMIN_PORT or
+// ADMIN_HOST where they don't exist.
+type siteRow struct {
+	models.Site
+	Base string
 }
 
-func dashboardHandler(db *sql.DB) http.HandlerFunc {
+func dashboardHandler(db *sql.DB, st *settings.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		templatesMu.RLock()
 		t := templates
@@ -68,116 +178,357 @@ func dashboardHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		err = t.ExecuteTemplate(w, "dashboard.html", sites)
+		status, err := slo.Current(db, st)
 		if err != nil {
-			log.Printf("Error rendering template: %v", err)
-			http.Error(w, "Error rendering template", http.StatusInternalServerError)
+			// The page is still useful without it
+			log.Printf("Error computing the uptime objective: %v", err)
 		}
+
+		base := middleware.BaseURL(r)
+		rows := make([]siteRow, len(sites))
+		for i, site := range sites {
+			rows[i] = siteRow{site, base}
+		}
+		render.HTML(w, http.StatusOK, t, "dashboard.html", newPage(db, sitesPage{Sites: rows, Base: base, SLO: status}))
 	}
 }
 
-func addSiteHandler(db *sql.DB) http.HandlerFunc {
+func addSiteHandler(db *sql.DB, st *settings.Store, rc *ring.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := r.FormValue("id")
 		name := r.FormValue("name")
 		url := r.FormValue("url")
+		notes := r.FormValue("notes")
+		webhookURL := r.FormValue("webhook_url")
+		checkType, checkTarget, ok := checkSettings(r)
+		if !ok {
+			httpError(w, r, "Invalid check type", http.StatusBadRequest)
+			return
+		}
+		displayOrder, ok := displayOrderValue(r)
+		if !ok {
+			httpError(w, r, "Invalid order", http.StatusBadRequest)
+			return
+		}
 
 		if idStr == "" || name == "" || url == "" {
-			http.Error(w, "ID, Name, and URL are required", http.StatusBadRequest)
+			httpError(w, r, "ID, Name, and URL are required", http.StatusBadRequest)
 			return
 		}
 
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
-			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			httpError(w, r, "Invalid ID", http.StatusBadRequest)
 			return
 		}
 
-		result, err := db.Exec("INSERT INTO sites (id, name, url) VALUES ($1, $2, $3)", id, name, url)
+		name, err = validate.SiteName(name, st.Bool(settings.StripEmojiInNames))
 		if err != nil {
-			http.Error(w, "Error adding site", http.StatusInternalServerError)
+			httpError(w, r, "Invalid name: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-		insertedID, _ := result.LastInsertId()
 
-		// Start a goroutine to fetch and store the favicon
-		go func() {
-			mediaFolder := os.Getenv("MEDIA_FOLDER")
-			if mediaFolder == "" {
-				mediaFolder = "media"
-			}
+		url, err = validate.SiteURL(url, urlPolicy(st))
+		if err != nil {
+			httpError(w, r, "Invalid URL: "+err.Error(), http.StatusBadRequest)
+			return
+		}
 
-			faviconPath, err := favicon.GetAndStoreFavicon(url, mediaFolder, int(insertedID))
-			if err != nil {
-				log.Printf("Error retrieving favicon for %s: %v", url, err)
-				return
-			}
+		supportURL, err := validate.SupportURL(r.FormValue("support_url"))
+		if err != nil {
+			httpError(w, r, "Invalid support URL: "+err.Error(), http.StatusBadRequest)
+			return
+		}
 
-			_, err = db.Exec("UPDATE sites SET favicon = $1 WHERE id = $2", faviconPath, insertedID)
+		monitorURL, monitorKind, err := monitorSettings(r)
+		if err != nil {
+			httpError(w, r, "Invalid monitor: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = database.InTx(db, func(tx *sql.Tx) error {
+			_, err := tx.Exec("INSERT INTO sites (id, name, url, notes, webhook_url, webhook_secret, check_type, check_target, display_order, support_url, monitor_url, monitor_kind) VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, NULLIF($10, ''), NULLIF($11, ''), $12)",
+				id, name, url, notes, webhookURL, newWebhookSecret(webhookURL), checkType, checkTarget, displayOrder, supportURL, monitorURL, monitorKind)
 			if err != nil {
-				log.Printf("Error updating favicon for site %d: %v", insertedID, err)
+				return err
 			}
-		}()
+			// The favicon is fetched once the site is committed
+			return outbox.Enqueue(tx, outbox.EventSiteChanged, outbox.SiteChanged{SiteID: id, URL: url, RefreshFavicon: true})
+		})
+		if err != nil {
+			httpError(w, r, "Error adding site", http.StatusInternalServerError)
+			return
+		}
+		rc.Invalidate()
+		audit.Record(db, audit.Entry{SiteID: &id, SiteName: name, Action: audit.ActionAdded, NewValue: url})
 
+		if isJSON(r) {
+			writeSite(w, r, db, id, http.StatusCreated)
+			return
+		}
 		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
 	}
 }
 
-func removeSiteHandler(db *sql.DB) http.HandlerFunc {
+func removeSiteHandler(db *sql.DB, rc *ring.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id := mux.Vars(r)["id"]
-		_, err := db.Exec("DELETE FROM sites WHERE id = $1", id)
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
 		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+
+		var name, url string
+		err = database.InTx(db, func(tx *sql.Tx) error {
+			if err := tx.QueryRow("DELETE FROM sites WHERE id = $1 RETURNING name, url", id).Scan(&name, &url); err != nil {
+				return err
+			}
+			return outbox.Enqueue(tx, outbox.EventSiteChanged, outbox.SiteChanged{SiteID: id, URL: url, Removed: true})
+		})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
 			http.Error(w, "Error removing site", http.StatusInternalServerError)
 			return
 		}
+		rc.Invalidate()
+		if err == nil {
+			audit.Record(db, audit.Entry{SiteID: &id, SiteName: name, Action: audit.ActionRemoved, OldValue: url})
+		}
 
+		if isPartial(r) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
 		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
 	}
 }
 
-func updateSiteHandler(db *sql.DB) http.HandlerFunc {
+func updateSiteHandler(db *sql.DB, st *settings.Store, rc *ring.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := mux.Vars(r)["id"]
 		name := r.FormValue("name")
 		url := r.FormValue("url")
+		notes := r.FormValue("notes")
+		webhookURL := r.FormValue("webhook_url")
+		checkType, checkTarget, ok := checkSettings(r)
+		if !ok {
+			httpError(w, r, "Invalid check type", http.StatusBadRequest)
+			return
+		}
+		displayOrder, ok := displayOrderValue(r)
+		if !ok {
+			httpError(w, r, "Invalid order", http.StatusBadRequest)
+			return
+		}
 
 		if name == "" || url == "" {
-			http.Error(w, "Name and URL are required", http.StatusBadRequest)
+			httpError(w, r, "Name and URL are required", http.StatusBadRequest)
 			return
 		}
 
-		_, err := db.Exec("UPDATE sites SET name = $1, url = $2 WHERE id = $3", name, url, id)
+		name, err := validate.SiteName(name, st.Bool(settings.StripEmojiInNames))
 		if err != nil {
-			http.Error(w, "Error updating site", http.StatusInternalServerError)
+			httpError(w, r, "Invalid name: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		go func() {
-			mediaFolder := os.Getenv("MEDIA_FOLDER")
-			if mediaFolder == "" {
-				mediaFolder = "media"
-			}
+		url, err = validate.SiteURL(url, urlPolicy(st))
+		if err != nil {
+			httpError(w, r, "Invalid URL: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		supportURL, err := validate.SupportURL(r.FormValue("support_url"))
+		if err != nil {
+			httpError(w, r, "Invalid support URL: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		monitorURL, monitorKind, err := monitorSettings(r)
+		if err != nil {
+			httpError(w, r, "Invalid monitor: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		maintenanceStart, maintenanceEnd, err := maintenanceWindow(r)
+		if err != nil {
+			httpError(w, r, "Invalid maintenance window: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Unchecked checkboxes are not submitted; JSON clients send false
+		keepInRing := r.FormValue("maintenance_keep_in_ring") != "" && r.FormValue("maintenance_keep_in_ring") != "false"
+
+		siteID, _ := strconv.Atoi(id)
+		old, err := getSite(db, siteID)
+		if errors.Is(err, sql.ErrNoRows) {
+			httpError(w, r, "Site not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error fetching site: %v", err)
+			httpError(w, r, "Error fetching site", http.StatusInternalServerError)
+			return
+		}
 
-			siteId, _ := strconv.Atoi(id)
-			faviconPath, err := favicon.GetAndStoreFavicon(url, mediaFolder, siteId)
+		err = database.InTx(db, func(tx *sql.Tx) error {
+			// Keep the existing webhook secret so owners don't have to reconfigure
+			// their receivers every time the site is edited.
+			_, err := tx.Exec(`
+				UPDATE sites
+				SET name = $1, url = $2, notes = $3,
+				    webhook_url = NULLIF($4, ''),
+				    webhook_secret = CASE WHEN $4 = '' THEN NULL ELSE COALESCE(webhook_secret, $5) END,
+				    check_type = $6, check_target = $7, display_order = $8,
+				    support_url = NULLIF($9, ''),
+				    maintenance_start = $10, maintenance_end = $11, maintenance_keep_in_ring = $12,
+				    monitor_url = NULLIF($13, ''), monitor_kind = $14
+				WHERE id = $15`, name, url, notes, webhookURL, newWebhookSecret(webhookURL), checkType, checkTarget, displayOrder, supportURL,
+				maintenanceStart, maintenanceEnd, keepInRing, monitorURL, monitorKind, id)
 			if err != nil {
-				log.Printf("Error retrieving favicon for %s: %v", url, err)
-				return
+				return err
 			}
+			return outbox.Enqueue(tx, outbox.EventSiteChanged, outbox.SiteChanged{SiteID: siteID, URL: url, RefreshFavicon: true})
+		})
+		if err != nil {
+			httpError(w, r, "Error updating site", http.StatusInternalServerError)
+			return
+		}
+		rc.Invalidate()
+		audit.Record(db, audit.SiteChanges(old, models.Site{ID: siteID, Name: name, URL: url, DisplayOrder: displayOrder})...)
 
-			_, err = db.Exec("UPDATE sites SET favicon = $1 WHERE id = $2", faviconPath, id)
+		if isJSON(r) {
+			writeSite(w, r, db, siteID, http.StatusOK)
+			return
+		}
+		if isPartial(r) {
+			site, err := getSite(db, siteID)
 			if err != nil {
-				log.Printf("Error updating favicon for site %d: %v", id, err)
+				log.Printf("Error fetching site: %v", err)
+				http.Error(w, "Error fetching site", http.StatusInternalServerError)
+				return
 			}
-		}()
-
+			renderFragment(w, "site-row", siteRow{site, middleware.BaseURL(r)})
+			return
+		}
 		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
 	}
 }
 
+// writeSite answers a JSON request with the stored site.
+func writeSite(w http.ResponseWriter, r *http.Request, db *sql.DB, id int, code int) {
+	site, err := getSite(db, id)
+	if err != nil {
+		log.Printf("Error fetching site: %v", err)
+		httpError(w, r, "Error fetching site", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, code, site)
+}
+
+func urlPolicy(st *settings.Store) validate.URLPolicy {
+	return validate.URLPolicy{
+		AllowedSchemes: validate.ParseList(st.Get(settings.AllowedURLSchemes)),
+		AllowOverlay:   st.Bool(settings.AllowOverlayNetworks),
+	}
+}
+
+// checkSettings reads the uptime check type and its target (port or keyword)
+// from the form, defaulting to an HTTP check.
+func checkSettings(r *http.Request) (string, string, bool) {
+	checkType := r.FormValue("check_type")
+	if checkType == "" {
+		checkType = uptime.CheckHTTP
+	}
+	return checkType, r.FormValue("check_target"), uptime.ValidCheckType(checkType)
+}
+
+// monitorSettings reads the site's external monitor and its kind from the
+// form, defaulting to a webhook.
+func monitorSettings(r *http.Request) (string, string, error) {
+	kind := r.FormValue("monitor_kind")
+	if kind == "" {
+		kind = monitor.KindWebhook
+	}
+	if !monitor.ValidKind(kind) {
+		return "", "", errors.New("unknown kind " + kind)
+	}
+	monitorURL, err := validate.SupportURL(r.FormValue("monitor_url"))
+	return monitorURL, kind, err
+}
+
+// displayOrderValue reads the optional manual ring position from the form.
+func displayOrderValue(r *http.Request) (*int, bool) {
+	value := r.FormValue("display_order")
+	if value == "" {
+		return nil, true
+	}
+	order, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, false
+	}
+	return &order, true
+}
+
+// maintenanceInputLayout is the format of datetime-local inputs.
+const maintenanceInputLayout = "2006-01-02T15:04"
+
+// maintenanceWindow reads the optional maintenance window from the form. Both
+// ends are required to schedule one; leaving both empty clears it.
+func maintenanceWindow(r *http.Request) (*time.Time, *time.Time, error) {
+	startValue := r.FormValue("maintenance_start")
+	endValue := r.FormValue("maintenance_end")
+	if startValue == "" && endValue == "" {
+		return nil, nil, nil
+	}
+	if startValue == "" || endValue == "" {
+		return nil, nil, errors.New("both start and end are required")
+	}
+	start, err := time.ParseInLocation(maintenanceInputLayout, startValue, time.Local)
+	if err != nil {
+		return nil, nil, errors.New("invalid start")
+	}
+	end, err := time.ParseInLocation(maintenanceInputLayout, endValue, time.Local)
+	if err != nil {
+		return nil, nil, errors.New("invalid end")
+	}
+	if !end.After(start) {
+		return nil, nil, errors.New("end must be after start")
+	}
+	return &start, &end, nil
+}
+
+// newWebhookSecret generates the HMAC secret for a site webhook, or returns
+// nil when no webhook is configured.
+func newWebhookSecret(webhookURL string) *string {
+	if webhookURL == "" {
+		return nil
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Error generating webhook secret: %v", err)
+		return nil
+	}
+	secret := hex.EncodeToString(b)
+	return &secret
+}
+
+const siteColumns = "id, name, url, is_up, last_check, favicon, notes, webhook_url, webhook_secret, check_type, check_target, display_order, support_url, https_issue, https_issue_since, " +
+	"maintenance_start, maintenance_end, maintenance_keep_in_ring, (maintenance_start <= NOW() AND NOW() < maintenance_end) IS TRUE, monitor_url, monitor_kind, hiatus_until, description, feed_url"
+
+// scanSite reads a row selected with siteColumns.
+func scanSite(row interface{ Scan(...interface{}) error }) (models.Site, error) {
+	var site models.Site
+	err := row.Scan(&site.ID, &site.Name, &site.URL, &site.IsUp, &site.LastCheck, &site.Favicon, &site.Notes, &site.WebhookURL, &site.WebhookSecret, &site.CheckType, &site.CheckTarget, &site.DisplayOrder, &site.SupportURL, &site.HTTPSIssue, &site.HTTPSIssueSince,
+		&site.MaintenanceStart, &site.MaintenanceEnd, &site.MaintenanceKeepInRing, &site.InMaintenance, &site.MonitorURL, &site.MonitorKind, &site.HiatusUntil, &site.Description, &site.FeedURL)
+	site.LastCheck = math.Round(site.LastCheck * 1000)
+	return site, err
+}
+
+func getSite(db *sql.DB, id int) (models.Site, error) {
+	return scanSite(db.QueryRow("SELECT "+siteColumns+" FROM sites WHERE id = $1", id))
+}
+
 func getAllSites(db *sql.DB) ([]models.Site, error) {
-	rows, err := db.Query("SELECT id, name, url, is_up, last_check, favicon FROM sites ORDER BY id")
+	rows, err := db.Query("SELECT " + siteColumns + " FROM sites ORDER BY id")
 	if err != nil {
 		return nil, err
 	}
@@ -190,12 +541,10 @@ func getAllSites(db *sql.DB) ([]models.Site, error) {
 
 	var sites []models.Site
 	for rows.Next() {
-		var site models.Site
-		err := rows.Scan(&site.ID, &site.Name, &site.URL, &site.IsUp, &site.LastCheck, &site.Favicon)
+		site, err := scanSite(rows)
 		if err != nil {
 			return nil, err
 		}
-		site.LastCheck = math.Round(site.LastCheck * 1000)
 		sites = append(sites, site)
 	}
 	return sites, nil
@@ -0,0 +1,88 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// maxJSONBody limits the size of JSON request bodies.
+const maxJSONBody = 1 << 20
+
+// isJSON reports whether the request was sent with a JSON body. The response
+// is JSON as well then.
+func isJSON(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// jsonBody lets a form handler accept a JSON object as well: its fields are
+// copied into the form, so the handler reads both the same way. Other body
+// types than forms and JSON are rejected.
+func jsonBody(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "", "application/x-www-form-urlencoded", "multipart/form-data":
+		case "application/json":
+			if err := parseJSONForm(r); err != nil {
+				httpError(w, r, "Invalid JSON body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Accept", "application/x-www-form-urlencoded, multipart/form-data, application/json")
+			http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func parseJSONForm(r *http.Request) error {
+	var body map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxJSONBody)).Decode(&body); err != nil {
+		return err
+	}
+
+	form := make(url.Values, len(body))
+	for key, value := range body {
+		switch v := value.(type) {
+		case nil:
+			form.Set(key, "")
+		case string:
+			form.Set(key, v)
+		case float64:
+			form.Set(key, strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			form.Set(key, strconv.FormatBool(v))
+		default:
+			return fmt.Errorf("%s must be a string, number, boolean or null", key)
+		}
+	}
+	// With both set, FormValue does not try to parse the body again
+	r.Form, r.PostForm = form, form
+	return nil
+}
+
+// httpError writes an error as {"error": "..."} for JSON requests and as
+// plain text otherwise.
+func httpError(w http.ResponseWriter, r *http.Request, message string, code int) {
+	if !isJSON(r) {
+		http.Error(w, message, code)
+		return
+	}
+	writeJSON(w, code, map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"webring/internal/httpclient"
+)
+
+// httpClientMetricsHandler reports request counts, errors and latency of the
+// outgoing HTTP clients (uptime, favicon, notify, wayback).
+func httpClientMetricsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(httpclient.Stats()); err != nil {
+			log.Printf("Error encoding response: %v", err)
+		}
+	}
+}
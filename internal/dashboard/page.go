@@ -0,0 +1,59 @@
+package dashboard
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"webring/internal/render"
+)
+
+// header is rendered by the shared "header" template on every dashboard page.
+type header struct {
+	DownSites      int
+	OpenReports    int
+	PendingEntries int
+	Duplicates     int
+}
+
+// page wraps the data of a dashboard template together with the header.
+type page struct {
+	Header header
+	Data   interface{}
+}
+
+func newPage(db *sql.DB, data interface{}) page {
+	var h header
+	// A missing count should not take the page down with it
+	err := db.QueryRow(`
+        SELECT (SELECT COUNT(*) FROM sites WHERE is_up = false),
+               (SELECT COUNT(*) FROM reports WHERE resolved_at IS NULL),
+               (SELECT COUNT(*) FROM guestbook_entries WHERE approved_at IS NULL),
+               (SELECT COUNT(*) FROM site_duplicates WHERE NOT dismissed)
+    `).Scan(&h.DownSites, &h.OpenReports, &h.PendingEntries, &h.Duplicates)
+	if err != nil {
+		log.Printf("Error counting dashboard work items: %v", err)
+	}
+	return page{Header: h, Data: data}
+}
+
+// isPartial reports whether the request comes from dashboard.js, which wants
+// a fragment or an empty response instead of a redirect.
+func isPartial(r *http.Request) bool {
+	return r.Header.Get("X-Requested-With") == "fetch"
+}
+
+// renderFragment renders a template without the page around it.
+func renderFragment(w http.ResponseWriter, name string, data interface{}) {
+	templatesMu.RLock()
+	t := templates
+	templatesMu.RUnlock()
+
+	if t == nil {
+		log.Println("Templates not initialized")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	render.HTML(w, http.StatusOK, t, name, data)
+}
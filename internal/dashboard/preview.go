@@ -0,0 +1,116 @@
+package dashboard
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/models"
+	"webring/internal/render"
+	"webring/internal/ring"
+	"webring/internal/settings"
+	"webring/internal/validate"
+
+	"github.com/gorilla/mux"
+)
+
+// sitePreview is how a site would appear on the public pages with the values
+// entered in its dashboard row.
+type sitePreview struct {
+	Site models.PublicSite
+	// New is set for sites that are not in the ring yet, whose favicon is
+	// only fetched once they are added.
+	New bool
+	// Problems lists the fields that would be rejected on save.
+	Problems []string
+
+	Position int
+	Total    int
+	Order    string
+	Prev     *models.PublicSite
+	Next     *models.PublicSite
+}
+
+// previewSiteHandler renders a site's directory entry and navigation widget
+// from the unsaved values of its row, without changing anything.
+func previewSiteHandler(db *sql.DB, st *settings.Store, rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		idStr := mux.Vars(r)["id"]
+		if idStr == "" {
+			idStr = r.FormValue("id")
+		}
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+		displayOrder, ok := displayOrderValue(r)
+		if !ok {
+			http.Error(w, "Invalid order", http.StatusBadRequest)
+			return
+		}
+
+		preview := sitePreview{Order: st.Get(settings.RingOrder)}
+		if preview.Order == "" {
+			preview.Order = ring.OrderManual
+		}
+
+		existing, err := getSite(db, id)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			preview.New = true
+		case err != nil:
+			log.Printf("Error fetching site %d: %v", id, err)
+			http.Error(w, "Error fetching site", http.StatusInternalServerError)
+			return
+		default:
+			preview.Site.Favicon = existing.Favicon
+		}
+
+		preview.Site.ID = id
+		preview.Site.Name, err = validate.SiteName(r.FormValue("name"), st.Bool(settings.StripEmojiInNames))
+		if err != nil {
+			preview.Site.Name = r.FormValue("name")
+			preview.Problems = append(preview.Problems, "Name: "+err.Error())
+		}
+		preview.Site.URL, err = validate.SiteURL(r.FormValue("url"), urlPolicy(st))
+		if err != nil {
+			preview.Site.URL = r.FormValue("url")
+			preview.Problems = append(preview.Problems, "URL: "+err.Error())
+		}
+		supportURL, err := validate.SupportURL(r.FormValue("support_url"))
+		if err != nil {
+			preview.Problems = append(preview.Problems, "Support link: "+err.Error())
+		} else if supportURL != "" {
+			preview.Site.SupportURL = &supportURL
+		}
+
+		sites, err := rc.Preview(preview.Site, displayOrder)
+		if err != nil {
+			log.Printf("Error previewing the ring: %v", err)
+			http.Error(w, "Error previewing the ring", http.StatusInternalServerError)
+			return
+		}
+		if i := ring.Index(sites, id); i >= 0 {
+			preview.Position, preview.Total = i+1, len(sites)
+			if len(sites) > 1 {
+				preview.Prev = &sites[(i-1+len(sites))%len(sites)]
+				preview.Next = &sites[(i+1)%len(sites)]
+			}
+		}
+
+		render.HTML(w, http.StatusOK, t, "site-preview.html", newPage(db, preview))
+	}
+}
@@ -0,0 +1,138 @@
+package dashboard
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"webring/internal/guestbook"
+	"webring/internal/ring"
+
+	"github.com/gorilla/mux"
+)
+
+// quickMaintenance is how long the maintenance window started by the
+// keyboard shortcut lasts, unless it is toggled off before.
+const quickMaintenance = 7 * 24 * time.Hour
+
+// quickSite is a site as returned by the quick action endpoints, with the
+// dashboard URL of its row.
+type quickSite struct {
+	ID             int        `json:"id"`
+	Name           string     `json:"name"`
+	URL            string     `json:"url"`
+	DashboardURL   string     `json:"dashboard_url"`
+	InMaintenance  bool       `json:"in_maintenance"`
+	MaintenanceEnd *time.Time `json:"maintenance_end,omitempty"`
+}
+
+// quickApproveHandler publishes the newest guestbook entry waiting for
+// approval. It answers 404 when there is none.
+func quickApproveHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entry, err := guestbook.ApproveNewest(db)
+		if err != nil {
+			log.Printf("Error approving guestbook entry: %v", err)
+			apiError(w, "Error approving guestbook entry", http.StatusInternalServerError)
+			return
+		}
+		if entry == nil {
+			apiError(w, "No guestbook entries waiting for approval", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"id":      entry.ID,
+			"name":    entry.Name,
+			"message": entry.Message,
+		})
+	}
+}
+
+// quickJumpHandler finds a site by ID or name for ?q=, preferring exact
+// names, then names starting with q, then names containing it.
+func quickJumpHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := strings.TrimSpace(r.URL.Query().Get("q"))
+		if q == "" {
+			apiError(w, "q is required", http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.Atoi(q)
+		if err != nil {
+			pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q)
+			err = db.QueryRow(`
+				SELECT id FROM sites WHERE name ILIKE '%' || $1 || '%'
+				ORDER BY LOWER(name) = LOWER($2) DESC, name ILIKE $1 || '%' DESC, display_order NULLS LAST, id
+				LIMIT 1`, pattern, q).Scan(&id)
+			if errors.Is(err, sql.ErrNoRows) {
+				apiError(w, "No site matches "+strconv.Quote(q), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("Error searching sites: %v", err)
+				apiError(w, "Error searching sites", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		writeQuickSite(w, db, id)
+	}
+}
+
+// quickMaintenanceHandler toggles a site's maintenance window: it ends the
+// current one now, or starts one lasting quickMaintenance. Whether the site
+// stays in the ring while down is left as configured.
+func quickMaintenanceHandler(db *sql.DB, rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+		res, err := db.Exec(`
+			UPDATE sites SET
+				maintenance_start = CASE WHEN (maintenance_start <= NOW() AND NOW() < maintenance_end) IS TRUE THEN maintenance_start ELSE NOW() END,
+				maintenance_end = CASE WHEN (maintenance_start <= NOW() AND NOW() < maintenance_end) IS TRUE THEN NOW() ELSE NOW() + $2 * INTERVAL '1 second' END
+			WHERE id = $1`, id, quickMaintenance.Seconds())
+		if err != nil {
+			log.Printf("Error toggling maintenance of site %d: %v", id, err)
+			apiError(w, "Error toggling maintenance", http.StatusInternalServerError)
+			return
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			apiError(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		rc.Invalidate()
+
+		writeQuickSite(w, db, id)
+	}
+}
+
+func writeQuickSite(w http.ResponseWriter, db *sql.DB, id int) {
+	site, err := getSite(db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apiError(w, "Site not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching site: %v", err)
+		apiError(w, "Error fetching site", http.StatusInternalServerError)
+		return
+	}
+
+	v := quickSite{
+		ID:            site.ID,
+		Name:          site.Name,
+		URL:           site.URL,
+		DashboardURL:  "/dashboard#site-" + strconv.Itoa(site.ID),
+		InMaintenance: site.InMaintenance,
+	}
+	if site.InMaintenance {
+		v.MaintenanceEnd = site.MaintenanceEnd
+	}
+	writeJSON(w, http.StatusOK, v)
+}
@@ -0,0 +1,328 @@
+package dashboard
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"webring/internal/audit"
+	"webring/internal/database"
+	"webring/internal/models"
+	"webring/internal/render"
+	"webring/internal/ring"
+	"webring/internal/settings"
+)
+
+// orderEntry is the display order of one site, as saved before a reorder.
+type orderEntry struct {
+	ID           int  `json:"id"`
+	DisplayOrder *int `json:"display_order"`
+}
+
+// recordReorder saves the display orders a reorder replaced, so it can be
+// undone.
+func recordReorder(tx *sql.Tx, previous []orderEntry, source string) error {
+	if len(previous) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(previous)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO reorders (previous, source) VALUES ($1, $2)", data, source)
+	return err
+}
+
+// lastReorder describes the reorder that "Undo" would revert.
+type lastReorder struct {
+	ID        int
+	Source    string
+	Sites     int
+	CreatedAt time.Time
+}
+
+func getLastReorder(db *sql.DB) (*lastReorder, error) {
+	var r lastReorder
+	err := db.QueryRow(`
+		SELECT id, source, jsonb_array_length(previous), created_at FROM reorders
+		WHERE undone_at IS NULL ORDER BY id DESC LIMIT 1`).Scan(&r.ID, &r.Source, &r.Sites, &r.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// orderedSite is a site in the manual ordering.
+type orderedSite struct {
+	ID           int
+	Name         string
+	DisplayOrder *int
+}
+
+// manualOrder returns every site in display order, with the sites without
+// one last, as the ring orders them in manual mode.
+func manualOrder(q interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}) ([]orderedSite, error) {
+	rows, err := q.Query("SELECT id, name, display_order FROM sites ORDER BY display_order NULLS LAST, id")
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	var sites []orderedSite
+	for rows.Next() {
+		var s orderedSite
+		if err := rows.Scan(&s.ID, &s.Name, &s.DisplayOrder); err != nil {
+			return nil, err
+		}
+		sites = append(sites, s)
+	}
+	return sites, rows.Err()
+}
+
+// proposedOrder reads the IDs listed in the order field, one per line with
+// anything after the ID ignored, and returns the full new ordering: the
+// listed sites first, then the others in their current order.
+func proposedOrder(current []orderedSite, field string) ([]orderedSite, error) {
+	byID := make(map[int]orderedSite, len(current))
+	for _, s := range current {
+		byID[s.ID] = s
+	}
+
+	var proposed []orderedSite
+	listed := make(map[int]bool)
+	for i, line := range strings.Split(field, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %q is not a site ID", i+1, fields[0])
+		}
+		site, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("line %d: no site with ID %d", i+1, id)
+		}
+		if listed[id] {
+			return nil, fmt.Errorf("line %d: site %d is listed twice", i+1, id)
+		}
+		listed[id] = true
+		proposed = append(proposed, site)
+	}
+	if len(proposed) == 0 {
+		return nil, errors.New("list at least one site ID")
+	}
+
+	for _, s := range current {
+		if !listed[s.ID] {
+			proposed = append(proposed, s)
+		}
+	}
+	return proposed, nil
+}
+
+// reorderPage is the data of the reorder page. After is set when previewing.
+type reorderPage struct {
+	Before []orderedSite
+	After  []orderedSite
+	// Order is the submitted order field, kept between preview and apply.
+	Order string
+	Error string
+	Last  *lastReorder
+	// RingOrder is the ordering setting; display orders only matter for
+	// manual ordering.
+	RingOrder string
+}
+
+func renderReorderPage(w http.ResponseWriter, db *sql.DB, st *settings.Store, data reorderPage, code int) {
+	templatesMu.RLock()
+	t := templates
+	templatesMu.RUnlock()
+
+	if t == nil {
+		log.Println("Templates not initialized")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	last, err := getLastReorder(db)
+	if err != nil {
+		log.Printf("Error fetching the last reorder: %v", err)
+	}
+	data.Last = last
+	data.RingOrder = st.Get(settings.RingOrder)
+	if data.RingOrder == "" {
+		data.RingOrder = ring.OrderManual
+	}
+
+	render.HTML(w, code, t, "reorder.html", newPage(db, data))
+}
+
+// reorderHandler shows the current manual ordering with a form to replace it.
+func reorderHandler(db *sql.DB, st *settings.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		current, err := manualOrder(db)
+		if err != nil {
+			log.Printf("Error fetching sites: %v", err)
+			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+			return
+		}
+
+		var order strings.Builder
+		for _, s := range current {
+			fmt.Fprintf(&order, "%d %s\n", s.ID, s.Name)
+		}
+		renderReorderPage(w, db, st, reorderPage{Before: current, Order: order.String()}, http.StatusOK)
+	}
+}
+
+// previewReorderHandler shows the ordering before and after the submitted
+// change without applying it.
+func previewReorderHandler(db *sql.DB, st *settings.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		current, err := manualOrder(db)
+		if err != nil {
+			log.Printf("Error fetching sites: %v", err)
+			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+			return
+		}
+
+		data := reorderPage{Before: current, Order: r.FormValue("order")}
+		proposed, err := proposedOrder(current, data.Order)
+		if err != nil {
+			data.Error = err.Error()
+			renderReorderPage(w, db, st, data, http.StatusBadRequest)
+			return
+		}
+		data.After = proposed
+		renderReorderPage(w, db, st, data, http.StatusOK)
+	}
+}
+
+// applyReorderHandler numbers the sites 1, 2, ... in the submitted order and
+// saves the previous display orders for undo.
+func applyReorderHandler(db *sql.DB, st *settings.Store, rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var changes []audit.Entry
+		var orderErr error
+		err := database.InTx(db, func(tx *sql.Tx) error {
+			// Keep concurrent edits out while the positions are rewritten
+			if _, err := tx.Exec("LOCK TABLE sites IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+				return err
+			}
+			current, err := manualOrder(tx)
+			if err != nil {
+				return err
+			}
+			proposed, err := proposedOrder(current, r.FormValue("order"))
+			if err != nil {
+				orderErr = err
+				return err
+			}
+
+			var previous []orderEntry
+			for i, s := range proposed {
+				position := i + 1
+				if s.DisplayOrder != nil && *s.DisplayOrder == position {
+					continue
+				}
+				if _, err := tx.Exec("UPDATE sites SET display_order = $1 WHERE id = $2", position, s.ID); err != nil {
+					return err
+				}
+				previous = append(previous, orderEntry{ID: s.ID, DisplayOrder: s.DisplayOrder})
+				changes = append(changes, audit.SiteChanges(
+					models.Site{ID: s.ID, Name: s.Name, DisplayOrder: s.DisplayOrder},
+					models.Site{ID: s.ID, Name: s.Name, DisplayOrder: &position})...)
+			}
+			return recordReorder(tx, previous, "dashboard")
+		})
+		if orderErr != nil {
+			http.Error(w, orderErr.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Error reordering sites: %v", err)
+			http.Error(w, "Error reordering sites", http.StatusInternalServerError)
+			return
+		}
+
+		rc.Invalidate()
+		audit.Record(db, changes...)
+		http.Redirect(w, r, "/dashboard/reorder", http.StatusSeeOther)
+	}
+}
+
+// undoReorderHandler restores the display orders saved by the latest reorder
+// that has not been undone yet, from the dashboard or the batch API.
+func undoReorderHandler(db *sql.DB, rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var changes []audit.Entry
+		found := false
+		err := database.InTx(db, func(tx *sql.Tx) error {
+			var id int
+			var data []byte
+			err := tx.QueryRow("SELECT id, previous FROM reorders WHERE undone_at IS NULL ORDER BY id DESC LIMIT 1 FOR UPDATE").Scan(&id, &data)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			found = true
+
+			var previous []orderEntry
+			if err := json.Unmarshal(data, &previous); err != nil {
+				return err
+			}
+			for _, entry := range previous {
+				var name string
+				var current *int
+				// Sites removed since then are skipped
+				err := tx.QueryRow("SELECT name, display_order FROM sites WHERE id = $1 FOR UPDATE", entry.ID).Scan(&name, &current)
+				if errors.Is(err, sql.ErrNoRows) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				if _, err := tx.Exec("UPDATE sites SET display_order = $1 WHERE id = $2", entry.DisplayOrder, entry.ID); err != nil {
+					return err
+				}
+				changes = append(changes, audit.SiteChanges(
+					models.Site{ID: entry.ID, Name: name, DisplayOrder: current},
+					models.Site{ID: entry.ID, Name: name, DisplayOrder: entry.DisplayOrder})...)
+			}
+			_, err = tx.Exec("UPDATE reorders SET undone_at = NOW() WHERE id = $1", id)
+			return err
+		})
+		if err != nil {
+			log.Printf("Error undoing reorder: %v", err)
+			http.Error(w, "Error undoing reorder", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "Nothing to undo", http.StatusNotFound)
+			return
+		}
+
+		rc.Invalidate()
+		audit.Record(db, changes...)
+		http.Redirect(w, r, "/dashboard/reorder", http.StatusSeeOther)
+	}
+}
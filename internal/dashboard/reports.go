@@ -0,0 +1,53 @@
+package dashboard
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/render"
+	"webring/internal/report"
+
+	"github.com/gorilla/mux"
+)
+
+func reportsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		entries, err := report.List(db)
+		if err != nil {
+			log.Printf("Error fetching reports: %v", err)
+			http.Error(w, "Error fetching reports", http.StatusInternalServerError)
+			return
+		}
+
+		render.HTML(w, http.StatusOK, t, "reports.html", newPage(db, entries))
+	}
+}
+
+func resolveReportHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := report.Resolve(db, id); err != nil {
+			http.Error(w, "Error resolving report", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/dashboard/reports", http.StatusSeeOther)
+	}
+}
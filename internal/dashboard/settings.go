@@ -0,0 +1,145 @@
+package dashboard
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webring/internal/audit"
+	"webring/internal/favicon"
+	"webring/internal/markdown"
+	"webring/internal/notify"
+	"webring/internal/render"
+	"webring/internal/ring"
+	"webring/internal/settings"
+	"webring/internal/slo"
+	"webring/internal/validate"
+)
+
+// settingValidators reject invalid values before they are stored.
+var settingValidators = map[string]func(string) error{
+	settings.NotificationRules: notify.ValidateRules,
+	settings.EscalationPolicy:  notify.ValidateEscalation,
+	settings.SLOTarget:         slo.ValidateTarget,
+	settings.Timezone:          notify.ValidateTimezone,
+	settings.RingSupportURL: func(value string) error {
+		_, err := validate.SupportURL(value)
+		return err
+	},
+	settings.FaviconFallback: func(value string) error {
+		if !favicon.ValidFallback(value) {
+			return fmt.Errorf("unknown favicon fallback service: %s", value)
+		}
+		return nil
+	},
+	settings.HTTPSGracePeriod:   durationValidator("grace period"),
+	settings.SLOWindow:          durationValidator("alert window"),
+	settings.NotifyDedupeWindow: durationValidator("dedupe window"),
+	settings.CustomRelations:    ring.ValidateRelations,
+	settings.RingOrder: func(value string) error {
+		if !ring.ValidOrder(value) {
+			return fmt.Errorf("unknown ring order: %s", value)
+		}
+		return nil
+	},
+}
+
+// durationValidator accepts an empty value or a positive duration.
+func durationValidator(what string) func(string) error {
+	return func(value string) error {
+		if value == "" {
+			return nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid %s: %s", what, value)
+		}
+		return nil
+	}
+}
+
+type settingField struct {
+	settings.Definition
+	Value string
+}
+
+func settingsHandler(db *sql.DB, st *settings.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		var fields []settingField
+		for _, def := range settings.Definitions {
+			fields = append(fields, settingField{Definition: def, Value: st.Get(def.Key)})
+		}
+
+		render.HTML(w, http.StatusOK, t, "settings.html", newPage(db, fields))
+	}
+}
+
+func updateSettingsHandler(db *sql.DB, st *settings.Store, rc *ring.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form", http.StatusBadRequest)
+			return
+		}
+
+		// Only the settings in the form change, so a partial form leaves
+		// the others alone. Everything is validated before anything is saved.
+		oldOrder := st.Get(settings.RingOrder)
+		values := make(map[string]string)
+		for _, def := range settings.Definitions {
+			submitted, ok := r.PostForm[def.Key]
+			if !ok {
+				continue
+			}
+			value := submitted[len(submitted)-1]
+			if def.Type == "bool" {
+				// The form sends a hidden false before each checkbox, which
+				// overrides it when checked
+				checked, _ := strconv.ParseBool(value)
+				value = strconv.FormatBool(checked || value == "on")
+			}
+			if validate, ok := settingValidators[def.Key]; ok {
+				if err := validate(value); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			values[def.Key] = value
+		}
+		if err := st.SetAll(values); err != nil {
+			log.Printf("Error saving settings: %v", err)
+			http.Error(w, "Error saving settings", http.StatusInternalServerError)
+			return
+		}
+		// The ring order may have changed
+		rc.Invalidate()
+		if newOrder := st.Get(settings.RingOrder); newOrder != oldOrder {
+			audit.Record(db, audit.Entry{Action: audit.ActionRingOrder, OldValue: oldOrder, NewValue: newOrder})
+		}
+
+		http.Redirect(w, r, "/dashboard/settings", http.StatusSeeOther)
+	}
+}
+
+// previewMarkdownHandler renders the markdown of a homepage block for the
+// preview next to the settings editor.
+func previewMarkdownHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if _, err := w.Write([]byte(markdown.Render(r.FormValue("markdown")))); err != nil {
+			log.Printf("Error writing markdown preview: %v", err)
+		}
+	}
+}
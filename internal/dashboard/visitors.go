@@ -0,0 +1,44 @@
+package dashboard
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"webring/internal/render"
+	"webring/internal/settings"
+	"webring/internal/visits"
+)
+
+type visitorsPage struct {
+	Visitors []visits.Visitors
+	// Enabled is the visitor_counter setting; the counts stay visible after
+	// it is turned off.
+	Enabled bool
+}
+
+// visitorsHandler lists how many visitors the ring sent to each member today
+// and over the last week.
+func visitorsHandler(db *sql.DB, st *settings.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		list, err := visits.List(db)
+		if err != nil {
+			log.Printf("Error fetching visitors: %v", err)
+			http.Error(w, "Error fetching visitors", http.StatusInternalServerError)
+			return
+		}
+
+		data := visitorsPage{Visitors: list, Enabled: st.Bool(settings.VisitorCounter)}
+		render.HTML(w, http.StatusOK, t, "visitors.html", newPage(db, data))
+	}
+}
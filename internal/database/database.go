@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	_ "github.com/lib/pq"
+	"log"
 	"os"
 )
 
@@ -10,3 +11,19 @@ func Connect() (*sql.DB, error) {
 	connStr := os.Getenv("DB_CONNECTION_STRING")
 	return sql.Open("postgres", connStr)
 }
+
+// InTx runs fn in a transaction, which is committed if fn succeeds and
+// rolled back otherwise.
+func InTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		if rerr := tx.Rollback(); rerr != nil {
+			log.Printf("Error rolling back transaction: %v", rerr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
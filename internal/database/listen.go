@@ -0,0 +1,51 @@
+package database
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Listen subscribes to a Postgres notification channel and calls handle with
+// the payload of every notification. The listener reconnects on its own;
+// since notifications sent while it was away are lost, handle is also called
+// with an empty payload after every reconnect. It blocks, so run it in a
+// goroutine.
+func Listen(channel string, handle func(payload string)) error {
+	listener := pq.NewListener(os.Getenv("DB_CONNECTION_STRING"), 10*time.Second, time.Minute,
+		func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				log.Printf("Error listening for %s notifications: %v", channel, err)
+			}
+		})
+	defer func(listener *pq.Listener) {
+		if err := listener.Close(); err != nil {
+			log.Printf("Error closing listener: %v", err)
+		}
+	}(listener)
+
+	if err := listener.Listen(channel); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case n := <-listener.Notify:
+			// nil after a reconnect
+			if n == nil {
+				handle("")
+				continue
+			}
+			handle(n.Extra)
+		case <-time.After(90 * time.Second):
+			// Notice a dead connection even when nothing changes
+			go func() {
+				if err := listener.Ping(); err != nil {
+					log.Printf("Error pinging the %s listener: %v", channel, err)
+				}
+			}()
+		}
+	}
+}
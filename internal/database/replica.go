@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"os"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// replicaConnLifetime bounds how long reads stay on the primary after the
+// replica comes back.
+const replicaConnLifetime = 5 * time.Minute
+
+// ConnectReplica opens the pool for reads that may lag slightly behind the
+// primary: public pages and the checker's site listing. It connects to
+// DB_REPLICA_CONNECTION_STRING and falls back to the primary for every
+// connection the replica refuses. Without a replica it returns primary.
+func ConnectReplica(primary *sql.DB) (*sql.DB, error) {
+	replicaStr := os.Getenv("DB_REPLICA_CONNECTION_STRING")
+	if replicaStr == "" {
+		return primary, nil
+	}
+
+	replica, err := pq.NewConnector(replicaStr)
+	if err != nil {
+		return nil, err
+	}
+	fallback, err := pq.NewConnector(os.Getenv("DB_CONNECTION_STRING"))
+	if err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(fallbackConnector{replica: replica, primary: fallback})
+	db.SetConnMaxLifetime(replicaConnLifetime)
+	return db, nil
+}
+
+// fallbackConnector opens connections to the replica, or to the primary when
+// the replica can't be reached.
+type fallbackConnector struct {
+	replica driver.Connector
+	primary driver.Connector
+}
+
+func (c fallbackConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.replica.Connect(ctx)
+	if err == nil {
+		return conn, nil
+	}
+	log.Printf("Error connecting to the read replica, reading from the primary: %v", err)
+	return c.primary.Connect(ctx)
+}
+
+func (c fallbackConnector) Driver() driver.Driver {
+	return c.replica.Driver()
+}
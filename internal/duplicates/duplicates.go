@@ -0,0 +1,271 @@
+// Package duplicates finds members that are likely the same site: URLs on
+// the same host where one path contains the other, e.g. a member that was
+// added again after moving from http:// to https:// or from the bare domain
+// to www. Sites on different subdomains, or side by side on a shared host
+// like example.com/~alice and example.com/~bob, are separate members and are
+// not flagged.
+package duplicates
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"webring/internal/database"
+	"webring/internal/jobs"
+	"webring/internal/outbox"
+	"webring/internal/validate"
+	"webring/internal/visits"
+
+	"github.com/lib/pq"
+)
+
+// JobDetect is the periodic job looking for duplicates.
+const JobDetect = "duplicate_check"
+
+var ErrNotFound = errors.New("site not found")
+
+// Site is a member as far as duplicate detection is concerned.
+type Site struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	URL       string     `json:"url"`
+	IsUp      bool       `json:"is_up"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+// Pair is two likely duplicates, the one with the lower ID first.
+type Pair struct {
+	Site    Site      `json:"site"`
+	Other   Site      `json:"other"`
+	Host    string    `json:"host"`
+	FoundAt time.Time `json:"found_at"`
+}
+
+// Key returns the normalized host and path of a member URL. The scheme,
+// port, a leading www., the query and a trailing slash are ignored. URLs
+// stored without a scheme are read as https, like when they were added.
+func Key(raw string) (host, path string) {
+	u, err := url.Parse(validate.WithScheme(raw))
+	if err != nil || u.Hostname() == "" {
+		return "", ""
+	}
+	host = strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	host = strings.TrimPrefix(host, "www.")
+	path = strings.TrimRight(strings.ToLower(u.Path), "/")
+	path = strings.TrimSuffix(path, "/index.html")
+	return host, path
+}
+
+// contains reports whether the path b is at or below the path a.
+func contains(a, b string) bool {
+	return a == b || strings.HasPrefix(b, a+"/")
+}
+
+// Find returns the pairs of likely duplicates among sites.
+func Find(sites []Site) []Pair {
+	type keyed struct {
+		site Site
+		path string
+	}
+	byHost := make(map[string][]keyed)
+	for _, s := range sites {
+		host, path := Key(s.URL)
+		if host == "" {
+			continue
+		}
+		byHost[host] = append(byHost[host], keyed{s, path})
+	}
+
+	var pairs []Pair
+	for host, group := range byHost {
+		for i := range group {
+			for j := i + 1; j < len(group); j++ {
+				a, b := group[i], group[j]
+				if !contains(a.path, b.path) && !contains(b.path, a.path) {
+					continue
+				}
+				if b.site.ID < a.site.ID {
+					a, b = b, a
+				}
+				pairs = append(pairs, Pair{Site: a.site, Other: b.site, Host: host})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Site.ID != pairs[j].Site.ID {
+			return pairs[i].Site.ID < pairs[j].Site.ID
+		}
+		return pairs[i].Other.ID < pairs[j].Other.ID
+	})
+	return pairs
+}
+
+// Register runs the detection every interval on one of the instances.
+func Register(w *jobs.Worker, db *sql.DB, interval time.Duration) {
+	w.Every(JobDetect, interval, func() {
+		if err := Detect(db); err != nil {
+			log.Printf("Error looking for duplicate sites: %v", err)
+		}
+	})
+}
+
+// Detect stores the current pairs of duplicates. Pairs that were dismissed
+// stay dismissed as long as they match; pairs that no longer match are
+// forgotten.
+func Detect(db *sql.DB) error {
+	sites, err := allSites(db)
+	if err != nil {
+		return err
+	}
+	pairs := Find(sites)
+
+	ids := make([]int64, len(pairs))
+	others := make([]int64, len(pairs))
+	for i, p := range pairs {
+		ids[i] = int64(p.Site.ID)
+		others[i] = int64(p.Other.ID)
+	}
+	return database.InTx(db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			DELETE FROM site_duplicates
+			WHERE (site_id, other_id) NOT IN (SELECT * FROM unnest($1::int[], $2::int[]))`,
+			pq.Array(ids), pq.Array(others))
+		if err != nil {
+			return err
+		}
+		for _, p := range pairs {
+			_, err := tx.Exec("INSERT INTO site_duplicates (site_id, other_id, host) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+				p.Site.ID, p.Other.ID, p.Host)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func allSites(db *sql.DB) ([]Site, error) {
+	rows, err := db.Query("SELECT id, name, url, is_up, created_at FROM sites")
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	var sites []Site
+	for rows.Next() {
+		var s Site
+		if err := rows.Scan(&s.ID, &s.Name, &s.URL, &s.IsUp, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		sites = append(sites, s)
+	}
+	return sites, rows.Err()
+}
+
+// List returns the pairs found by the last detection that were not
+// dismissed.
+func List(db *sql.DB) ([]Pair, error) {
+	rows, err := db.Query(`
+		SELECT d.host, d.found_at,
+		       a.id, a.name, a.url, a.is_up, a.created_at,
+		       b.id, b.name, b.url, b.is_up, b.created_at
+		FROM site_duplicates d
+		JOIN sites a ON a.id = d.site_id
+		JOIN sites b ON b.id = d.other_id
+		WHERE NOT d.dismissed
+		ORDER BY d.found_at DESC, d.site_id, d.other_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	var pairs []Pair
+	for rows.Next() {
+		var p Pair
+		err := rows.Scan(&p.Host, &p.FoundAt,
+			&p.Site.ID, &p.Site.Name, &p.Site.URL, &p.Site.IsUp, &p.Site.CreatedAt,
+			&p.Other.ID, &p.Other.Name, &p.Other.URL, &p.Other.IsUp, &p.Other.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, rows.Err()
+}
+
+// Dismiss marks two sites as separate members, so they are no longer listed.
+func Dismiss(db *sql.DB, a, b int) error {
+	if b < a {
+		a, b = b, a
+	}
+	_, err := db.Exec("UPDATE site_duplicates SET dismissed = TRUE WHERE site_id = $1 AND other_id = $2", a, b)
+	return err
+}
+
+// Merge removes the site remove from the ring after handing its history to
+// keep: reports and check errors move over, uptime history for the periods
+// keep has none, visits are added to keep's, and the owner's description,
+// feed and support link where keep has none. It returns the removed site's name and URL.
+func Merge(db *sql.DB, keep, remove int) (name, siteURL string, err error) {
+	if keep == remove {
+		return "", "", errors.New("a site can't be merged into itself")
+	}
+	err = database.InTx(db, func(tx *sql.Tx) error {
+		var locked int
+		err := tx.QueryRow("SELECT COUNT(*) FROM (SELECT id FROM sites WHERE id IN ($1, $2) ORDER BY id FOR UPDATE) s", keep, remove).Scan(&locked)
+		if err != nil {
+			return err
+		}
+		if locked != 2 {
+			return ErrNotFound
+		}
+
+		statements := []string{
+			"UPDATE reports SET site_id = $1 WHERE site_id = $2",
+			"UPDATE check_errors SET site_id = $1 WHERE site_id = $2",
+			`UPDATE uptime_checks SET site_id = $1
+			 WHERE site_id = $2 AND checked_at < COALESCE((SELECT MIN(checked_at) FROM uptime_checks WHERE site_id = $1), 'infinity')`,
+			`INSERT INTO uptime_hourly (site_id, hour, checks, up_checks, avg_response_time)
+			 SELECT $1, hour, checks, up_checks, avg_response_time FROM uptime_hourly WHERE site_id = $2
+			 ON CONFLICT DO NOTHING`,
+			`INSERT INTO uptime_daily (site_id, day, checks, up_checks, avg_response_time)
+			 SELECT $1, day, checks, up_checks, avg_response_time FROM uptime_daily WHERE site_id = $2
+			 ON CONFLICT DO NOTHING`,
+			`UPDATE sites k SET
+			     description = COALESCE(k.description, r.description),
+			     feed_url = COALESCE(k.feed_url, r.feed_url),
+			     support_url = COALESCE(k.support_url, r.support_url)
+			 FROM sites r WHERE k.id = $1 AND r.id = $2`,
+		}
+		for _, query := range statements {
+			if _, err := tx.Exec(query, keep, remove); err != nil {
+				return err
+			}
+		}
+
+		if err := visits.Move(tx, remove, keep); err != nil {
+			return err
+		}
+
+		if err := tx.QueryRow("DELETE FROM sites WHERE id = $1 RETURNING name, url", remove).Scan(&name, &siteURL); err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, outbox.EventSiteChanged, outbox.SiteChanged{SiteID: remove, URL: siteURL, Removed: true})
+	})
+	return name, siteURL, err
+}
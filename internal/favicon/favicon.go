@@ -10,17 +10,80 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"webring/internal/httpclient"
+
 	"github.com/PuerkitoBio/goquery"
 )
 
-func GetAndStoreFavicon(siteURL string, mediaFolder string, siteID int) (string, error) {
+// fallbackServices are third-party favicon services tried when the site
+// itself has no usable favicon. They see every host they are asked about,
+// so they are only used when enabled in the settings.
+var fallbackServices = map[string]string{
+	"duckduckgo": "https://icons.duckduckgo.com/ip3/%s.ico",
+	"google":     "https://www.google.com/s2/favicons?domain=%s&sz=64",
+}
+
+// ValidFallback reports whether name is a known fallback service, or empty.
+func ValidFallback(name string) bool {
+	_, ok := fallbackServices[name]
+	return name == "" || ok
+}
+
+// ErrOptedOut is returned for sites that asked not to have their favicon
+// copied.
+var ErrOptedOut = errors.New("site opted out of favicon scraping")
+
+// optedOut reports whether a response asks the ring not to scrape the site:
+// X-Webring-Optout: all (or favicon), or an X-Robots-Tag forbidding images.
+func optedOut(h http.Header) bool {
+	for _, value := range h.Values("X-Webring-Optout") {
+		for _, token := range strings.Split(strings.ToLower(value), ",") {
+			switch strings.TrimSpace(token) {
+			case "all", "favicon", "1", "true":
+				return true
+			}
+		}
+	}
+	for _, value := range h.Values("X-Robots-Tag") {
+		if robotsOptOut(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// robotsOptOut reports whether robots directives (from X-Robots-Tag or a
+// robots meta tag) forbid copying the site's images.
+func robotsOptOut(directives string) bool {
+	for _, d := range strings.Split(strings.ToLower(directives), ",") {
+		// X-Robots-Tag may name the bot, e.g. "googlebot: noindex"
+		if _, rule, ok := strings.Cut(d, ":"); ok {
+			d = rule
+		}
+		switch strings.TrimSpace(d) {
+		case "none", "noimageindex":
+			return true
+		}
+	}
+	return false
+}
+
+// GetAndStoreFavicon downloads the favicon of a site into mediaFolder and
+// returns its file name. fallback names the service from fallbackServices to
+// ask when scraping the site fails; an empty fallback disables it.
+func GetAndStoreFavicon(siteURL string, mediaFolder string, siteID int, fallback string) (string, error) {
 	faviconURL, err := getFaviconFromHTML(siteURL)
+	if errors.Is(err, ErrOptedOut) {
+		// Neither the site nor a fallback service is asked then
+		return "", err
+	}
 	if err == nil {
-		faviconPath, err := downloadFavicon(faviconURL, siteURL, mediaFolder, siteID)
+		faviconPath, err := downloadFavicon(faviconURL, siteURL, mediaFolder, siteID, true)
 		if err == nil {
 			return faviconPath, nil
 		}
@@ -39,27 +102,35 @@ func GetAndStoreFavicon(siteURL string, mediaFolder string, siteID int) (string,
 
 	for _, name := range commonFaviconNames {
 		faviconURL := fmt.Sprintf("%s/%s", siteURL, name)
-		faviconPath, err := downloadFavicon(faviconURL, siteURL, mediaFolder, siteID)
+		faviconPath, err := downloadFavicon(faviconURL, siteURL, mediaFolder, siteID, true)
 		if err == nil {
 			return faviconPath, nil
 		}
 		log.Printf("Failed to download %s: %v", name, err)
 	}
 
+	if service, ok := fallbackServices[fallback]; ok {
+		if u, err := url.Parse(siteURL); err == nil && u.Hostname() != "" {
+			faviconURL := fmt.Sprintf(service, url.QueryEscape(u.Hostname()))
+			faviconPath, err := downloadFavicon(faviconURL, siteURL, mediaFolder, siteID, false)
+			if err == nil {
+				return faviconPath, nil
+			}
+			log.Printf("Failed to download favicon from %s: %v", fallback, err)
+		}
+	}
+
 	return "", errors.New("failed to find and download favicon")
 }
 
 func getFaviconFromHTML(siteURL string) (string, error) {
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
+	client := httpclient.New("favicon", httpclient.Options{Timeout: 5 * time.Second, RespectRobots: true})
 
 	req, err := http.NewRequest("GET", siteURL, nil)
 	if err != nil {
 		return "", err
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 	req.Header.Set("Connection", "keep-alive")
@@ -76,6 +147,9 @@ func getFaviconFromHTML(siteURL string) (string, error) {
 		}
 	}(resp.Body)
 
+	if optedOut(resp.Header) {
+		return "", ErrOptedOut
+	}
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("failed to fetch HTML: status code %d", resp.StatusCode)
 	}
@@ -84,6 +158,9 @@ func getFaviconFromHTML(siteURL string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if content, ok := doc.Find("meta[name='robots' i]").Attr("content"); ok && robotsOptOut(content) {
+		return "", ErrOptedOut
+	}
 
 	var faviconURL string
 	var exists bool
@@ -109,17 +186,17 @@ func getFaviconFromHTML(siteURL string) (string, error) {
 	return faviconURL, nil
 }
 
-func downloadFavicon(faviconURL, siteURL, mediaFolder string, siteID int) (string, error) {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+// downloadFavicon stores the image at faviconURL. Images on the site itself
+// are only fetched where its robots.txt allows; the fallback services are
+// APIs meant for this and are asked regardless.
+func downloadFavicon(faviconURL, siteURL, mediaFolder string, siteID int, fromSite bool) (string, error) {
+	client := httpclient.New("favicon", httpclient.Options{Timeout: 10 * time.Second, RespectRobots: fromSite})
 
 	req, err := http.NewRequest("GET", faviconURL, nil)
 	if err != nil {
 		return "", err
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
 	req.Header.Set("Accept", "image/webp,image/apng,image/*,*/*;q=0.8")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 	req.Header.Set("Connection", "keep-alive")
@@ -144,10 +221,7 @@ func downloadFavicon(faviconURL, siteURL, mediaFolder string, siteID int) (strin
 	hasher.Write([]byte(fmt.Sprintf("%d-%s", siteID, faviconURL)))
 	hash := hex.EncodeToString(hasher.Sum(nil))
 
-	ext := filepath.Ext(faviconURL)
-	if ext == "" {
-		ext = ".ico"
-	}
+	ext := faviconExt(faviconURL, resp.Header.Get("Content-Type"))
 
 	fileName := fmt.Sprintf("favicon-%d-%s%s", siteID, hash[:8], ext)
 	filePath := filepath.Join(mediaFolder, fileName)
@@ -174,3 +248,24 @@ func downloadFavicon(faviconURL, siteURL, mediaFolder string, siteID int) (strin
 
 	return fileName, nil
 }
+
+// faviconExt picks the file extension for a downloaded favicon, from the URL
+// path if it has one and from the content type otherwise.
+func faviconExt(faviconURL, contentType string) string {
+	if u, err := url.Parse(faviconURL); err == nil {
+		if ext := path.Ext(u.Path); ext != "" {
+			return ext
+		}
+	}
+	switch strings.TrimSpace(strings.Split(contentType, ";")[0]) {
+	case "image/png":
+		return ".png"
+	case "image/svg+xml":
+		return ".svg"
+	case "image/gif":
+		return ".gif"
+	case "image/jpeg":
+		return ".jpg"
+	}
+	return ".ico"
+}
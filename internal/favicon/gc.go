@@ -0,0 +1,144 @@
+package favicon
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"webring/internal/database"
+	"webring/internal/jobs"
+)
+
+// gcGracePeriod protects files that were just downloaded but whose site row
+// has not been updated yet.
+const gcGracePeriod = time.Hour
+
+type GCResult struct {
+	RanAt        time.Time
+	FilesRemoved int
+	BytesFreed   int64
+	Err          error
+}
+
+// GarbageCollector removes favicon files that are no longer referenced by any
+// site and remembers the outcome of the last run.
+type GarbageCollector struct {
+	db          *sql.DB
+	mediaFolder string
+
+	mu   sync.Mutex
+	last GCResult
+}
+
+func NewGarbageCollector(db *sql.DB, mediaFolder string) *GarbageCollector {
+	return &GarbageCollector{db: db, mediaFolder: mediaFolder}
+}
+
+func (gc *GarbageCollector) Last() GCResult {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	return gc.last
+}
+
+// JobGC is the periodic job collecting orphaned favicons.
+const JobGC = "favicon_gc"
+
+// gcLock is the advisory lock held while collecting, so runs from the
+// dashboard and the periodic job of other instances don't overlap.
+const gcLock = 0x66617669636f6e // "favicon"
+
+var errGCRunning = errors.New("a collection is already running on another instance")
+
+// Register runs the collector every interval on one of the instances.
+func (gc *GarbageCollector) Register(w *jobs.Worker, interval time.Duration) {
+	w.Every(JobGC, interval, func() {
+		gc.Run()
+	})
+}
+
+// Run removes the orphaned files now, unless another instance is at it.
+func (gc *GarbageCollector) Run() GCResult {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	res := GCResult{RanAt: time.Now()}
+	err := database.InTx(gc.db, func(tx *sql.Tx) error {
+		var locked bool
+		if err := tx.QueryRow("SELECT pg_try_advisory_xact_lock($1)", gcLock).Scan(&locked); err != nil {
+			return err
+		}
+		if !locked {
+			return errGCRunning
+		}
+		return gc.collect(tx, &res)
+	})
+	if err != nil {
+		res.Err = err
+		log.Printf("Error collecting media garbage: %v", err)
+	}
+	gc.last = res
+	return res
+}
+
+// collect removes the files no site references. The lock is held by tx.
+func (gc *GarbageCollector) collect(tx *sql.Tx, res *GCResult) error {
+	referenced, err := referencedFiles(tx)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(gc.mediaFolder)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		// Only touch files this package created
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), "favicon-") || referenced[entry.Name()] {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < gcGracePeriod {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(gc.mediaFolder, entry.Name())); err != nil {
+			log.Printf("Error removing orphaned media file %s: %v", entry.Name(), err)
+			continue
+		}
+		res.FilesRemoved++
+		res.BytesFreed += info.Size()
+	}
+
+	log.Printf("Media garbage collection removed %d files (%d bytes)", res.FilesRemoved, res.BytesFreed)
+	return nil
+}
+
+func referencedFiles(tx *sql.Tx) (map[string]bool, error) {
+	rows, err := tx.Query("SELECT favicon FROM sites WHERE favicon IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	referenced := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		referenced[name] = true
+	}
+	return referenced, rows.Err()
+}
@@ -0,0 +1,139 @@
+package favicon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+var errUnsupportedICO = errors.New("unsupported ico image")
+
+const icoMagic = "\x00\x00\x01\x00"
+
+// decodeICO decodes the largest image of an .ico file. Entries can either be
+// embedded PNGs or BMPs without the file header; BMPs with 1, 4, 8, 24 or 32
+// bits per pixel are supported.
+func decodeICO(data []byte) (image.Image, error) {
+	if len(data) < 6 || string(data[:4]) != icoMagic {
+		return nil, errUnsupportedICO
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:6]))
+
+	best, bestSize := -1, -1
+	for i := 0; i < count; i++ {
+		entry := 6 + i*16
+		if entry+16 > len(data) {
+			break
+		}
+		// A stored width of 0 means 256
+		width := int(data[entry])
+		if width == 0 {
+			width = 256
+		}
+		if width > bestSize {
+			best, bestSize = entry, width
+		}
+	}
+	if best < 0 {
+		return nil, errUnsupportedICO
+	}
+
+	size := int(binary.LittleEndian.Uint32(data[best+8 : best+12]))
+	offset := int(binary.LittleEndian.Uint32(data[best+12 : best+16]))
+	if offset < 0 || size <= 0 || offset+size > len(data) {
+		return nil, errUnsupportedICO
+	}
+	img := data[offset : offset+size]
+
+	if bytes.HasPrefix(img, []byte("\x89PNG")) {
+		return png.Decode(bytes.NewReader(img))
+	}
+	return decodeICOBitmap(img)
+}
+
+func decodeICOBitmap(data []byte) (image.Image, error) {
+	if len(data) < 40 {
+		return nil, errUnsupportedICO
+	}
+	headerSize := int(binary.LittleEndian.Uint32(data[0:4]))
+	width := int(int32(binary.LittleEndian.Uint32(data[4:8])))
+	// The height covers both the color bitmap and the AND mask
+	height := int(int32(binary.LittleEndian.Uint32(data[8:12]))) / 2
+	bpp := int(binary.LittleEndian.Uint16(data[14:16]))
+	compression := binary.LittleEndian.Uint32(data[16:20])
+	colorsUsed := int(binary.LittleEndian.Uint32(data[32:36]))
+
+	if compression != 0 || width <= 0 || height <= 0 || width > 256 || height > 256 {
+		return nil, errUnsupportedICO
+	}
+
+	pos := headerSize
+	var palette []color.NRGBA
+	switch bpp {
+	case 1, 4, 8:
+		if colorsUsed == 0 {
+			colorsUsed = 1 << bpp
+		}
+		if pos+colorsUsed*4 > len(data) {
+			return nil, errUnsupportedICO
+		}
+		for i := 0; i < colorsUsed; i++ {
+			c := data[pos+i*4:]
+			palette = append(palette, color.NRGBA{R: c[2], G: c[1], B: c[0], A: 0xff})
+		}
+		pos += colorsUsed * 4
+	case 24, 32:
+	default:
+		return nil, errUnsupportedICO
+	}
+
+	stride := (width*bpp + 31) / 32 * 4
+	maskStride := (width + 31) / 32 * 4
+	maskStart := pos + stride*height
+	if maskStart > len(data) {
+		return nil, errUnsupportedICO
+	}
+	hasMask := maskStart+maskStride*height <= len(data)
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		// Rows are stored bottom-up
+		row := data[pos+(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch bpp {
+			case 32:
+				c = color.NRGBA{R: row[x*4+2], G: row[x*4+1], B: row[x*4], A: row[x*4+3]}
+				hasAlpha = hasAlpha || c.A != 0
+			case 24:
+				c = color.NRGBA{R: row[x*3+2], G: row[x*3+1], B: row[x*3], A: 0xff}
+			default:
+				bit := x * bpp
+				index := int(row[bit/8]>>(8-bpp-bit%8)) & (1<<bpp - 1)
+				if index < len(palette) {
+					c = palette[index]
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// Icons without an alpha channel use the AND mask for transparency
+	if !hasAlpha {
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				c := img.NRGBAAt(x, y)
+				c.A = 0xff
+				if hasMask && data[maskStart+(height-1-y)*maskStride+x/8]&(0x80>>(x%8)) != 0 {
+					c.A = 0
+				}
+				img.SetNRGBA(x, y, c)
+			}
+		}
+	}
+	return img, nil
+}
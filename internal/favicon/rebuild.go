@@ -0,0 +1,192 @@
+package favicon
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"webring/internal/settings"
+)
+
+const rebuildWorkers = 4
+
+var ErrRebuildRunning = errors.New("a favicon rebuild is already running")
+
+// MediaFolder returns the folder favicons are stored in.
+func MediaFolder() string {
+	mediaFolder := os.Getenv("MEDIA_FOLDER")
+	if mediaFolder == "" {
+		mediaFolder = "media"
+	}
+	return mediaFolder
+}
+
+// Refresh fetches the favicon of a site and stores its file name.
+func Refresh(db *sql.DB, siteID int, siteURL string, mediaFolder string, fallback string) error {
+	faviconPath, err := GetAndStoreFavicon(siteURL, mediaFolder, siteID, fallback)
+	if errors.Is(err, ErrOptedOut) {
+		// Drop the copy made before the site opted out
+		_, err = db.Exec("UPDATE sites SET favicon = NULL WHERE id = $1", siteID)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE sites SET favicon = $1 WHERE id = $2", faviconPath, siteID)
+	return err
+}
+
+type Progress struct {
+	Running    bool
+	Total      int
+	Done       int
+	Failed     int
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Errors     []string
+}
+
+// Rebuilder re-fetches the favicons of all sites and keeps track of its
+// progress so it can be reported while running.
+type Rebuilder struct {
+	db          *sql.DB
+	mediaFolder string
+	settings    *settings.Store
+
+	mu       sync.Mutex
+	progress Progress
+}
+
+func NewRebuilder(db *sql.DB, mediaFolder string, st *settings.Store) *Rebuilder {
+	return &Rebuilder{db: db, mediaFolder: mediaFolder, settings: st}
+}
+
+func (b *Rebuilder) Progress() Progress {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := b.progress
+	p.Errors = append([]string(nil), b.progress.Errors...)
+	return p
+}
+
+// Start runs a rebuild in the background and calls done when it finishes.
+// It returns ErrRebuildRunning if a rebuild is already in progress.
+func (b *Rebuilder) Start(done func()) error {
+	if !b.begin() {
+		return ErrRebuildRunning
+	}
+	go func() {
+		b.run()
+		if done != nil {
+			done()
+		}
+	}()
+	return nil
+}
+
+// Run rebuilds synchronously.
+func (b *Rebuilder) Run() error {
+	if !b.begin() {
+		return ErrRebuildRunning
+	}
+	b.run()
+	return nil
+}
+
+func (b *Rebuilder) begin() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.progress.Running {
+		return false
+	}
+	b.progress = Progress{Running: true, StartedAt: time.Now()}
+	return true
+}
+
+type siteRef struct {
+	id  int
+	url string
+}
+
+func (b *Rebuilder) run() {
+	defer func() {
+		b.mu.Lock()
+		b.progress.Running = false
+		b.progress.FinishedAt = time.Now()
+		b.mu.Unlock()
+	}()
+
+	sites, err := b.loadSites()
+	if err != nil {
+		b.recordError(fmt.Sprintf("loading sites: %v", err))
+		return
+	}
+
+	b.mu.Lock()
+	b.progress.Total = len(sites)
+	b.mu.Unlock()
+
+	fallback := b.settings.Get(settings.FaviconFallback)
+	jobs := make(chan siteRef)
+	var wg sync.WaitGroup
+	for i := 0; i < rebuildWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for site := range jobs {
+				err := Refresh(b.db, site.id, site.url, b.mediaFolder, fallback)
+
+				b.mu.Lock()
+				b.progress.Done++
+				b.mu.Unlock()
+
+				if err != nil {
+					log.Printf("Error rebuilding favicon for site %d: %v", site.id, err)
+					b.recordError(fmt.Sprintf("site %d (%s): %v", site.id, site.url, err))
+				}
+			}
+		}()
+	}
+
+	for _, site := range sites {
+		jobs <- site
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func (b *Rebuilder) recordError(msg string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.progress.Failed++
+	b.progress.Errors = append(b.progress.Errors, msg)
+}
+
+func (b *Rebuilder) loadSites() ([]siteRef, error) {
+	rows, err := b.db.Query("SELECT id, url FROM sites ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	var sites []siteRef
+	for rows.Next() {
+		var site siteRef
+		if err := rows.Scan(&site.id, &site.url); err != nil {
+			return nil, err
+		}
+		sites = append(sites, site)
+	}
+	return sites, rows.Err()
+}
@@ -0,0 +1,180 @@
+package favicon
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"webring/internal/models"
+)
+
+const (
+	// SpriteCellSize is the size of every icon in the sprite, large enough to
+	// stay sharp on high density screens when shown at 20px.
+	SpriteCellSize = 32
+	spriteColumns  = 16
+)
+
+// SpriteOffset is the position of an icon in the sprite, in pixels.
+type SpriteOffset struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// SpriteSheet is a combined image of the favicons of all members.
+type SpriteSheet struct {
+	PNG     []byte                `json:"-"`
+	Version string                `json:"version"`
+	Size    int                   `json:"size"`
+	Width   int                   `json:"width"`
+	Height  int                   `json:"height"`
+	Icons   map[int]*SpriteOffset `json:"icons"`
+}
+
+// Sprite builds the sprite sheet for the public directory and rebuilds it
+// only when the set of members or their favicons change.
+type Sprite struct {
+	mediaFolder string
+	sites       func() ([]models.PublicSite, error)
+
+	mu          sync.Mutex
+	fingerprint string
+	sheet       *SpriteSheet
+}
+
+// NewSprite returns a sprite of the members returned by sites, normally the
+// ring cache's Sites so the sprite follows the ring.
+func NewSprite(mediaFolder string, sites func() ([]models.PublicSite, error)) *Sprite {
+	return &Sprite{mediaFolder: mediaFolder, sites: sites}
+}
+
+// Sheet returns the current sprite sheet.
+func (s *Sprite) Sheet() (*SpriteSheet, error) {
+	sites, err := s.sites()
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := spriteFingerprint(sites)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sheet != nil && s.fingerprint == fingerprint {
+		return s.sheet, nil
+	}
+
+	sheet, err := s.build(sites)
+	if err != nil {
+		return nil, err
+	}
+	s.sheet, s.fingerprint = sheet, fingerprint
+	return sheet, nil
+}
+
+func spriteFingerprint(sites []models.PublicSite) string {
+	var b strings.Builder
+	for _, site := range sites {
+		if site.Favicon != nil {
+			fmt.Fprintf(&b, "%d:%s\n", site.ID, *site.Favicon)
+		}
+	}
+	return b.String()
+}
+
+func (s *Sprite) build(sites []models.PublicSite) (*SpriteSheet, error) {
+	type icon struct {
+		id  int
+		img image.Image
+	}
+	var icons []icon
+	for _, site := range sites {
+		if site.Favicon == nil {
+			continue
+		}
+		img, err := s.decode(*site.Favicon)
+		if err != nil {
+			// The directory falls back to the single image for this member
+			log.Printf("Skipping favicon %s in sprite: %v", *site.Favicon, err)
+			continue
+		}
+		icons = append(icons, icon{site.ID, img})
+	}
+
+	columns := min(len(icons), spriteColumns)
+	rows := (len(icons) + spriteColumns - 1) / spriteColumns
+	sheet := &SpriteSheet{
+		Size:   SpriteCellSize,
+		Width:  max(columns, 1) * SpriteCellSize,
+		Height: max(rows, 1) * SpriteCellSize,
+		Icons:  make(map[int]*SpriteOffset, len(icons)),
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, sheet.Width, sheet.Height))
+	for i, ic := range icons {
+		offset := &SpriteOffset{X: i % spriteColumns * SpriteCellSize, Y: i / spriteColumns * SpriteCellSize}
+		drawScaled(dst, image.Rect(offset.X, offset.Y, offset.X+SpriteCellSize, offset.Y+SpriteCellSize), ic.img)
+		sheet.Icons[ic.id] = offset
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, err
+	}
+	sheet.PNG = buf.Bytes()
+	sum := sha256.Sum256(sheet.PNG)
+	sheet.Version = hex.EncodeToString(sum[:8])
+	return sheet, nil
+}
+
+func (s *Sprite) decode(name string) (image.Image, error) {
+	data, err := os.ReadFile(filepath.Join(s.mediaFolder, filepath.Base(name)))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte(icoMagic)) {
+		return decodeICO(data)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// drawScaled draws src into r of dst, averaging the source pixels covered by
+// every destination pixel. The standard library has no image scaling.
+func drawScaled(dst draw.Image, r image.Rectangle, src image.Image) {
+	sb := src.Bounds()
+	if sb.Empty() {
+		return
+	}
+	for y := 0; y < r.Dy(); y++ {
+		y0 := sb.Min.Y + y*sb.Dy()/r.Dy()
+		y1 := max(sb.Min.Y+(y+1)*sb.Dy()/r.Dy(), y0+1)
+		for x := 0; x < r.Dx(); x++ {
+			x0 := sb.Min.X + x*sb.Dx()/r.Dx()
+			x1 := max(sb.Min.X+(x+1)*sb.Dx()/r.Dx(), x0+1)
+
+			var red, green, blue, alpha, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					red, green, blue, alpha = red+cr, green+cg, blue+cb, alpha+ca
+					n++
+				}
+			}
+			dst.Set(r.Min.X+x, r.Min.Y+y, color.RGBA64{
+				R: uint16(red / n), G: uint16(green / n), B: uint16(blue / n), A: uint16(alpha / n),
+			})
+		}
+	}
+}
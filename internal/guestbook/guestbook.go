@@ -0,0 +1,158 @@
+// Package guestbook keeps the ring's guestbook. Visitors sign it from the
+// public /guestbook page; entries stay hidden until an admin approves them on
+// the dashboard.
+package guestbook
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"webring/internal/notify"
+	"webring/internal/validate"
+)
+
+const (
+	maxNameLength    = 100
+	maxMessageLength = 2000
+	// maxLinks is how many links a message may contain; spam usually has
+	// many more.
+	maxLinks = 2
+	// maxPendingPerIP is how many entries a client may have waiting for
+	// approval within pendingWindow.
+	maxPendingPerIP = 3
+	pendingWindow   = time.Hour
+)
+
+var (
+	// ErrInvalid wraps the errors caused by bad input.
+	ErrInvalid = errors.New("invalid entry")
+	// ErrTooMany is returned when a client has too many entries waiting for
+	// approval.
+	ErrTooMany = errors.New("too many entries waiting for approval, please try again later")
+)
+
+var linkRegex = regexp.MustCompile(`(?i)https?://|www\.`)
+
+type Entry struct {
+	ID         int
+	Name       string
+	Website    string
+	Message    string
+	IP         string
+	CreatedAt  time.Time
+	ApprovedAt *time.Time
+}
+
+// Submit adds an entry to the moderation queue and notifies the admins
+// through the guestbook_entry notification rules.
+func Submit(db *sql.DB, notifier *notify.Notifier, name, website, message, ip string) error {
+	name = strings.TrimSpace(name)
+	message = strings.TrimSpace(message)
+	if name == "" || message == "" {
+		return fmt.Errorf("%w: name and message are required", ErrInvalid)
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return fmt.Errorf("%w: name must be at most %d characters", ErrInvalid, maxNameLength)
+	}
+	if utf8.RuneCountInString(message) > maxMessageLength {
+		return fmt.Errorf("%w: message must be at most %d characters", ErrInvalid, maxMessageLength)
+	}
+	if len(linkRegex.FindAllString(message, -1)) > maxLinks {
+		return fmt.Errorf("%w: message may contain at most %d links", ErrInvalid, maxLinks)
+	}
+	website, err := validate.SupportURL(website)
+	if err != nil {
+		return fmt.Errorf("%w: website: %v", ErrInvalid, err)
+	}
+
+	var pending int
+	err = db.QueryRow("SELECT COUNT(*) FROM guestbook_entries WHERE ip = $1 AND approved_at IS NULL AND created_at > $2",
+		ip, time.Now().Add(-pendingWindow)).Scan(&pending)
+	if err != nil {
+		return err
+	}
+	if pending >= maxPendingPerIP {
+		return ErrTooMany
+	}
+
+	_, err = db.Exec("INSERT INTO guestbook_entries (name, website, message, ip) VALUES ($1, $2, $3, $4)", name, website, message, ip)
+	if err != nil {
+		return err
+	}
+
+	notifier.Notify(notify.Event{
+		Type:    notify.EventGuestbookEntry,
+		Message: fmt.Sprintf("%s wrote: %s", name, message),
+	})
+	return nil
+}
+
+// Approved returns up to limit approved entries, newest first.
+func Approved(db *sql.DB, limit int) ([]Entry, error) {
+	return list(db, "WHERE approved_at IS NOT NULL ORDER BY created_at DESC LIMIT $1", limit)
+}
+
+// List returns the entries waiting for approval, oldest first, followed by
+// the approved ones, newest first.
+func List(db *sql.DB) ([]Entry, error) {
+	return list(db, `ORDER BY approved_at IS NOT NULL, CASE WHEN approved_at IS NULL THEN created_at END, created_at DESC`)
+}
+
+func list(db *sql.DB, clause string, args ...interface{}) ([]Entry, error) {
+	rows, err := db.Query("SELECT id, name, website, message, ip, created_at, approved_at FROM guestbook_entries "+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.Name, &e.Website, &e.Message, &e.IP, &e.CreatedAt, &e.ApprovedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Approve publishes an entry.
+func Approve(db *sql.DB, id int) error {
+	_, err := db.Exec("UPDATE guestbook_entries SET approved_at = NOW() WHERE id = $1 AND approved_at IS NULL", id)
+	return err
+}
+
+// ApproveNewest publishes the most recent entry waiting for approval and
+// returns it, or nil when none is waiting.
+func ApproveNewest(db *sql.DB) (*Entry, error) {
+	var e Entry
+	err := db.QueryRow(`
+		UPDATE guestbook_entries SET approved_at = NOW()
+		WHERE id = (SELECT id FROM guestbook_entries WHERE approved_at IS NULL ORDER BY created_at DESC LIMIT 1 FOR UPDATE SKIP LOCKED)
+		RETURNING id, name, website, message, ip, created_at, approved_at`).
+		Scan(&e.ID, &e.Name, &e.Website, &e.Message, &e.IP, &e.CreatedAt, &e.ApprovedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Remove rejects a waiting entry or takes down a published one.
+func Remove(db *sql.DB, id int) error {
+	_, err := db.Exec("DELETE FROM guestbook_entries WHERE id = $1", id)
+	return err
+}
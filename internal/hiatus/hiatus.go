@@ -0,0 +1,116 @@
+// Package hiatus lets members take a break from the ring until a chosen
+// date. A site on hiatus is skipped in navigation and not checked. Its return
+// and a reminder the day before are stored as jobs, so they happen on time
+// across restarts and only on one instance.
+package hiatus
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"webring/internal/database"
+	"webring/internal/jobs"
+	"webring/internal/notify"
+	"webring/internal/outbox"
+)
+
+// Job kinds.
+const (
+	// KindReturn ends the hiatus of a site.
+	KindReturn = "hiatus_return"
+	// KindReminder tells the owner that their site returns the next day.
+	KindReminder = "hiatus_reminder"
+)
+
+const (
+	// MaxLength is how far ahead a hiatus may end.
+	MaxLength = 365 * 24 * time.Hour
+	// reminderLead is how long before the return the owner is reminded.
+	reminderLead = 24 * time.Hour
+)
+
+var (
+	ErrNotFound = errors.New("site not found")
+	// ErrInvalid is returned for return dates in the past or too far ahead.
+	ErrInvalid = errors.New("invalid return date")
+)
+
+// Set puts a site on hiatus until the given time, replacing the jobs of an
+// earlier hiatus. A nil until ends the hiatus right away; the return still
+// goes through the job, so the site is checked before the next round.
+func Set(db *sql.DB, siteID int, until *time.Time) error {
+	now := time.Now()
+	if until != nil && (!until.After(now) || until.Sub(now) > MaxLength) {
+		return fmt.Errorf("%w: the return must be in the future and at most %d days ahead", ErrInvalid, int(MaxLength.Hours()/24))
+	}
+
+	return database.InTx(db, func(tx *sql.Tx) error {
+		var current *time.Time
+		err := tx.QueryRow("SELECT hiatus_until FROM sites WHERE id = $1 FOR UPDATE", siteID).Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if until == nil {
+			if current == nil {
+				return nil
+			}
+			until = &now
+		}
+
+		if _, err := tx.Exec("UPDATE sites SET hiatus_until = $1 WHERE id = $2", *until, siteID); err != nil {
+			return err
+		}
+		if err := jobs.Cancel(tx, siteID, KindReturn, KindReminder); err != nil {
+			return err
+		}
+		if remindAt := until.Add(-reminderLead); remindAt.After(now) {
+			if err := jobs.Schedule(tx, KindReminder, siteID, remindAt, nil); err != nil {
+				return err
+			}
+		}
+		return jobs.Schedule(tx, KindReturn, siteID, *until, nil)
+	})
+}
+
+// Register adds the handlers of the hiatus jobs to w. returned is called
+// after a site's hiatus ended, to put it back into the ring and check it.
+func Register(w *jobs.Worker, db *sql.DB, returned func(siteID int)) {
+	w.Handle(KindReturn, func(j jobs.Job) error {
+		res, err := db.Exec("UPDATE sites SET hiatus_until = NULL WHERE id = $1 AND hiatus_until <= NOW()", j.SiteID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			log.Printf("Site %d is back from hiatus", j.SiteID)
+		}
+		returned(j.SiteID)
+		return nil
+	})
+	w.Handle(KindReminder, func(j jobs.Job) error {
+		return database.InTx(db, func(tx *sql.Tx) error {
+			return remind(tx, j.SiteID)
+		})
+	})
+}
+
+// remind queues the hiatus_ending notification for a site.
+func remind(tx *sql.Tx, siteID int) error {
+	e := notify.Event{Type: notify.EventHiatusEnding, SiteID: siteID}
+	var until *time.Time
+	err := tx.QueryRow("SELECT name, url, hiatus_until FROM sites WHERE id = $1", siteID).Scan(&e.SiteName, &e.SiteURL, &until)
+	if err != nil {
+		return err
+	}
+	// The hiatus was ended early
+	if until == nil {
+		return nil
+	}
+	e.Message = "back in the ring on " + until.Format("2006-01-02 15:04 MST")
+	return outbox.Enqueue(tx, outbox.EventNotify, e)
+}
@@ -0,0 +1,152 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultUserAgent  = "webring"
+	defaultContactURL = "https://github.com/Alexander-D-Karpov/webring"
+)
+
+// UserAgent is sent with every outgoing request: OUTBOUND_USER_AGENT followed
+// by OUTBOUND_CONTACT_URL, so site operators can find out who is crawling
+// them and how to reach the ring's operators.
+var UserAgent = sync.OnceValue(func() string {
+	agent := os.Getenv("OUTBOUND_USER_AGENT")
+	if agent == "" {
+		agent = defaultUserAgent
+	}
+	contact := os.Getenv("OUTBOUND_CONTACT_URL")
+	if contact == "" {
+		contact = defaultContactURL
+	}
+	return agent + " (+" + contact + ")"
+})
+
+type Options struct {
+	Timeout time.Duration
+	// Proxy routes requests through the given proxy, e.g. socks5://127.0.0.1:9050.
+	Proxy *url.URL
+	// RespectRobots makes requests to URLs disallowed by the site's
+	// robots.txt fail with ErrDisallowed. Use it for scraping members'
+	// sites, but not for uptime checks.
+	RespectRobots bool
+}
+
+var (
+	transportsMu sync.Mutex
+	// transports are shared per proxy so that connections are reused across
+	// clients and across calls to New.
+	transports = make(map[string]*http.Transport)
+)
+
+func transportFor(proxy *url.URL) *http.Transport {
+	key := ""
+	if proxy != nil {
+		key = proxy.String()
+	}
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+
+	if t, ok := transports[key]; ok {
+		return t
+	}
+	// Without a proxy, requests go out directly even if HTTP_PROXY is set: the
+	// uptime checker relies on that to tell a dead proxy from a dead site.
+	t := &http.Transport{
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if proxy != nil {
+		t.Proxy = http.ProxyURL(proxy)
+	}
+	transports[key] = t
+	return t
+}
+
+// New returns a client whose requests are counted under name in Stats.
+func New(name string, opts Options) *http.Client {
+	var transport http.RoundTripper = &instrumented{
+		name: name,
+		next: transportFor(opts.Proxy),
+	}
+	if opts.RespectRobots {
+		transport = &robotsTransport{next: transport}
+	}
+	return &http.Client{Timeout: opts.Timeout, Transport: transport}
+}
+
+// Metrics are the request counters of one named client.
+type Metrics struct {
+	Name     string        `json:"name"`
+	Requests int64         `json:"requests"`
+	Errors   int64         `json:"errors"`
+	Total    time.Duration `json:"-"`
+	// AvgMillis is the mean time to response headers.
+	AvgMillis float64 `json:"avg_ms"`
+}
+
+var (
+	metricsMu sync.Mutex
+	metrics   = make(map[string]*Metrics)
+)
+
+// Stats returns a snapshot of the counters of every client, sorted by name.
+func Stats() []Metrics {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	stats := make([]Metrics, 0, len(metrics))
+	for _, m := range metrics {
+		s := *m
+		if s.Requests > 0 {
+			s.AvgMillis = float64(s.Total.Milliseconds()) / float64(s.Requests)
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+func record(name string, elapsed time.Duration, failed bool) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	m, ok := metrics[name]
+	if !ok {
+		m = &Metrics{Name: name}
+		metrics[name] = m
+	}
+	m.Requests++
+	m.Total += elapsed
+	if failed {
+		m.Errors++
+	}
+}
+
+type instrumented struct {
+	name string
+	next http.RoundTripper
+}
+
+func (t *instrumented) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		// RoundTrippers must not modify the caller's request
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", UserAgent())
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	record(t.name, time.Since(start), err != nil)
+	return resp, err
+}
@@ -0,0 +1,230 @@
+package httpclient
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDisallowed is returned by clients created with RespectRobots for URLs
+// that the site's robots.txt disallows.
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+const (
+	robotsTTL = 24 * time.Hour
+	// Unreachable robots.txt files are retried sooner
+	robotsErrorTTL = time.Hour
+	// RFC 9309 asks crawlers to parse at least 500 KiB
+	maxRobotsSize     = 500 << 10
+	maxRobotsRedirect = 5
+)
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsPolicy holds the rules of one host that apply to our user agent.
+type robotsPolicy struct {
+	rules      []robotsRule
+	disallowed bool // robots.txt was unreachable, so everything is disallowed
+	expires    time.Time
+}
+
+var (
+	robotsMu    sync.Mutex
+	robotsCache = make(map[string]*robotsPolicy)
+)
+
+// robotsTransport checks every request against the robots.txt of its host,
+// which is fetched through next and cached per scheme and host.
+type robotsTransport struct {
+	next http.RoundTripper
+}
+
+func (t *robotsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path != "/robots.txt" {
+		policy := t.policy(req)
+		if !policy.allowed(req.URL.RequestURI()) {
+			return nil, fmt.Errorf("%s: %w", req.URL, ErrDisallowed)
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+func (t *robotsTransport) policy(req *http.Request) *robotsPolicy {
+	key := req.URL.Scheme + "://" + req.URL.Host
+
+	robotsMu.Lock()
+	policy, ok := robotsCache[key]
+	robotsMu.Unlock()
+	if ok && time.Now().Before(policy.expires) {
+		return policy
+	}
+
+	policy = t.fetch(req, key+"/robots.txt")
+	robotsMu.Lock()
+	robotsCache[key] = policy
+	robotsMu.Unlock()
+	return policy
+}
+
+// fetch downloads and parses a robots.txt file. As in RFC 9309, a missing
+// file (4xx) allows everything and an unreachable one (5xx or a network
+// error) disallows everything.
+func (t *robotsTransport) fetch(orig *http.Request, robotsURL string) *robotsPolicy {
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: t.next,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRobotsRedirect {
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+
+	req, err := http.NewRequestWithContext(orig.Context(), http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return &robotsPolicy{disallowed: true, expires: time.Now().Add(robotsErrorTTL)}
+	}
+	req.Header.Set("User-Agent", UserAgent())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return &robotsPolicy{disallowed: true, expires: time.Now().Add(robotsErrorTTL)}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		return &robotsPolicy{disallowed: true, expires: time.Now().Add(robotsErrorTTL)}
+	case resp.StatusCode >= 400:
+		return &robotsPolicy{expires: time.Now().Add(robotsTTL)}
+	case resp.StatusCode != http.StatusOK:
+		// Redirect loops and other oddities
+		return &robotsPolicy{disallowed: true, expires: time.Now().Add(robotsErrorTTL)}
+	}
+
+	rules := parseRobots(io.LimitReader(resp.Body, maxRobotsSize), productToken())
+	return &robotsPolicy{rules: rules, expires: time.Now().Add(robotsTTL)}
+}
+
+// productToken is the name matched against User-agent lines: the first word
+// of our user agent, without a version.
+func productToken() string {
+	token, _, _ := strings.Cut(UserAgent(), " ")
+	token, _, _ = strings.Cut(token, "/")
+	return strings.ToLower(token)
+}
+
+// parseRobots returns the rules of the groups naming agent, or of the "*"
+// groups if none do.
+func parseRobots(r io.Reader, agent string) []robotsRule {
+	var (
+		own, wildcard []robotsRule
+		// Agents of the group being read; consecutive User-agent lines
+		// start a single group
+		agents  []string
+		inRules bool
+		// A group naming us replaces the "*" groups even when it is empty
+		named bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxRobotsSize)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				agents = nil
+				inRules = false
+			}
+			value = strings.ToLower(value)
+			agents = append(agents, value)
+			if value == agent {
+				named = true
+			}
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				// "Disallow:" with no path allows everything
+				continue
+			}
+			rule := robotsRule{allow: key == "allow", pattern: value}
+			for _, a := range agents {
+				switch a {
+				case agent:
+					own = append(own, rule)
+				case "*":
+					wildcard = append(wildcard, rule)
+				}
+			}
+		}
+	}
+
+	if named {
+		return own
+	}
+	return wildcard
+}
+
+// allowed applies the longest matching rule to path, with Allow winning ties.
+func (p *robotsPolicy) allowed(path string) bool {
+	if p.disallowed {
+		return false
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	allow, longest := true, -1
+	for _, rule := range p.rules {
+		if !matchRobots(rule.pattern, path) {
+			continue
+		}
+		n := len(rule.pattern)
+		if n > longest || (n == longest && rule.allow) {
+			allow, longest = rule.allow, n
+		}
+	}
+	return allow
+}
+
+// matchRobots matches a path against a robots.txt pattern, where * matches
+// any sequence and a trailing $ anchors the end of the path.
+func matchRobots(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		last := i == len(parts)-2
+		if last && anchored {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	return !anchored || rest == ""
+}
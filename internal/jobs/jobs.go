@@ -0,0 +1,306 @@
+// Package jobs runs scheduled work from the jobs table: one-off jobs due at
+// a point in time, such as a member returning from hiatus, and periodic work
+// on the database that used to run on a ticker in every instance, such as
+// the uptime check rounds. A worker leases the jobs it runs and renews the
+// lease while they run, so each job runs on one instance at a time, and the
+// jobs of an instance that died are picked up by another once their lease
+// has expired. Work on the memory of an instance, like flushing its counters,
+// still runs on a ticker in each instance.
+package jobs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// lease is how long a claimed job stays reserved for its worker without
+	// being renewed.
+	lease         = time.Minute
+	maxConcurrent = 4
+	maxAttempts   = 10
+	maxBackoff    = time.Hour
+	// Failed one-off jobs are kept this long for debugging
+	retention = 7 * 24 * time.Hour
+)
+
+// Execer is satisfied by *sql.Tx and *sql.DB.
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Job is a claimed job.
+type Job struct {
+	ID   int64
+	Kind string
+	// SiteID is 0 for jobs that are not about a site.
+	SiteID   int
+	Payload  json.RawMessage
+	Attempts int
+
+	periodic bool
+}
+
+// Handler runs a job. Returning an error retries one-off jobs with a backoff;
+// periodic jobs just run again at their next time. A job may run again after
+// a crash, so handlers must be safe to run more than once.
+type Handler func(j Job) error
+
+// Schedule adds a one-off job due at the given time. Pass the transaction
+// making the change the job belongs to. siteID is 0 for jobs not about a
+// site; the jobs of a site are removed with it.
+func Schedule(tx Execer, kind string, siteID int, at time.Time, payload interface{}) error {
+	data := []byte("{}")
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+	var site interface{}
+	if siteID != 0 {
+		site = siteID
+	}
+	_, err := tx.Exec("INSERT INTO jobs (kind, site_id, run_at, payload) VALUES ($1, $2, $3, $4)", kind, site, at, data)
+	return err
+}
+
+// Cancel removes the pending jobs of the given kinds for a site.
+func Cancel(tx Execer, siteID int, kinds ...string) error {
+	_, err := tx.Exec("DELETE FROM jobs WHERE site_id = $1 AND kind = ANY($2) AND failed_at IS NULL", siteID, pq.Array(kinds))
+	return err
+}
+
+// Worker runs the due jobs of the kinds it has handlers for.
+type Worker struct {
+	db *sql.DB
+	// id tells the leases of this worker apart from other instances'
+	id string
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	periodic map[string]time.Duration
+}
+
+func NewWorker(db *sql.DB) *Worker {
+	host, _ := os.Hostname()
+	return &Worker{
+		db:       db,
+		id:       fmt.Sprintf("%s-%d", host, os.Getpid()),
+		handlers: make(map[string]Handler),
+		periodic: make(map[string]time.Duration),
+	}
+}
+
+// Handle registers h for one-off jobs of the given kind.
+func (w *Worker) Handle(kind string, h Handler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[kind] = h
+}
+
+// Every runs fn every interval on one of the instances, starting one
+// interval after the job is first registered. Restarts keep the schedule.
+func (w *Worker) Every(kind string, interval time.Duration, fn func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[kind] = func(Job) error {
+		fn()
+		return nil
+	}
+	w.periodic[kind] = interval
+}
+
+// Start registers the periodic jobs and runs due jobs, polling every
+// interval. It blocks, so run it in a goroutine.
+func (w *Worker) Start(interval time.Duration) {
+	if err := w.schedulePeriodic(); err != nil {
+		log.Printf("Error scheduling periodic jobs: %v", err)
+	}
+
+	slots := make(chan struct{}, maxConcurrent)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastPrune := time.Now()
+
+	for range ticker.C {
+		free := cap(slots) - len(slots)
+		if free > 0 {
+			jobs, err := w.claim(free)
+			if err != nil {
+				log.Printf("Error claiming jobs: %v", err)
+			}
+			for _, j := range jobs {
+				slots <- struct{}{}
+				go func(j Job) {
+					defer func() { <-slots }()
+					w.run(j)
+				}(j)
+			}
+		}
+
+		if time.Since(lastPrune) > time.Hour {
+			w.prune()
+			lastPrune = time.Now()
+		}
+	}
+}
+
+// schedulePeriodic adds the rows of the periodic jobs, and moves their next
+// run closer when their interval got shorter.
+func (w *Worker) schedulePeriodic() error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for kind, interval := range w.periodic {
+		_, err := w.db.Exec(`
+			INSERT INTO jobs (kind, run_at, interval_seconds) VALUES ($1, NOW() + $2 * INTERVAL '1 second', $2)
+			ON CONFLICT (kind) WHERE interval_seconds IS NOT NULL DO UPDATE SET
+				interval_seconds = EXCLUDED.interval_seconds,
+				run_at = LEAST(jobs.run_at, EXCLUDED.run_at)`,
+			kind, int(interval.Seconds()))
+		if err != nil {
+			return fmt.Errorf("%s: %w", kind, err)
+		}
+	}
+	return nil
+}
+
+// claim leases up to limit due jobs. Periodic jobs are moved to their next
+// run right away, so a slow run doesn't shift the schedule.
+func (w *Worker) claim(limit int) ([]Job, error) {
+	w.mu.RLock()
+	kinds := make([]string, 0, len(w.handlers))
+	for kind := range w.handlers {
+		kinds = append(kinds, kind)
+	}
+	w.mu.RUnlock()
+
+	rows, err := w.db.Query(`
+		UPDATE jobs SET
+			locked_by = $1,
+			locked_until = NOW() + $2 * INTERVAL '1 second',
+			attempts = attempts + 1,
+			run_at = CASE WHEN interval_seconds IS NULL THEN run_at ELSE NOW() + interval_seconds * INTERVAL '1 second' END
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE kind = ANY($3) AND run_at <= NOW() AND failed_at IS NULL
+			  AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY run_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED)
+		RETURNING id, kind, COALESCE(site_id, 0), payload, attempts, interval_seconds IS NOT NULL`,
+		w.id, lease.Seconds(), pq.Array(kinds), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	var jobs []Job
+	for rows.Next() {
+		var j Job
+		var payload []byte
+		if err := rows.Scan(&j.ID, &j.Kind, &j.SiteID, &payload, &j.Attempts, &j.periodic); err != nil {
+			return nil, err
+		}
+		j.Payload = payload
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// run runs a claimed job, renewing its lease until the handler returns, and
+// records the outcome.
+func (w *Worker) run(j Job) {
+	done := make(chan struct{})
+	go w.renew(j, done)
+	err := w.handle(j)
+	close(done)
+
+	var query string
+	var args []interface{}
+	switch {
+	case err == nil && j.periodic:
+		query = "UPDATE jobs SET locked_by = NULL, locked_until = NULL, attempts = 0, last_error = NULL WHERE id = $1 AND locked_by = $2"
+		args = []interface{}{j.ID, w.id}
+	case err == nil:
+		query = "DELETE FROM jobs WHERE id = $1 AND locked_by = $2"
+		args = []interface{}{j.ID, w.id}
+	case j.periodic:
+		log.Printf("Error running job %s: %v", j.Kind, err)
+		query = "UPDATE jobs SET locked_by = NULL, locked_until = NULL, last_error = $3 WHERE id = $1 AND locked_by = $2"
+		args = []interface{}{j.ID, w.id, err.Error()}
+	case j.Attempts >= maxAttempts:
+		log.Printf("Giving up on job %d (%s) after %d attempts: %v", j.ID, j.Kind, j.Attempts, err)
+		query = "UPDATE jobs SET locked_by = NULL, locked_until = NULL, last_error = $3, failed_at = NOW() WHERE id = $1 AND locked_by = $2"
+		args = []interface{}{j.ID, w.id, err.Error()}
+	default:
+		log.Printf("Error running job %d (%s), will retry: %v", j.ID, j.Kind, err)
+		query = "UPDATE jobs SET locked_by = NULL, locked_until = NULL, last_error = $3, run_at = $4 WHERE id = $1 AND locked_by = $2"
+		args = []interface{}{j.ID, w.id, err.Error(), time.Now().Add(backoff(j.Attempts))}
+	}
+	if _, err := w.db.Exec(query, args...); err != nil {
+		log.Printf("Error recording the outcome of job %d (%s): %v", j.ID, j.Kind, err)
+	}
+}
+
+// renew extends the lease of a running job until done is closed.
+func (w *Worker) renew(j Job, done <-chan struct{}) {
+	ticker := time.NewTicker(lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			_, err := w.db.Exec("UPDATE jobs SET locked_until = NOW() + $3 * INTERVAL '1 second' WHERE id = $1 AND locked_by = $2",
+				j.ID, w.id, lease.Seconds())
+			if err != nil {
+				log.Printf("Error renewing the lease of job %d (%s): %v", j.ID, j.Kind, err)
+			}
+		}
+	}
+}
+
+// handle runs the handler of a job. Handlers that panic count as failed, so
+// one bad job can't stop the worker.
+func (w *Worker) handle(j Job) (err error) {
+	w.mu.RLock()
+	h := w.handlers[j.Kind]
+	w.mu.RUnlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return h(j)
+}
+
+// backoff returns the delay before the next attempt: 10s, 20s, 40s, ... up
+// to maxBackoff.
+func backoff(attempts int) time.Duration {
+	d := 10 * time.Second << attempts
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+func (w *Worker) prune() {
+	_, err := w.db.Exec("DELETE FROM jobs WHERE failed_at < $1", time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("Error pruning failed jobs: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+// Package markdown renders the small subset of Markdown used for the ring's
+// homepage blocks: headings, paragraphs, lists, block quotes, code, emphasis
+// and links. Raw HTML is escaped, so operators can't break the page layout.
+package markdown
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	bulletPattern  = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	numberPattern  = regexp.MustCompile(`^\d+[.)]\s+(.*)$`)
+
+	codeSpanPattern = regexp.MustCompile("`([^`]+)`")
+	linkPattern     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strongPattern   = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	emPattern       = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+)
+
+// Render converts src to HTML.
+func Render(src string) template.HTML {
+	var b strings.Builder
+	var paragraph []string
+	var list string // "ul" or "ol" while inside a list
+	var quote []string
+	inCode := false
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			b.WriteString("<p>" + inline(strings.Join(paragraph, " ")) + "</p>\n")
+			paragraph = nil
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			b.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+	flushQuote := func() {
+		if len(quote) > 0 {
+			b.WriteString("<blockquote>" + string(Render(strings.Join(quote, "\n"))) + "</blockquote>\n")
+			quote = nil
+		}
+	}
+	flush := func() {
+		flushParagraph()
+		closeList()
+		flushQuote()
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			if inCode {
+				b.WriteString("</code></pre>\n")
+			} else {
+				flush()
+				b.WriteString("<pre><code>")
+			}
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			b.WriteString(html.EscapeString(line) + "\n")
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, ">") {
+			flushParagraph()
+			closeList()
+			quote = append(quote, strings.TrimSpace(strings.TrimPrefix(trimmed, ">")))
+			continue
+		}
+		flushQuote()
+
+		switch {
+		case trimmed == "":
+			flush()
+		case headingPattern.MatchString(trimmed):
+			flush()
+			m := headingPattern.FindStringSubmatch(trimmed)
+			level := string(rune('0' + len(m[1])))
+			b.WriteString("<h" + level + ">" + inline(m[2]) + "</h" + level + ">\n")
+		case bulletPattern.MatchString(trimmed):
+			startList(&b, &list, "ul", flushParagraph)
+			b.WriteString("<li>" + inline(bulletPattern.FindStringSubmatch(trimmed)[1]) + "</li>\n")
+		case numberPattern.MatchString(trimmed):
+			startList(&b, &list, "ol", flushParagraph)
+			b.WriteString("<li>" + inline(numberPattern.FindStringSubmatch(trimmed)[1]) + "</li>\n")
+		default:
+			closeList()
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	if inCode {
+		b.WriteString("</code></pre>\n")
+	}
+	flush()
+
+	return template.HTML(b.String())
+}
+
+// startList opens a list of the given kind, closing a list of the other kind
+// first.
+func startList(b *strings.Builder, list *string, kind string, flushParagraph func()) {
+	flushParagraph()
+	if *list == kind {
+		return
+	}
+	if *list != "" {
+		b.WriteString("</" + *list + ">\n")
+	}
+	b.WriteString("<" + kind + ">\n")
+	*list = kind
+}
+
+// inline escapes text and applies code spans, links and emphasis.
+func inline(text string) string {
+	// Code spans are set aside first so their content is left alone
+	var spans []string
+	text = codeSpanPattern.ReplaceAllStringFunc(text, func(m string) string {
+		spans = append(spans, "<code>"+html.EscapeString(m[1:len(m)-1])+"</code>")
+		return "\x00" + strconv.Itoa(len(spans)-1) + "\x00"
+	})
+
+	text = html.EscapeString(text)
+	text = linkPattern.ReplaceAllStringFunc(text, func(m string) string {
+		parts := linkPattern.FindStringSubmatch(m)
+		href := html.UnescapeString(parts[2])
+		if !safeURL(href) {
+			return parts[1]
+		}
+		return `<a href="` + html.EscapeString(href) + `">` + parts[1] + "</a>"
+	})
+	text = strongPattern.ReplaceAllString(text, "<strong>$1$2</strong>")
+	text = emPattern.ReplaceAllString(text, "<em>$1$2</em>")
+
+	for i, span := range spans {
+		text = strings.Replace(text, "\x00"+strconv.Itoa(i)+"\x00", span, 1)
+	}
+	return text
+}
+
+// safeURL accepts web and mail links and links relative to this service.
+func safeURL(href string) bool {
+	lower := strings.ToLower(href)
+	for _, prefix := range []string{"https://", "http://", "mailto:", "/", "#"} {
+		if strings.HasPrefix(lower, prefix) {
+			return !strings.HasPrefix(lower, "//")
+		}
+	}
+	return false
+}
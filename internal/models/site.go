@@ -1,23 +1,103 @@
 package models
 
+import "time"
+
 type Site struct {
-	ID        int     `json:"id"`
-	Name      string  `json:"name"`
-	URL       string  `json:"url"`
-	IsUp      bool    `json:"is_up"`
-	LastCheck float64 `json:"last_check"`
-	Favicon   *string `json:"favicon"`
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	URL       string     `json:"url"`
+	IsUp      bool       `json:"is_up"`
+	LastCheck float64    `json:"last_check"`
+	Favicon   *string    `json:"favicon"`
+	DownSince *time.Time `json:"down_since"`
+	Notes     string     `json:"notes"`
+
+	DisplayOrder *int    `json:"display_order"`
+	SupportURL   *string `json:"support_url"`
+
+	// Description and FeedURL are set by the owner.
+	Description *string `json:"description"`
+	FeedURL     *string `json:"feed_url"`
+
+	CheckType   string `json:"check_type"`
+	CheckTarget string `json:"check_target"`
+
+	WebhookURL    *string `json:"webhook_url"`
+	WebhookSecret *string `json:"-"`
+
+	// MonitorURL receives every check result, as a webhook or a ping URL
+	// depending on MonitorKind.
+	MonitorURL  *string `json:"monitor_url"`
+	MonitorKind string  `json:"monitor_kind"`
+
+	// During the maintenance window the site is still checked, but going down
+	// does not notify anyone. With MaintenanceKeepInRing it also stays in
+	// the ring. InMaintenance is computed when the site is loaded.
+	MaintenanceStart      *time.Time `json:"maintenance_start"`
+	MaintenanceEnd        *time.Time `json:"maintenance_end"`
+	MaintenanceKeepInRing bool       `json:"maintenance_keep_in_ring"`
+	InMaintenance         bool       `json:"in_maintenance"`
+
+	// HiatusUntil is set while the owner has taken the site out of the ring;
+	// it is neither navigated to nor checked until then.
+	HiatusUntil *time.Time `json:"hiatus_until"`
+
+	// HTTPSIssue explains why the site fails the HTTPS requirement.
+	HTTPSIssue      *string    `json:"https_issue"`
+	HTTPSIssueSince *time.Time `json:"https_issue_since"`
 }
 
 type PublicSite struct {
-	ID      int     `json:"id"`
-	Name    string  `json:"name"`
-	URL     string  `json:"url"`
-	Favicon *string `json:"favicon"`
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	URL        string  `json:"url"`
+	Favicon    *string `json:"favicon"`
+	SupportURL *string `json:"support_url,omitempty"`
+	// Maintenance is set for members kept in the ring during maintenance.
+	Maintenance bool `json:"maintenance,omitempty"`
+}
+
+// OfflineSite is a member that is currently down but has an archived copy.
+type OfflineSite struct {
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	ArchiveURL string `json:"archive_url"`
 }
 
 type SiteData struct {
-	Prev PublicSite `json:"prev"`
-	Curr PublicSite `json:"curr"`
-	Next PublicSite `json:"next"`
+	Prev     PublicSite `json:"prev"`
+	Curr     PublicSite `json:"curr"`
+	Next     PublicSite `json:"next"`
+	Position int        `json:"position"`
+	Total    int        `json:"total"`
+	// First and Last are set at the ends of a ring that doesn't wrap around,
+	// where Prev or Next lead back to the directory.
+	First bool `json:"first,omitempty"`
+	Last  bool `json:"last,omitempty"`
+	// Token allows navigating from Curr when navigation tokens are required.
+	Token string `json:"token,omitempty"`
+}
+
+// Neighbors is a site together with the chains of sites that follow and
+// precede it, closest first.
+type Neighbors struct {
+	Curr     PublicSite   `json:"curr"`
+	Next     []PublicSite `json:"next"`
+	Prev     []PublicSite `json:"prev"`
+	Position int          `json:"position"`
+	Total    int          `json:"total"`
+}
+
+// RingMember is a site together with its precomputed neighbours. Prev and
+// Next are 0 at the ends of a ring that doesn't wrap around.
+type RingMember struct {
+	PublicSite
+	Position int `json:"position"`
+	Prev     int `json:"prev"`
+	Next     int `json:"next"`
+}
+
+type RingData struct {
+	Total int          `json:"total"`
+	Sites []RingMember `json:"sites"`
 }
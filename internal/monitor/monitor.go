@@ -0,0 +1,100 @@
+// Package monitor forwards uptime check results to external monitoring
+// systems configured per site, so operators can watch members from the
+// monitoring stack they already have.
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"webring/internal/httpclient"
+)
+
+// Kinds of monitors.
+const (
+	// KindWebhook receives every result as a JSON POST.
+	KindWebhook = "webhook"
+	// KindPing is a healthchecks.io-style ping URL: it is requested after
+	// every successful check, and with /fail appended after failed ones.
+	KindPing = "ping"
+)
+
+func ValidKind(kind string) bool {
+	return kind == KindWebhook || kind == KindPing
+}
+
+// Event types.
+const (
+	EventSiteUp   = "site_up"
+	EventSiteDown = "site_down"
+	// EventHeartbeat is a check that did not change the site's state.
+	EventHeartbeat = "heartbeat"
+)
+
+// Event is the JSON body sent to webhook monitors.
+type Event struct {
+	Type     string    `json:"type"`
+	SiteID   int       `json:"site_id"`
+	SiteName string    `json:"site_name"`
+	SiteURL  string    `json:"site_url"`
+	IsUp     bool      `json:"is_up"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+	// ResponseTime of the check in seconds.
+	ResponseTime float64 `json:"response_time"`
+	// Maintenance is set during the site's maintenance window.
+	Maintenance bool `json:"maintenance,omitempty"`
+}
+
+// Delivery is an event together with where to send it, as queued in the
+// outbox for state changes.
+type Delivery struct {
+	Kind  string `json:"kind"`
+	URL   string `json:"url"`
+	Event Event  `json:"event"`
+}
+
+// Send delivers an event to a monitor.
+func Send(d Delivery) error {
+	client := httpclient.New("monitor", httpclient.Options{Timeout: 10 * time.Second})
+
+	var req *http.Request
+	var err error
+	switch d.Kind {
+	case KindPing:
+		target := d.URL
+		if !d.Event.IsUp {
+			target = strings.TrimRight(target, "/") + "/fail"
+		}
+		// Ping services show the body as the check's log
+		req, err = http.NewRequest("POST", target, strings.NewReader(d.Event.Error))
+	default:
+		body, merr := json.Marshal(d.Event)
+		if merr != nil {
+			return merr
+		}
+		req, err = http.NewRequest("POST", d.URL, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Webring-Event", d.Event.Type)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("monitor returned status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package navtoken
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"webring/internal/clock"
+)
+
+const defaultTTL = time.Hour
+
+var (
+	ErrInvalid = errors.New("invalid navigation token")
+	ErrExpired = errors.New("navigation token expired")
+)
+
+// Signer issues and verifies short-lived tokens that allow navigating from
+// one member. Tokens are handed to embedding pages with the site data, so
+// only clients that loaded a member's widget can follow its links.
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+
+	// Clock dates the tokens. Tests replace it to expire tokens without
+	// waiting.
+	Clock clock.Clock
+}
+
+// New reads NAV_TOKEN_SECRET and NAV_TOKEN_TTL. Without a secret a random
+// one is generated, which invalidates outstanding tokens on restart.
+func New() *Signer {
+	secret := []byte(os.Getenv("NAV_TOKEN_SECRET"))
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("Failed to generate navigation token secret: %v", err)
+		}
+	}
+
+	ttl, err := time.ParseDuration(os.Getenv("NAV_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &Signer{secret: secret, ttl: ttl, Clock: clock.Real}
+}
+
+// Issue returns a token for navigating from siteID.
+func (s *Signer) Issue(siteID int) string {
+	payload := fmt.Sprintf("%d.%d", siteID, s.Clock.Now().Add(s.ttl).Unix())
+	return payload + "." + s.sign(payload)
+}
+
+// Verify checks that token was issued for siteID and has not expired.
+func (s *Signer) Verify(token string, siteID int) error {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return ErrInvalid
+	}
+	payload, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(s.sign(payload))) {
+		return ErrInvalid
+	}
+
+	id, expires, ok := strings.Cut(payload, ".")
+	if !ok || id != strconv.Itoa(siteID) {
+		return ErrInvalid
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalid
+	}
+	if s.Clock.Now().Unix() > unix {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (s *Signer) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
@@ -0,0 +1,53 @@
+package navtoken
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"webring/internal/clock"
+)
+
+func testSigner() (*Signer, *clock.Fake) {
+	c := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	return &Signer{secret: []byte("secret"), ttl: time.Hour, Clock: c}, c
+}
+
+func TestVerify(t *testing.T) {
+	s, c := testSigner()
+	token := s.Issue(7)
+
+	if err := s.Verify(token, 7); err != nil {
+		t.Errorf("Verify = %v, want nil", err)
+	}
+	c.Advance(time.Hour)
+	if err := s.Verify(token, 7); err != nil {
+		t.Errorf("Verify at expiry = %v, want nil", err)
+	}
+	c.Advance(time.Second)
+	if err := s.Verify(token, 7); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify after expiry = %v, want ErrExpired", err)
+	}
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	s, _ := testSigner()
+	token := s.Issue(7)
+
+	other := &Signer{secret: []byte("other"), ttl: time.Hour, Clock: s.Clock}
+	for name, tc := range map[string]struct {
+		signer *Signer
+		token  string
+		site   int
+	}{
+		"other site":   {s, token, 8},
+		"other secret": {other, token, 7},
+		"extended":     {s, strings.Replace(token, ".", ".9", 1), 7},
+		"unsigned":     {s, "7", 7},
+	} {
+		if err := tc.signer.Verify(tc.token, tc.site); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: Verify = %v, want ErrInvalid", name, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+)
+
+// Channel delivers an event to a target (a URL, an address, ...).
+type Channel interface {
+	Send(target string, e Event) error
+}
+
+var channels = map[string]Channel{
+	"log":     logChannel{},
+	"webhook": webhookChannel{},
+	"email":   emailChannel{},
+	"issue":   issueChannel{},
+}
+
+type logChannel struct{}
+
+func (logChannel) Send(_ string, e Event) error {
+	log.Printf("[NOTIFY] %s", e.Text())
+	return nil
+}
+
+type webhookChannel struct{}
+
+func (webhookChannel) Send(target string, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return postJSON(target, body, nil)
+}
+
+// emailChannel sends plain-text mail through the SMTP server configured with
+// the SMTP_* environment variables.
+type emailChannel struct{}
+
+func (emailChannel) Send(target string, e Event) error {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return fmt.Errorf("SMTP_HOST is not configured")
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("SMTP_FROM")
+
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USER"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: [webring] %s\r\n\r\n%s\r\n", from, target, e.Subject(), e.Text())
+	return smtp.SendMail(host+":"+port, auth, from, []string{target}, []byte(msg))
+}
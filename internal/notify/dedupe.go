@@ -0,0 +1,189 @@
+package notify
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"webring/internal/database"
+	"webring/internal/outbox"
+	"webring/internal/settings"
+)
+
+// EventRepeated summarizes the notifications of one type for a site that
+// were held back during a dedupe window. It goes to the rules of the
+// summarized event type.
+const EventRepeated = "repeated"
+
+// Summary is queued in the outbox when the first notification of a dedupe
+// window is held back, and delivered when the window ends.
+type Summary struct {
+	SiteID      int       `json:"site_id"`
+	EventType   string    `json:"event_type"`
+	WindowStart time.Time `json:"window_start"`
+}
+
+// summarized reports whether held back events of the type get a summary.
+// Every site_up of a flapping site follows a site_down, so the site_down
+// summary already counts the flaps.
+func summarized(eventType string) bool {
+	return eventType != EventSiteUp
+}
+
+// Deliver is the outbox handler for notify events. It doesn't send anything
+// itself: every channel and webhook the event goes to is queued in the outbox
+// as a Send, in the same transaction as the dedupe bookkeeping, so each one
+// is retried on its own and none is sent twice.
+//
+// When a dedupe window is configured, only the first event of each type for
+// a site within the window is announced; later ones are held back and
+// counted, and a summary is sent when the window ends. Held back events still
+// reach rules with a min_duration, which already ignore short outages; those
+// sends are queued for when the duration is over.
+func (n *Notifier) Deliver(e Event) error {
+	if e.Time.IsZero() {
+		e.Time = n.Clock.Now()
+	}
+	window := n.st.Duration(settings.NotifyDedupeWindow, 0)
+
+	return database.InTx(n.db, func(tx *sql.Tx) error {
+		held := false
+		if window > 0 && e.SiteID != 0 {
+			var err error
+			if held, err = n.dedupe(tx, e, window); err != nil {
+				return err
+			}
+		}
+		return enqueue(tx, n.route(e, held))
+	})
+}
+
+// dedupe records the event in its dedupe window and reports whether it is
+// held back.
+func (n *Notifier) dedupe(tx *sql.Tx, e Event, window time.Duration) (bool, error) {
+	// Window starts are stored in UTC, so they compare with the clock
+	// whatever the time zone of the server
+	now := n.Clock.Now().UTC()
+	var start time.Time
+	var count int
+	err := tx.QueryRow("SELECT window_start, held FROM notification_dedupe WHERE site_id = $1 AND event_type = $2 FOR UPDATE",
+		e.SiteID, e.Type).Scan(&start, &count)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	if err == nil && inWindow(start, now, window) {
+		_, err := tx.Exec("UPDATE notification_dedupe SET held = held + 1 WHERE site_id = $1 AND event_type = $2", e.SiteID, e.Type)
+		if err != nil || count > 0 || !summarized(e.Type) {
+			return true, err
+		}
+		summary := Summary{SiteID: e.SiteID, EventType: e.Type, WindowStart: start}
+		return true, outbox.EnqueueAt(tx, outbox.EventNotifySummary, summary, start.Add(window))
+	}
+
+	if summarized(e.Type) && count > 0 {
+		// The previous window ended before its summary was delivered;
+		// that summary no longer matches the window and is dropped
+		if err := n.summarize(tx, e.SiteID, e.Type, count, window); err != nil {
+			return false, err
+		}
+	}
+	// Sites removed in the meantime get no row
+	_, err = tx.Exec(`
+		INSERT INTO notification_dedupe (site_id, event_type, window_start) SELECT id, $2, $3 FROM sites WHERE id = $1
+		ON CONFLICT (site_id, event_type) DO UPDATE SET window_start = EXCLUDED.window_start, held = 0`, e.SiteID, e.Type, now)
+	return false, err
+}
+
+// inWindow reports whether a dedupe window that started at start is still
+// open at now.
+func inWindow(start, now time.Time, window time.Duration) bool {
+	return now.Sub(start) < window
+}
+
+// DeliverSummary is the outbox handler for summaries. It queues the number
+// of events held back in the window, unless a newer window replaced it.
+func (n *Notifier) DeliverSummary(s Summary) error {
+	return database.InTx(n.db, func(tx *sql.Tx) error {
+		var count int
+		err := tx.QueryRow(`
+			SELECT held FROM notification_dedupe
+			WHERE site_id = $1 AND event_type = $2 AND window_start = $3 FOR UPDATE`,
+			s.SiteID, s.EventType, s.WindowStart).Scan(&count)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		_, err = tx.Exec("UPDATE notification_dedupe SET held = 0 WHERE site_id = $1 AND event_type = $2", s.SiteID, s.EventType)
+		if err != nil {
+			return err
+		}
+		return n.summarize(tx, s.SiteID, s.EventType, count, n.st.Duration(settings.NotifyDedupeWindow, 0))
+	})
+}
+
+// summarize queues an EventRepeated for count held back events of the type.
+func (n *Notifier) summarize(tx *sql.Tx, siteID int, eventType string, count int, window time.Duration) error {
+	e := Event{Type: EventRepeated, SiteID: siteID, Summarizes: eventType, Repeats: count, Time: n.Clock.Now()}
+	var isUp bool
+	err := tx.QueryRow("SELECT name, url, is_up FROM sites WHERE id = $1", siteID).Scan(&e.SiteName, &e.SiteURL, &isUp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	period := "the last " + formatWindow(window)
+	if window == 0 {
+		period = "the dedupe window"
+	}
+	if eventType == EventSiteDown {
+		state := "up"
+		if !isUp {
+			state = "down"
+		}
+		// The first outage of the window was announced on its own
+		e.Message = fmt.Sprintf("went down %d times in %s, currently %s", count+1, period, state)
+	} else {
+		e.Message = fmt.Sprintf("%d more %s notifications held back in %s", count, eventType, period)
+	}
+	// Summaries only go to rules without a min_duration, so nothing is delayed
+	return enqueue(tx, n.route(e, false))
+}
+
+// enqueue queues the deliveries in the outbox, the delayed ones for when
+// they are due.
+func enqueue(tx outbox.Execer, sends []Send) error {
+	for _, s := range sends {
+		var err error
+		if s.Delay > 0 {
+			err = outbox.EnqueueAt(tx, outbox.EventNotifySend, s, s.Event.Time.Add(s.Delay))
+		} else {
+			err = outbox.Enqueue(tx, outbox.EventNotifySend, s)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatWindow drops the zero units of a duration: 1h rather than 1h0m0s.
+func formatWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,127 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Escalation is the chain of notifications for a site that goes down. The
+// owner hears about it right away through the site's own webhook; if the site
+// stays down for AdminsAfter the Admins rules fire, and after IssueAfter an
+// issue is opened through IssueWebhook. Stored as a JSON object in the
+// escalation_policy setting, e.g.
+//
+//	{"admins_after": "30m", "admins": [{"channel": "email", "target": "ops@example.com"}],
+//	 "issue_after": "6h", "issue_webhook": "https://example.com/issues"}
+//
+// The stages are turned into ordinary notification rules with a
+// min_duration, so they wait in the outbox and survive restarts; a stage
+// that comes due after the site recovered is dropped.
+type Escalation struct {
+	AdminsAfter string `json:"admins_after,omitempty"`
+	// Admins are rules without an event; channel, target, quiet_hours and
+	// timezone work as in notification_rules.
+	Admins []Rule `json:"admins,omitempty"`
+
+	IssueAfter   string `json:"issue_after,omitempty"`
+	IssueWebhook string `json:"issue_webhook,omitempty"`
+}
+
+func ParseEscalation(raw string) (*Escalation, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var esc Escalation
+	if err := json.Unmarshal([]byte(raw), &esc); err != nil {
+		return nil, fmt.Errorf("invalid escalation policy: %v", err)
+	}
+
+	if len(esc.Admins) > 0 {
+		if _, err := positiveDuration(esc.AdminsAfter); err != nil {
+			return nil, fmt.Errorf("invalid admins_after: %v", err)
+		}
+	}
+	if esc.IssueWebhook != "" {
+		if _, err := positiveDuration(esc.IssueAfter); err != nil {
+			return nil, fmt.Errorf("invalid issue_after: %v", err)
+		}
+	}
+
+	// The rules are validated the same way as notification_rules
+	if _, err := validateRules(esc.rules()); err != nil {
+		return nil, fmt.Errorf("escalation policy %v", err)
+	}
+	return &esc, nil
+}
+
+// ValidateEscalation is used by the settings page to reject malformed
+// policies.
+func ValidateEscalation(raw string) error {
+	_, err := ParseEscalation(raw)
+	return err
+}
+
+func positiveDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be positive, got %s", s)
+	}
+	return d, nil
+}
+
+// rules returns the notification rules of the later stages. Each stage also
+// gets a site_up rule, which only fires for outages long enough to have
+// reached the stage, so admins and the issue tracker learn about the
+// recovery.
+func (esc *Escalation) rules() []Rule {
+	if esc == nil {
+		return nil
+	}
+
+	var rules []Rule
+	for _, admin := range esc.Admins {
+		admin.MinDuration = esc.AdminsAfter
+		for _, event := range []string{EventSiteDown, EventSiteUp} {
+			admin.Event = event
+			rules = append(rules, admin)
+		}
+	}
+	if esc.IssueWebhook != "" {
+		for _, event := range []string{EventSiteDown, EventSiteUp} {
+			rules = append(rules, Rule{Event: event, Channel: "issue", Target: esc.IssueWebhook, MinDuration: esc.IssueAfter})
+		}
+	}
+	return rules
+}
+
+// issueChannel posts an issue to a webhook: opened when a site goes down and
+// resolved when it comes back. The payload has a title and body for trackers
+// that create issues from incoming webhooks, plus the site to match the two.
+type issueChannel struct{}
+
+func (issueChannel) Send(target string, e Event) error {
+	action := "open"
+	if e.Type == EventSiteUp {
+		action = "resolve"
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"action":   action,
+		"title":    e.Subject(),
+		"body":     e.Text(),
+		"labels":   []string{"webring", e.Type},
+		"site_id":  e.SiteID,
+		"site_url": e.SiteURL,
+		"since":    e.Since,
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(target, body, map[string]string{"X-Webring-Event": e.Type})
+}
@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"webring/internal/settings"
+)
+
+const testEscalation = `{"admins_after": "30m", "admins": [{"channel": "test", "target": "ops"}],
+	"issue_after": "6h", "issue_webhook": "https://example.com/issues"}`
+
+func TestEscalationStages(t *testing.T) {
+	n, c, rec, sites := testNotifier(t, map[string]string{settings.EscalationPolicy: testEscalation})
+	issues := &recorder{}
+	channels["issue"] = issues
+	t.Cleanup(func() { channels["issue"] = issueChannel{} })
+	q := &queue{n: n}
+
+	goDown(q, sites, c.Now())
+	c.Advance(29 * time.Minute)
+	q.dispatch()
+	if got := rec.count(); got != 0 {
+		t.Fatalf("admins told after 29m, want after 30m")
+	}
+	c.Advance(time.Minute)
+	q.dispatch()
+	if got := rec.count(); got != 1 {
+		t.Fatalf("admins told %d times after 30m, want once", got)
+	}
+	if got := issues.count(); got != 0 {
+		t.Fatalf("issue opened after 30m, want after 6h")
+	}
+
+	c.Advance(6 * time.Hour)
+	q.dispatch()
+	if got := issues.count(); got != 1 {
+		t.Fatalf("%d issues opened after 6h, want 1", got)
+	}
+
+	// Both stages were reached, so both hear about the recovery
+	goUp(q, sites, c.Now())
+	if got := rec.count(); got != 2 {
+		t.Errorf("admins told %d times in total, want 2", got)
+	}
+	if got := issues.count(); got != 2 || issues.events[1].Type != EventSiteUp {
+		t.Errorf("issue not resolved: %d issue events", got)
+	}
+}
+
+func TestEscalationCancelled(t *testing.T) {
+	n, c, rec, sites := testNotifier(t, map[string]string{settings.EscalationPolicy: testEscalation})
+	issues := &recorder{}
+	channels["issue"] = issues
+	t.Cleanup(func() { channels["issue"] = issueChannel{} })
+	q := &queue{n: n}
+
+	// Down for an hour: admins are told, the issue stage is cancelled
+	goDown(q, sites, c.Now())
+	c.Advance(time.Hour)
+	q.dispatch()
+	goUp(q, sites, c.Now())
+	c.Advance(12 * time.Hour)
+	q.dispatch()
+
+	if got := rec.count(); got != 2 {
+		t.Errorf("admins told %d times, want down and up", got)
+	}
+	if got := issues.count(); got != 0 {
+		t.Errorf("%d issue events for an outage shorter than issue_after", got)
+	}
+}
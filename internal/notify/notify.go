@@ -0,0 +1,280 @@
+package notify
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"webring/internal/clock"
+	"webring/internal/settings"
+)
+
+type Event struct {
+	Type     string    `json:"type"`
+	SiteID   int       `json:"site_id"`
+	SiteName string    `json:"site_name"`
+	SiteURL  string    `json:"site_url"`
+	Message  string    `json:"message,omitempty"`
+	Time     time.Time `json:"time"`
+
+	// Since is when the site went down. For site_up events it is the start
+	// of the outage that just ended.
+	Since time.Time `json:"since"`
+
+	// Summarizes is the type of the events an EventRepeated stands for, and
+	// Repeats how many of them were held back.
+	Summarizes string `json:"summarizes,omitempty"`
+	Repeats    int    `json:"repeats,omitempty"`
+}
+
+func (e Event) Subject() string {
+	switch e.Type {
+	case EventSiteDown:
+		return fmt.Sprintf("%s is down", e.SiteName)
+	case EventSiteUp:
+		return fmt.Sprintf("%s is back up", e.SiteName)
+	case EventAuthFailures:
+		return "Repeated failed dashboard logins"
+	case EventSiteReported:
+		return fmt.Sprintf("%s was reported", e.SiteName)
+	case EventHTTPSIssue:
+		return fmt.Sprintf("%s fails the HTTPS requirement", e.SiteName)
+	case EventSLOBreach:
+		return "The ring is below its uptime objective"
+	case EventSLORecovered:
+		return "The ring is back within its uptime objective"
+	case EventGuestbookEntry:
+		return "New guestbook entry waiting for approval"
+	case EventHiatusEnding:
+		return fmt.Sprintf("%s returns from hiatus tomorrow", e.SiteName)
+	case EventRepeated:
+		if e.Summarizes == EventSiteDown {
+			return fmt.Sprintf("%s is flapping", e.SiteName)
+		}
+		return fmt.Sprintf("Repeated notifications for %s", e.SiteName)
+	default:
+		return fmt.Sprintf("%s: %s", e.Type, e.SiteName)
+	}
+}
+
+func (e Event) Text() string {
+	text := e.Subject()
+	if e.SiteURL != "" {
+		text += fmt.Sprintf(" (%s)", e.SiteURL)
+	}
+	if e.Type == EventSiteUp && !e.Since.IsZero() {
+		text += fmt.Sprintf(" after %s", e.Time.Sub(e.Since).Round(time.Second))
+	}
+	if e.Message != "" {
+		text += ": " + e.Message
+	}
+	return text + " at " + e.Time.Format("2006-01-02 15:04 MST")
+}
+
+// Notifier evaluates the configured rules for every event and delivers the
+// matching ones, and forwards events to the site's own webhook. site_down
+// notifications with a min_duration, including the later stages of the
+// escalation policy, are queued in the outbox for when the duration is over
+// and dropped then if the site recovered in the meantime. Events from the
+// outbox go through Deliver, which holds back repeats within the dedupe
+// window and queues every delivery in the outbox on its own.
+type Notifier struct {
+	db *sql.DB
+	st *settings.Store
+	// fake only logs what would be sent, for staging and load tests
+	fake bool
+
+	// stillDown reports whether the outage a site_down event announced is
+	// still going on. Tests replace it to run without a database.
+	stillDown func(e Event) (bool, error)
+
+	// Clock times the events, quiet hours and the dedupe windows. Tests
+	// replace it before first use.
+	Clock clock.Clock
+}
+
+func New(db *sql.DB, st *settings.Store) *Notifier {
+	fake, _ := strconv.ParseBool(os.Getenv("NOTIFY_FAKE"))
+	n := &Notifier{
+		db:    db,
+		st:    st,
+		fake:  fake,
+		Clock: clock.Real,
+	}
+	n.stillDown = n.outageGoesOn
+	return n
+}
+
+// Notify delivers an event right away, without retries. Events about sites
+// go through the outbox and Deliver instead.
+func (n *Notifier) Notify(e Event) {
+	sends := n.route(e, false)
+	var delayed []Send
+	for _, s := range sends {
+		if s.Delay > 0 {
+			delayed = append(delayed, s)
+			continue
+		}
+		go func(s Send) {
+			if err := n.Send(s); err != nil {
+				log.Printf("Error sending %s notification: %v", e.Type, err)
+			}
+		}(s)
+	}
+	if err := enqueue(n.db, delayed); err != nil {
+		log.Printf("Error queueing %s notification: %v", e.Type, err)
+	}
+}
+
+// Send is one delivery of an event: to the channel of a rule, or to the
+// owner's webhook of the site when Rule is nil. Deliver queues each of them
+// in the outbox, so a failing channel is retried on its own.
+type Send struct {
+	Event Event `json:"event"`
+	Rule  *Rule `json:"rule,omitempty"`
+	// Delay is the min_duration of the rule of a site_down. The send is due
+	// that long after the event, and only delivered if the site is still
+	// down from the same outage by then.
+	Delay time.Duration `json:"delay,omitempty"`
+}
+
+// route returns where an event goes. Held back events only go to rules with
+// a min_duration.
+func (n *Notifier) route(e Event, held bool) []Send {
+	if e.Time.IsZero() {
+		e.Time = n.Clock.Now()
+	}
+
+	// ruleEvent is the event type the rules are matched against
+	ruleEvent := e.Type
+	if e.Type == EventRepeated {
+		ruleEvent = e.Summarizes
+	}
+
+	var sends []Send
+
+	// Owners get every state change, HTTPS warning and hiatus reminder on
+	// their own webhook, independent of the admin-defined rules.
+	if !held && (ruleEvent == EventSiteDown || ruleEvent == EventSiteUp || ruleEvent == EventHTTPSIssue || ruleEvent == EventHiatusEnding) {
+		sends = append(sends, Send{Event: e})
+	}
+
+	rules, err := ParseRules(n.st.Get(settings.NotificationRules))
+	if err != nil {
+		log.Printf("Error loading notification rules: %v", err)
+		return sends
+	}
+	if ruleEvent == EventSiteDown || ruleEvent == EventSiteUp {
+		esc, err := ParseEscalation(n.st.Get(settings.EscalationPolicy))
+		if err != nil {
+			log.Printf("Error loading escalation policy: %v", err)
+		}
+		rules = append(rules, esc.rules()...)
+	}
+
+	for _, rule := range rules {
+		if rule.Event != ruleEvent {
+			continue
+		}
+
+		minDuration, _ := rule.minDuration()
+		switch {
+		case minDuration == 0 && held:
+			// Held back by the dedupe window
+		case minDuration > 0 && e.Type == EventRepeated:
+			// Rules with a min_duration got the held back events themselves
+		case e.Type == EventSiteDown && minDuration > 0:
+			rule := rule
+			sends = append(sends, Send{Event: e, Rule: &rule, Delay: minDuration})
+		case e.Type == EventSiteUp && minDuration > 0 && !e.Since.IsZero() && e.Time.Sub(e.Since) < minDuration:
+			// The outage was too short to have been announced
+		default:
+			rule := rule
+			sends = append(sends, Send{Event: e, Rule: &rule})
+		}
+	}
+	return sends
+}
+
+// Send delivers one notification and returns the error of the channel or
+// webhook, so the outbox can retry it. Delayed sends of an outage that is
+// over are dropped.
+func (n *Notifier) Send(s Send) error {
+	if s.Rule == nil {
+		return n.deliverSiteWebhook(s.Event)
+	}
+	if s.Delay > 0 {
+		down, err := n.stillDown(s.Event)
+		if err != nil {
+			return err
+		}
+		if !down {
+			return nil
+		}
+		s.Event.Time = n.Clock.Now()
+	}
+	return n.deliver(*s.Rule, s.Event)
+}
+
+// outageGoesOn reports whether the site of a site_down event is still down,
+// since the time of that event.
+func (n *Notifier) outageGoesOn(e Event) (bool, error) {
+	query := "SELECT (NOT is_up AND down_since = $2) IS TRUE FROM sites WHERE id = $1"
+	args := []interface{}{e.SiteID, e.Since}
+	if e.Since.IsZero() {
+		query, args = "SELECT NOT is_up FROM sites WHERE id = $1", args[:1]
+	}
+	var down bool
+	err := n.db.QueryRow(query, args...).Scan(&down)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The site was removed
+		return false, nil
+	}
+	return down, err
+}
+
+// location returns the time zone of the rule's recipient, falling back to the
+// timezone setting and then to the server's local time.
+func (n *Notifier) location(rule Rule) *time.Location {
+	for _, name := range []string{rule.Timezone, n.st.Get(settings.Timezone)} {
+		if name == "" {
+			continue
+		}
+		loc, err := time.LoadLocation(name)
+		if err == nil {
+			return loc
+		}
+		log.Printf("Error loading timezone %q: %v", name, err)
+	}
+	return time.Local
+}
+
+func (n *Notifier) deliver(rule Rule, e Event) error {
+	loc := n.location(rule)
+	e.Time = e.Time.In(loc)
+	if !e.Since.IsZero() {
+		e.Since = e.Since.In(loc)
+	}
+
+	if rule.quiet(n.Clock.Now().In(loc)) {
+		log.Printf("Skipping %s notification during quiet hours: %s", e.Type, e.Text())
+		return nil
+	}
+
+	if n.fake {
+		log.Printf("[NOTIFY FAKE] %s to %s: %s", rule.Channel, rule.Target, e.Text())
+		return nil
+	}
+	channel, ok := channels[rule.Channel]
+	if !ok {
+		return fmt.Errorf("unknown channel %q", rule.Channel)
+	}
+	if err := channel.Send(rule.Target, e); err != nil {
+		return fmt.Errorf("sending via %s: %w", rule.Channel, err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"webring/internal/clock"
+	"webring/internal/settings"
+)
+
+// recorder is a channel that keeps what it is sent.
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) Send(_ string, e Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+// outage is the stored state of a site: down since the time, or up when zero.
+type outage map[int]time.Time
+
+// testNotifier returns a notifier with the given rules that delivers to the
+// recorder, and reads whether sites are down from the returned outage.
+func testNotifier(t *testing.T, values map[string]string) (*Notifier, *clock.Fake, *recorder, outage) {
+	rec := &recorder{}
+	channels["test"] = rec
+	t.Cleanup(func() { delete(channels, "test") })
+
+	values[settings.Timezone] = "UTC"
+	n := New(nil, settings.NewStatic(values))
+	c := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	n.Clock = c
+	sites := outage{}
+	n.stillDown = func(e Event) (bool, error) {
+		since, ok := sites[e.SiteID]
+		return ok && since.Equal(e.Since), nil
+	}
+	return n, c, rec, sites
+}
+
+// queue stands in for the outbox: it sends the rule deliveries of the events
+// posted to it once they are due. The owner's webhook is left out.
+type queue struct {
+	n       *Notifier
+	pending []Send
+}
+
+func (q *queue) post(e Event) {
+	for _, s := range q.n.route(e, false) {
+		if s.Rule != nil {
+			q.pending = append(q.pending, s)
+		}
+	}
+	q.dispatch()
+}
+
+// dispatch sends what is due.
+func (q *queue) dispatch() {
+	now := q.n.Clock.Now()
+	pending := q.pending[:0]
+	for _, s := range q.pending {
+		if s.Event.Time.Add(s.Delay).After(now) {
+			pending = append(pending, s)
+			continue
+		}
+		_ = q.n.Send(s)
+	}
+	q.pending = pending
+}
+
+// goDown and goUp post the events the checker sends for site 1, and record
+// its state.
+func goDown(q *queue, sites outage, now time.Time) {
+	sites[1] = now
+	q.post(Event{Type: EventSiteDown, SiteID: 1, Time: now, Since: now})
+}
+
+func goUp(q *queue, sites outage, now time.Time) {
+	since := sites[1]
+	delete(sites, 1)
+	q.post(Event{Type: EventSiteUp, SiteID: 1, Time: now, Since: since})
+}
+
+func TestHeldSiteDown(t *testing.T) {
+	n, c, rec, sites := testNotifier(t, map[string]string{
+		settings.NotificationRules: `[{"event": "site_down", "channel": "test", "min_duration": "10m"}]`,
+	})
+	q := &queue{n: n}
+
+	goDown(q, sites, c.Now())
+	c.Advance(9 * time.Minute)
+	q.dispatch()
+	if got := rec.count(); got != 0 {
+		t.Fatalf("sent %d notifications before min_duration", got)
+	}
+	c.Advance(time.Minute)
+	q.dispatch()
+	if got := rec.count(); got != 1 {
+		t.Fatalf("sent %d notifications after min_duration, want 1", got)
+	}
+	if want := c.Now(); !rec.events[0].Time.Equal(want) {
+		t.Errorf("event time = %s, want %s", rec.events[0].Time, want)
+	}
+}
+
+func TestHeldSiteDownCancelled(t *testing.T) {
+	n, c, rec, sites := testNotifier(t, map[string]string{
+		settings.NotificationRules: `[{"event": "site_down", "channel": "test", "min_duration": "10m"}]`,
+	})
+	q := &queue{n: n}
+
+	goDown(q, sites, c.Now())
+	c.Advance(5 * time.Minute)
+	goUp(q, sites, c.Now())
+	c.Advance(time.Minute)
+	goDown(q, sites, c.Now())
+
+	// The first outage is over when its notification is due, even though
+	// the site is down again
+	c.Advance(4 * time.Minute)
+	q.dispatch()
+	if got := rec.count(); got != 0 {
+		t.Fatalf("sent %d notifications for a short outage", got)
+	}
+	c.Advance(6 * time.Minute)
+	q.dispatch()
+	if got := rec.count(); got != 1 {
+		t.Errorf("sent %d notifications for the second outage, want 1", got)
+	}
+}
+
+func TestDedupeWindow(t *testing.T) {
+	c := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	start := c.Now()
+	for _, tc := range []struct {
+		after time.Duration
+		open  bool
+	}{
+		{0, true},
+		{14 * time.Minute, true},
+		{15 * time.Minute, false},
+		{time.Hour, false},
+	} {
+		c.Set(start.Add(tc.after))
+		if got := inWindow(start, c.Now(), 15*time.Minute); got != tc.open {
+			t.Errorf("inWindow after %s = %v, want %v", tc.after, got, tc.open)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	EventSiteDown = "site_down"
+	EventSiteUp   = "site_up"
+
+	// EventAuthFailures is sent when a client is blocked after repeated failed
+	// dashboard logins. It is not tied to a site.
+	EventAuthFailures = "auth_failures"
+
+	// EventSiteReported is sent when a visitor reports a member.
+	EventSiteReported = "site_reported"
+
+	// EventHTTPSIssue is sent when a member starts failing the HTTPS
+	// requirement.
+	EventHTTPSIssue = "https_issue"
+
+	// EventSLOBreach is sent when the share of members up has been below the
+	// uptime objective for the alert window, and EventSLORecovered when it is
+	// met again. They are not tied to a site.
+	EventSLOBreach    = "slo_breach"
+	EventSLORecovered = "slo_recovered"
+
+	// EventGuestbookEntry is sent when a visitor signs the guestbook and the
+	// entry is waiting for approval. It is not tied to a site.
+	EventGuestbookEntry = "guestbook_entry"
+
+	// EventHiatusEnding is sent the day before a member on hiatus returns to
+	// the ring.
+	EventHiatusEnding = "hiatus_ending"
+)
+
+// Rule maps an event to a channel. Rules are stored as a JSON array in the
+// notification_rules setting, e.g.
+//
+//	[{"event": "site_down", "channel": "webhook", "target": "https://example.com/hook", "min_duration": "10m"}]
+type Rule struct {
+	Event   string `json:"event"`
+	Channel string `json:"channel"`
+	Target  string `json:"target,omitempty"`
+
+	// MinDuration delays site_down notifications until the site has been down
+	// for this long, and suppresses site_up notifications for outages shorter
+	// than this.
+	MinDuration string `json:"min_duration,omitempty"`
+
+	// QuietHours is a time range like "22:00-07:00" during which the rule
+	// does not fire.
+	QuietHours string `json:"quiet_hours,omitempty"`
+
+	// Timezone is the IANA time zone of the recipient, used for quiet hours
+	// and timestamps in messages. Defaults to the timezone setting.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+func ParseRules(raw string) ([]Rule, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, fmt.Errorf("invalid notification rules: %v", err)
+	}
+	return validateRules(rules)
+}
+
+func validateRules(rules []Rule) ([]Rule, error) {
+	for i, rule := range rules {
+		if rule.Event == "" {
+			return nil, fmt.Errorf("rule %d: event is required", i+1)
+		}
+		if _, ok := channels[rule.Channel]; !ok {
+			return nil, fmt.Errorf("rule %d: unknown channel %q", i+1, rule.Channel)
+		}
+		if _, err := rule.minDuration(); err != nil {
+			return nil, fmt.Errorf("rule %d: invalid min_duration: %v", i+1, err)
+		}
+		if _, _, err := parseQuietHours(rule.QuietHours); err != nil {
+			return nil, fmt.Errorf("rule %d: invalid quiet_hours: %v", i+1, err)
+		}
+		if _, err := time.LoadLocation(rule.Timezone); err != nil {
+			return nil, fmt.Errorf("rule %d: invalid timezone: %v", i+1, err)
+		}
+	}
+	return rules, nil
+}
+
+// ValidateRules is used by the settings page to reject malformed rules.
+func ValidateRules(raw string) error {
+	_, err := ParseRules(raw)
+	return err
+}
+
+func (r Rule) minDuration() (time.Duration, error) {
+	if r.MinDuration == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(r.MinDuration)
+}
+
+// ValidateTimezone is used by the settings page to reject unknown time zones.
+func ValidateTimezone(name string) error {
+	_, err := time.LoadLocation(name)
+	return err
+}
+
+func (r Rule) quiet(t time.Time) bool {
+	start, end, err := parseQuietHours(r.QuietHours)
+	if err != nil || start == end {
+		return false
+	}
+
+	minutes := t.Hour()*60 + t.Minute()
+	if start < end {
+		return minutes >= start && minutes < end
+	}
+	// The range wraps around midnight
+	return minutes >= start || minutes < end
+}
+
+// parseQuietHours returns the start and end of the range in minutes since
+// midnight.
+func parseQuietHours(s string) (int, int, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+
+	from, to, found := strings.Cut(s, "-")
+	if !found {
+		return 0, 0, fmt.Errorf("expected HH:MM-HH:MM, got %q", s)
+	}
+
+	start, err := time.Parse("15:04", strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(to))
+	if err != nil {
+		return 0, 0, err
+	}
+	return start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute(), nil
+}
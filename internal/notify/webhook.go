@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webring/internal/httpclient"
+)
+
+// Sign returns the signature sent in X-Webring-Signature. Receivers recompute
+// it over the X-Webring-Timestamp header, a dot and the raw request body.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverSiteWebhook sends the event to the owner's webhook of the site, if
+// one is configured. Failed deliveries are retried by the outbox.
+func (n *Notifier) deliverSiteWebhook(e Event) error {
+	var webhookURL, secret sql.NullString
+	err := n.db.QueryRow("SELECT webhook_url, webhook_secret FROM sites WHERE id = $1", e.SiteID).Scan(&webhookURL, &secret)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("loading webhook for site %d: %w", e.SiteID, err)
+	}
+	if !webhookURL.Valid || webhookURL.String == "" {
+		return nil
+	}
+
+	if n.fake {
+		log.Printf("[NOTIFY FAKE] site webhook %s: %s", webhookURL.String, e.Text())
+		return nil
+	}
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(n.Clock.Now().Unix(), 10)
+	headers := map[string]string{
+		"X-Webring-Event":     e.Type,
+		"X-Webring-Timestamp": timestamp,
+		"X-Webring-Signature": Sign(secret.String, timestamp, body),
+	}
+	if err := postJSON(webhookURL.String, body, headers); err != nil {
+		return fmt.Errorf("webhook for site %d: %w", e.SiteID, err)
+	}
+	return nil
+}
+
+func postJSON(target string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequest("POST", target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	client := httpclient.New("notify", httpclient.Options{Timeout: 10 * time.Second})
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+// Package onboarding builds the checklist new members see after they were
+// approved. Steps are detected where possible: the navigation links and the
+// feed are looked up on the member's homepage, the rest comes from the
+// database.
+package onboarding
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"webring/internal/httpclient"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// scanTTL is how long the result of a homepage scan is reused, so
+	// reloading the page doesn't hit the member's site every time.
+	scanTTL = 10 * time.Minute
+	// maxPageSize bounds how much of the homepage is read.
+	maxPageSize = 2 << 20
+)
+
+var ErrNotFound = errors.New("site not found")
+
+// feedTypes are the link types of the feeds looked for on homepages.
+var feedTypes = []string{"application/rss+xml", "application/atom+xml", "application/feed+json"}
+
+// Site is what the checklist needs to know about a member.
+type Site struct {
+	ID          int
+	Name        string
+	URL         string
+	Favicon     *string
+	Description *string
+	FeedURL     *string
+}
+
+// GetSite loads a member for the checklist.
+func GetSite(db *sql.DB, id int) (Site, error) {
+	site := Site{ID: id}
+	err := db.QueryRow("SELECT name, url, favicon, description, feed_url FROM sites WHERE id = $1", id).
+		Scan(&site.Name, &site.URL, &site.Favicon, &site.Description, &site.FeedURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Site{}, ErrNotFound
+	}
+	return site, err
+}
+
+// Step is one item of the checklist. Hint tells the owner how to complete
+// it, Detail what was found.
+type Step struct {
+	Title  string
+	Done   bool
+	Detail string
+	Hint   string
+}
+
+type Checklist struct {
+	Steps []Step
+	Done  int
+}
+
+// Complete reports whether every step is done.
+func (c Checklist) Complete() bool {
+	return c.Done == len(c.Steps)
+}
+
+// Build returns the checklist of a member. base is the public URL of the
+// ring, used to recognize navigation links.
+func Build(site Site, scan Scan, base string) Checklist {
+	widget := Step{
+		Title: "Navigation links on your site",
+		Done:  scan.Widget,
+		Hint:  fmt.Sprintf("Add the snippet from %s/%d/snippet or the %s/widget.js?slug=%d script to your homepage, so visitors can move along the ring.", base, site.ID, base, site.ID),
+	}
+	switch {
+	case scan.Err != nil:
+		widget.Detail = "We could not read your homepage: " + scan.Err.Error()
+	case scan.Widget:
+		widget.Detail = "Found on your homepage."
+	default:
+		widget.Detail = "No links to the ring were found on your homepage."
+	}
+
+	favicon := Step{
+		Title: "Favicon fetched",
+		Done:  site.Favicon != nil,
+		Hint:  `Add <link rel="icon"> to your homepage; the ring fetches it on the next favicon refresh.`,
+	}
+
+	description := Step{
+		Title: "Description added",
+		Done:  site.Description != nil && *site.Description != "",
+		Hint:  "Tell visitors what your site is about in a sentence or two.",
+	}
+
+	feed := Step{
+		Title: "Feed registered",
+		Done:  site.FeedURL != nil && *site.FeedURL != "",
+		Hint:  "Register the RSS or Atom feed of your site, so readers can follow the whole ring.",
+	}
+	if feed.Done {
+		feed.Detail = *site.FeedURL
+	} else if scan.Feed != "" {
+		feed.Detail = "Your homepage advertises " + scan.Feed + "."
+	}
+
+	c := Checklist{Steps: []Step{widget, favicon, description, feed}}
+	for _, s := range c.Steps {
+		if s.Done {
+			c.Done++
+		}
+	}
+	return c
+}
+
+// Scan is what was found on a member's homepage.
+type Scan struct {
+	// Widget is set when the page links to the member's navigation.
+	Widget bool
+	// Feed is the first feed the page advertises.
+	Feed string
+	Err  error
+
+	at time.Time
+}
+
+// Scanner looks for the ring's links and a feed on members' homepages and
+// caches the results.
+type Scanner struct {
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[int]Scan
+}
+
+func NewScanner() *Scanner {
+	return &Scanner{
+		client: httpclient.New("onboarding", httpclient.Options{Timeout: 10 * time.Second, RespectRobots: true}),
+		cache:  make(map[int]Scan),
+	}
+}
+
+// Scan returns the result of scanning the member's homepage for links to
+// base, scanning it again if the cached result is older than scanTTL.
+func (s *Scanner) Scan(site Site, base string) Scan {
+	s.mu.Lock()
+	cached, ok := s.cache[site.ID]
+	s.mu.Unlock()
+	if ok && time.Since(cached.at) < scanTTL {
+		return cached
+	}
+
+	result := s.scan(site, base)
+	result.at = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, old := range s.cache {
+		if time.Since(old.at) >= scanTTL {
+			delete(s.cache, id)
+		}
+	}
+	s.cache[site.ID] = result
+	return result
+}
+
+func (s *Scanner) scan(site Site, base string) Scan {
+	ring, err := url.Parse(base)
+	if err != nil {
+		return Scan{Err: err}
+	}
+	page, err := url.Parse(site.URL)
+	if err != nil {
+		return Scan{Err: err}
+	}
+
+	resp, err := s.client.Get(site.URL)
+	if err != nil {
+		if errors.Is(err, httpclient.ErrDisallowed) {
+			return Scan{Err: errors.New("robots.txt disallows it")}
+		}
+		return Scan{Err: errors.New("the request failed")}
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Printf("Failed to close response body: %v", err)
+		}
+	}(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return Scan{Err: fmt.Errorf("status code %d", resp.StatusCode)}
+	}
+
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return Scan{Err: errors.New("the page is not valid HTML")}
+	}
+
+	var result Scan
+	doc.Find("a[href], script[src], img[src], iframe[src]").EachWithBreak(func(_ int, sel *goquery.Selection) bool {
+		ref, ok := sel.Attr("href")
+		if !ok {
+			ref, _ = sel.Attr("src")
+		}
+		result.Widget = linksToRing(page, ring, ref, site.ID)
+		return !result.Widget
+	})
+	doc.Find("link[rel~='alternate'][href]").EachWithBreak(func(_ int, sel *goquery.Selection) bool {
+		if !isFeedType(sel.AttrOr("type", "")) {
+			return true
+		}
+		if u, err := page.Parse(sel.AttrOr("href", "")); err == nil {
+			result.Feed = u.String()
+		}
+		return result.Feed == ""
+	})
+	return result
+}
+
+// linksToRing reports whether ref, found on page, points to the navigation,
+// data or widget of the member on the ring.
+func linksToRing(page, ring *url.URL, ref string, id int) bool {
+	u, err := page.Parse(strings.TrimSpace(ref))
+	if err != nil || !strings.EqualFold(u.Host, ring.Host) {
+		return false
+	}
+	path := strings.TrimPrefix(u.Path, strings.TrimRight(ring.Path, "/"))
+	path = strings.TrimPrefix(path, "/v2")
+	if path == "/widget.js" {
+		return u.Query().Get("slug") == strconv.Itoa(id)
+	}
+	return strings.HasPrefix(path, fmt.Sprintf("/%d/", id)) || path == fmt.Sprintf("/b/%d.gif", id)
+}
+
+func isFeedType(t string) bool {
+	t = strings.ToLower(strings.TrimSpace(t))
+	for _, feed := range feedTypes {
+		if t == feed {
+			return true
+		}
+	}
+	return false
+}
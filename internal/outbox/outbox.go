@@ -0,0 +1,246 @@
+// Package outbox implements a transactional outbox. Side effects of a change,
+// such as notifications or fetching a new favicon, are written to the outbox
+// table in the same transaction as the change itself, and a dispatcher
+// delivers them once the transaction has committed. Events survive crashes
+// and are retried until their handler succeeds.
+package outbox
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	// EventSiteChanged carries a SiteChanged.
+	EventSiteChanged = "site_changed"
+	// EventNotify carries a notify.Event.
+	EventNotify = "notify"
+	// EventNotifySend carries a notify.Send, one channel or webhook an
+	// EventNotify goes to.
+	EventNotifySend = "notify_send"
+	// EventNotifySummary carries a notify.Summary, queued for the end of a
+	// dedupe window in which notifications were held back.
+	EventNotifySummary = "notify_summary"
+	// EventMonitor carries a monitor.Delivery.
+	EventMonitor = "monitor"
+)
+
+// SiteChanged is emitted when a site is added, edited or removed.
+type SiteChanged struct {
+	SiteID  int    `json:"site_id"`
+	URL     string `json:"url,omitempty"`
+	Removed bool   `json:"removed,omitempty"`
+	// RefreshFavicon asks for the site's favicon to be fetched again.
+	RefreshFavicon bool `json:"refresh_favicon,omitempty"`
+}
+
+const (
+	batchSize = 20
+	// lease is how long a claimed batch is left to its dispatcher before
+	// other instances take it over; enough for every event of a batch to
+	// time out once.
+	lease       = 10 * time.Minute
+	maxAttempts = 10
+	maxBackoff  = time.Hour
+	// Delivered and failed events are kept this long for debugging
+	retention = 7 * 24 * time.Hour
+)
+
+// Execer is satisfied by *sql.Tx and *sql.DB.
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Enqueue adds an event to the outbox. Pass the transaction that makes the
+// change the event is about.
+func Enqueue(tx Execer, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO outbox (event_type, payload) VALUES ($1, $2)", eventType, data)
+	return err
+}
+
+// EnqueueAt adds an event that is not delivered before at.
+func EnqueueAt(tx Execer, eventType string, payload interface{}, at time.Time) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO outbox (event_type, payload, next_attempt_at) VALUES ($1, $2, $3)", eventType, data, at)
+	return err
+}
+
+// Handler delivers the payload of an event. Returning an error schedules a
+// retry, so handlers must be safe to run more than once.
+type Handler func(payload json.RawMessage) error
+
+// Dispatcher delivers pending events to the handlers registered for their
+// type.
+type Dispatcher struct {
+	db *sql.DB
+
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewDispatcher(db *sql.DB) *Dispatcher {
+	return &Dispatcher{db: db, handlers: make(map[string][]Handler)}
+}
+
+// Handle registers h for events of the given type. Events with several
+// handlers are retried as a whole, so every handler has to be idempotent.
+func (d *Dispatcher) Handle(eventType string, h Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[eventType] = append(d.handlers[eventType], h)
+}
+
+// Start polls for pending events every interval. It blocks, so run it in a
+// goroutine.
+func (d *Dispatcher) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastPrune := time.Now()
+
+	for range ticker.C {
+		for {
+			n, err := d.dispatch()
+			if err != nil {
+				log.Printf("Error dispatching outbox events: %v", err)
+				break
+			}
+			// A full batch means there may be more waiting
+			if n < batchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPrune) > time.Hour {
+			d.prune()
+			lastPrune = time.Now()
+		}
+	}
+}
+
+type pendingEvent struct {
+	id        int64
+	eventType string
+	payload   json.RawMessage
+	attempts  int
+}
+
+// dispatch delivers one batch of due events and returns how many it handled.
+// The batch is claimed first, by moving the events' next attempt a lease
+// ahead, so several instances can dispatch from the same table without
+// holding locks while the handlers make their network calls. Events of an
+// instance that dies mid-batch are picked up again once the lease is over.
+func (d *Dispatcher) dispatch() (int, error) {
+	events, err := d.claim()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range events {
+		err := d.deliver(e)
+		switch {
+		case err == nil:
+			_, err = d.db.Exec("UPDATE outbox SET delivered_at = NOW(), attempts = attempts + 1 WHERE id = $1", e.id)
+		case e.attempts+1 >= maxAttempts:
+			log.Printf("Giving up on outbox event %d (%s) after %d attempts: %v", e.id, e.eventType, e.attempts+1, err)
+			_, err = d.db.Exec("UPDATE outbox SET failed_at = NOW(), attempts = attempts + 1, last_error = $1 WHERE id = $2", err.Error(), e.id)
+		default:
+			log.Printf("Error delivering outbox event %d (%s), will retry: %v", e.id, e.eventType, err)
+			_, err = d.db.Exec("UPDATE outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3",
+				err.Error(), time.Now().Add(backoff(e.attempts)), e.id)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(events), nil
+}
+
+// claim takes a batch of due events for this instance.
+func (d *Dispatcher) claim() ([]pendingEvent, error) {
+	rows, err := d.db.Query(`
+		UPDATE outbox SET next_attempt_at = NOW() + $2::float8 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING id, event_type, payload, attempts`, batchSize, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	var events []pendingEvent
+	for rows.Next() {
+		var e pendingEvent
+		var payload []byte
+		if err := rows.Scan(&e.id, &e.eventType, &payload, &e.attempts); err != nil {
+			return nil, err
+		}
+		e.payload = payload
+		events = append(events, e)
+	}
+	// RETURNING doesn't keep the order of the subquery
+	sort.Slice(events, func(i, j int) bool { return events[i].id < events[j].id })
+	return events, rows.Err()
+}
+
+// deliver runs the handlers of an event. Handlers that panic count as
+// failed, so one bad event can't stop the dispatcher.
+func (d *Dispatcher) deliver(e pendingEvent) (err error) {
+	d.mu.RLock()
+	handlers := d.handlers[e.eventType]
+	d.mu.RUnlock()
+	if len(handlers) == 0 {
+		return fmt.Errorf("no handler for %s events", e.eventType)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	for _, h := range handlers {
+		if err := h(e.payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backoff returns the delay before the next attempt: 10s, 20s, 40s, ... up
+// to maxBackoff.
+func backoff(attempts int) time.Duration {
+	d := 10 * time.Second << attempts
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+func (d *Dispatcher) prune() {
+	cutoff := time.Now().Add(-retention)
+	_, err := d.db.Exec("DELETE FROM outbox WHERE delivered_at < $1 OR failed_at < $1", cutoff)
+	if err != nil {
+		log.Printf("Error pruning the outbox: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package public
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/audit"
+	"webring/internal/render"
+)
+
+const defaultChangesLimit = 50
+
+// changesHandler lists recent changes to the ring, as HTML or, with
+// ?format=json, as JSON.
+func changesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultChangesLimit
+		if value := r.URL.Query().Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		entries, err := audit.Recent(db, limit)
+		if err != nil {
+			log.Printf("Error fetching changes: %v", err)
+			http.Error(w, "Error fetching changes", http.StatusInternalServerError)
+			return
+		}
+
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(entries); err != nil {
+				log.Printf("Error encoding changes: %v", err)
+			}
+			return
+		}
+
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		render.HTML(w, http.StatusOK, t, "changes.html", entries)
+	}
+}
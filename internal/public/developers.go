@@ -0,0 +1,130 @@
+package public
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"webring/internal/api"
+	"webring/internal/api/middleware"
+	"webring/internal/models"
+	"webring/internal/render"
+	"webring/internal/ring"
+	"webring/internal/settings"
+)
+
+// endpoint documents a public API endpoint. {id} in the path stands for a
+// member ID.
+type endpoint struct {
+	Method      string
+	Path        string
+	Description string
+}
+
+// endpoints lists the API for member sites and tools built on the ring, in
+// the order the developers page shows them.
+var endpoints = []endpoint{
+	{"GET", "/{id}/next", "Redirects to the next member."},
+	{"GET", "/{id}/prev", "Redirects to the previous member."},
+	{"GET", "/{id}/random", "Redirects to a random member."},
+	{"GET", "/{id}/next/", "The next member as JSON."},
+	{"GET", "/{id}/prev/", "The previous member as JSON."},
+	{"GET", "/{id}/random/", "A random member as JSON."},
+	{"GET", "/{id}/data", "The member with its previous and next sites, its position in the ring and the ring size. Add ?format=js&callback=fn for a script embed, or ?format=xml or text. When the ring stops at its ends, first or last is set there and the directory (ID 0) takes the place of the missing neighbour."},
+	{"GET", "/{id}/neighbors?depth=2", "Previous and next members several steps ahead (up to 10), for widgets that navigate client-side."},
+	{"GET", "/{id}/next/?order=alphabetical", "Every navigation endpoint above takes order= to traverse the ring in another ordering: manual, alphabetical, join_date, newest or daily_shuffle."},
+	{"GET", "/v2/{id}/data", "Version 2 of next, prev, random, data and neighbors: one envelope with the site, its position, the ring size and the prev and next lists, without null fields."},
+	{"GET", "/{id}/snippet", "Copy-pasteable HTML navigation for the member."},
+	{"GET", "/widget.js?slug={id}&theme=auto", "Script rendering the navigation where it is included, in the ring's styling; theme is light, dark or auto."},
+	{"GET", "/{id}/card", "Link preview card (SVG, 1200x630) for og:image."},
+	{"GET", "/b/{id}.gif", "Transparent 1x1 image to embed next to the widget; its views are counted as the member's impressions when the visitor counter is on."},
+	{"GET", "/{id}/qr.png", "QR code linking to the member; ?to=random, next or prev for the ring redirects."},
+	{"POST", "/{id}/report", `Reports the member to the ring operators, with a JSON body {"reason": "...", "contact": "..."}.`},
+	{"GET", "/sites", "All members as JSON, or XML or plain text with ?format= or an Accept header; also /sites.txt and /sites.md."},
+	{"GET", "/sites.opml", "Every member as an OPML subscription list, with its ID as slug and the status of its last check; members with a registered feed can be subscribed to directly. Also /sites.rss, newest members first."},
+	{"GET", "/ring/data", "The whole ring with the previous and next ID of every member. Supports ETag and If-None-Match."},
+	{"GET", "/search?q=blog", "Full-text search over member names and URLs, with page and per_page."},
+	{"GET", "/stats", "Ring statistics: member count, average uptime, newest member and ring age."},
+	{"GET", "/status?format=json", "Members up and the ring's uptime objective."},
+	{"GET", "/changes?format=json&limit=50", "Recent changes to the ring."},
+	{"GET", "/badge/members.svg", "Member count badge; also /badge/uptime.svg."},
+	{"GET", "/favicons/sprite.png", "All member favicons as one image, positioned by /favicons/sprite.json."},
+}
+
+// example is an endpoint with the path filled in for this deployment.
+type example struct {
+	endpoint
+	URL string
+	// Curl is the command to try requests that can't be opened in a browser
+	Curl string
+}
+
+type developersPage struct {
+	Base string
+	// Site is the member used in the examples, nil while the ring is empty.
+	Site      *models.PublicSite
+	Examples  []example
+	Snippet   string
+	Widget    string
+	WidgetJS  string
+	ScriptTag string
+
+	// Tokens is set when navigation needs a token from /{id}/data, and
+	// KeyRequired when /ring/data needs an API key.
+	Tokens      bool
+	KeyRequired bool
+}
+
+// developersHandler documents the API with examples for the first member, so
+// the URLs and snippets can be copied as they are.
+func developersHandler(rc *ring.Cache, st *settings.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		sites, err := rc.Sites()
+		if err != nil {
+			log.Printf("Error fetching sites: %v", err)
+			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+			return
+		}
+
+		page := developersPage{
+			Base:        middleware.BaseURL(r),
+			Tokens:      st.Bool(settings.NavigationTokens),
+			KeyRequired: st.Bool(settings.RingDataRequiresKey),
+		}
+		id := "{id}"
+		if len(sites) > 0 {
+			page.Site = &sites[0]
+			id = fmt.Sprint(page.Site.ID)
+			page.Snippet = api.Snippet(page.Base, page.Site.ID)
+		}
+
+		for _, e := range endpoints {
+			ex := example{endpoint: e, URL: page.Base + strings.ReplaceAll(e.Path, "{id}", id)}
+			if e.Method != "GET" {
+				ex.Curl = fmt.Sprintf(`curl -X %s -H "Content-Type: application/json" -d '{"reason": "..."}' %s`, e.Method, ex.URL)
+			}
+			page.Examples = append(page.Examples, ex)
+		}
+		page.Widget = fmt.Sprintf(`<script src="%s/widget.js?slug=%s&theme=auto" async></script>`, page.Base, id)
+		page.ScriptTag = fmt.Sprintf(`<script src="%s/%s/data?format=js&callback=showWebring"></script>`, page.Base, id)
+		page.WidgetJS = fmt.Sprintf(`fetch("%s/%s/data")
+  .then((r) => r.json())
+  .then((data) => {
+    document.querySelector("#webring-prev").href = data.prev.url;
+    document.querySelector("#webring-next").href = data.next.url;
+  });`, page.Base, id)
+
+		render.HTML(w, http.StatusOK, t, "developers.html", page)
+	}
+}
@@ -0,0 +1,107 @@
+package public
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"webring/internal/api/middleware"
+	"webring/internal/archive"
+	"webring/internal/guestbook"
+	"webring/internal/notify"
+	"webring/internal/render"
+	"webring/internal/settings"
+)
+
+// guestbookLimit is how many entries the guestbook page shows.
+const guestbookLimit = 100
+
+// trapField is hidden from visitors; bots filling in every field get a
+// thank-you page, but their entry is dropped.
+const trapField = "phone"
+
+type guestbookPage struct {
+	Entries  []guestbook.Entry
+	Error    string
+	Sent     bool
+	Archived bool
+	// Form keeps the submitted values when the entry was rejected.
+	Form struct {
+		Name    string
+		Website string
+		Message string
+	}
+}
+
+func renderGuestbook(w http.ResponseWriter, db *sql.DB, status int, data guestbookPage) {
+	templatesMu.RLock()
+	t := templates
+	templatesMu.RUnlock()
+
+	if t == nil {
+		log.Println("Templates not initialized")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	entries, err := guestbook.Approved(db, guestbookLimit)
+	if err != nil {
+		log.Printf("Error fetching guestbook entries: %v", err)
+		http.Error(w, "Error fetching guestbook entries", http.StatusInternalServerError)
+		return
+	}
+	data.Entries = entries
+	data.Archived = archive.Enabled()
+	render.HTML(w, status, t, "guestbook.html", data)
+}
+
+// guestbookEnabled answers 404 while the guestbook is turned off.
+func guestbookEnabled(st *settings.Store, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !st.Bool(settings.Guestbook) {
+			http.NotFound(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func guestbookHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		renderGuestbook(w, db, http.StatusOK, guestbookPage{})
+	}
+}
+
+func signGuestbookHandler(db, reads *sql.DB, notifier *notify.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var page guestbookPage
+		if r.FormValue(trapField) != "" {
+			page.Sent = true
+			renderGuestbook(w, reads, http.StatusOK, page)
+			return
+		}
+
+		page.Form.Name = r.FormValue("name")
+		page.Form.Website = r.FormValue("website")
+		page.Form.Message = r.FormValue("message")
+		err := guestbook.Submit(db, notifier, page.Form.Name, page.Form.Website, page.Form.Message, middleware.ClientIP(r))
+		switch {
+		case errors.Is(err, guestbook.ErrInvalid):
+			page.Error = err.Error()
+			renderGuestbook(w, reads, http.StatusBadRequest, page)
+			return
+		case errors.Is(err, guestbook.ErrTooMany):
+			page.Error = err.Error()
+			renderGuestbook(w, reads, http.StatusTooManyRequests, page)
+			return
+		case err != nil:
+			log.Printf("Error signing the guestbook: %v", err)
+			http.Error(w, "Error signing the guestbook", http.StatusInternalServerError)
+			return
+		}
+
+		page = guestbookPage{Sent: true}
+		renderGuestbook(w, reads, http.StatusOK, page)
+	}
+}
@@ -2,18 +2,42 @@ package public
 
 import (
 	"database/sql"
+	"fmt"
 	"github.com/gorilla/mux"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
 	"sync"
+	"webring/internal/archive"
+	"webring/internal/blocklist"
+	"webring/internal/favicon"
+	"webring/internal/markdown"
 	"webring/internal/models"
+	"webring/internal/notify"
+	"webring/internal/onboarding"
+	"webring/internal/render"
+	"webring/internal/ring"
+	"webring/internal/search"
+	"webring/internal/settings"
+	"webring/internal/tour"
 )
 
 type TemplateData struct {
 	Sites       []models.PublicSite
+	Offline     []models.OfflineSite
+	Icons       map[int]template.CSS
+	SpriteURL   string
+	Query       string
 	ContactLink string
+	SupportLink string
+	Banner      string
+	Archived    bool
+	Guestbook   bool
+	// Homepage blocks edited in the dashboard settings, empty when unset
+	Intro template.HTML
+	FAQ   template.HTML
+	Join  template.HTML
 }
 
 var (
@@ -27,16 +51,63 @@ func InitTemplates(t *template.Template) {
 	templates = t
 }
 
-func RegisterHandlers(r *mux.Router, db *sql.DB) {
-	r.HandleFunc("/", listSitesHandler(db)).Methods("GET")
+// RegisterHandlers adds the public pages. Pages that only read use reads,
+// which may be a replica; writes go to db.
+func RegisterHandlers(r *mux.Router, db, reads *sql.DB, bl *blocklist.Blocklist, st *settings.Store, rc *ring.Cache, notifier *notify.Notifier, sprite *favicon.Sprite, tc *tour.Cookies) {
+	publicRouter := r.PathPrefix("").Subrouter()
+	publicRouter.Use(bl.Middleware)
+
+	publicRouter.HandleFunc("/", listSitesHandler(reads, rc, st, sprite)).Methods("GET")
+	publicRouter.HandleFunc("/changes", changesHandler(reads)).Methods("GET")
+	publicRouter.HandleFunc("/status", statusHandler(reads, st)).Methods("GET")
+	publicRouter.HandleFunc("/developers", developersHandler(rc, st)).Methods("GET")
+	publicRouter.HandleFunc("/tour", tourHandler(rc, tc)).Methods("GET")
+	publicRouter.HandleFunc("/tour/start", tourStartHandler(tc)).Methods("GET")
+	publicRouter.HandleFunc("/tour/next", tourNextHandler(rc, tc)).Methods("GET")
+	publicRouter.HandleFunc("/user/sites/{id:[0-9]+}/onboarding", onboardingHandler(reads, onboarding.NewScanner())).Methods("GET")
+	publicRouter.HandleFunc("/report/{id}", reportFormHandler(reads)).Methods("GET")
+	publicRouter.HandleFunc("/report/{id}", submitReportHandler(db, notifier)).Methods("POST")
+	publicRouter.HandleFunc("/guestbook", guestbookEnabled(st, guestbookHandler(reads))).Methods("GET")
+	publicRouter.HandleFunc("/guestbook", guestbookEnabled(st, signGuestbookHandler(db, reads, notifier))).Methods("POST")
 }
 
-func listSitesHandler(db *sql.DB) http.HandlerFunc {
+func listSitesHandler(db *sql.DB, rc *ring.Cache, st *settings.Store, sprite *favicon.Sprite) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		sites, err := getRespondingSites(db)
-		if err != nil {
-			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
-			return
+		query := r.URL.Query().Get("q")
+
+		var sites []models.PublicSite
+		var offline []models.OfflineSite
+		var err error
+		if query != "" {
+			sites, err = searchSites(db, query)
+			if err != nil {
+				log.Printf("Error searching sites: %v", err)
+				http.Error(w, "Error searching sites", http.StatusInternalServerError)
+				return
+			}
+		} else {
+			sites, err = rc.Sites()
+			if err != nil {
+				http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+				return
+			}
+
+			offline, err = getArchivedOfflineSites(db)
+			if err != nil {
+				// The directory is still useful without the archived copies
+				log.Printf("Error fetching offline sites: %v", err)
+			}
+		}
+
+		var icons map[int]template.CSS
+		var spriteURL string
+		// The sprite covers the whole ring, also when showing search results
+		if sheet, err := sprite.Sheet(); err != nil {
+			// Members fall back to their own favicon images
+			log.Printf("Error building favicon sprite: %v", err)
+		} else {
+			icons = spriteStyles(sheet)
+			spriteURL = "/favicons/sprite.png?v=" + sheet.Version
 		}
 
 		templatesMu.RLock()
@@ -49,17 +120,58 @@ func listSitesHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		data := TemplateData{sites, os.Getenv("CONTACT_LINK")}
-		err = t.ExecuteTemplate(w, "sites.html", data)
-		if err != nil {
-			log.Printf("Error rendering template: %v", err)
-			http.Error(w, "Error rendering template", http.StatusInternalServerError)
+		data := TemplateData{
+			Sites:       sites,
+			Offline:     offline,
+			Icons:       icons,
+			SpriteURL:   spriteURL,
+			Query:       query,
+			ContactLink: os.Getenv("CONTACT_LINK"),
+			SupportLink: st.Get(settings.RingSupportURL),
+			Banner:      st.Get(settings.MaintenanceBanner),
+			Archived:    archive.Enabled(),
+			Guestbook:   st.Bool(settings.Guestbook),
+			Intro:       markdown.Render(st.Get(settings.HomeIntro)),
+			FAQ:         markdown.Render(st.Get(settings.HomeFAQ)),
+			Join:        markdown.Render(st.Get(settings.HomeJoin)),
 		}
+		render.HTML(w, http.StatusOK, t, "sites.html", data)
 	}
 }
 
-func getRespondingSites(db *sql.DB) ([]models.PublicSite, error) {
-	rows, err := db.Query("SELECT id, name, url, favicon FROM sites WHERE is_up = true ORDER BY id")
+// spriteIconSize is the size favicons are shown at in the directory.
+const spriteIconSize = 20
+
+// spriteStyles returns the inline style that shows a member's icon from the
+// sprite, scaled down to spriteIconSize.
+func spriteStyles(sheet *favicon.SpriteSheet) map[int]template.CSS {
+	scale := func(v int) int { return v * spriteIconSize / sheet.Size }
+	styles := make(map[int]template.CSS, len(sheet.Icons))
+	for id, offset := range sheet.Icons {
+		styles[id] = template.CSS(fmt.Sprintf("background-position: -%dpx -%dpx; background-size: %dpx %dpx",
+			scale(offset.X), scale(offset.Y), scale(sheet.Width), scale(sheet.Height)))
+	}
+	return styles
+}
+
+// searchSites returns the best matches for the directory search box.
+func searchSites(db *sql.DB, query string) ([]models.PublicSite, error) {
+	result, err := search.Sites(db, query, 1, search.MaxPerPage)
+	if err != nil {
+		return nil, err
+	}
+
+	sites := make([]models.PublicSite, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		sites = append(sites, hit.PublicSite)
+	}
+	return sites, nil
+}
+
+// getArchivedOfflineSites returns the down members for which an Internet
+// Archive snapshot is known.
+func getArchivedOfflineSites(db *sql.DB) ([]models.OfflineSite, error) {
+	rows, err := db.Query("SELECT name, url, archive_url FROM sites WHERE is_up = false AND archive_url IS NOT NULL ORDER BY id")
 	if err != nil {
 		return nil, err
 	}
@@ -70,13 +182,13 @@ func getRespondingSites(db *sql.DB) ([]models.PublicSite, error) {
 		}
 	}(rows)
 
-	var sites []models.PublicSite
+	var sites []models.OfflineSite
 	for rows.Next() {
-		var site models.PublicSite
-		if err := rows.Scan(&site.ID, &site.Name, &site.URL, &site.Favicon); err != nil {
+		var site models.OfflineSite
+		if err := rows.Scan(&site.Name, &site.URL, &site.ArchiveURL); err != nil {
 			return nil, err
 		}
 		sites = append(sites, site)
 	}
-	return sites, nil
+	return sites, rows.Err()
 }
@@ -0,0 +1,62 @@
+package public
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/api/middleware"
+	"webring/internal/onboarding"
+	"webring/internal/render"
+
+	"github.com/gorilla/mux"
+)
+
+type onboardingPage struct {
+	Site      onboarding.Site
+	Checklist onboarding.Checklist
+	Base      string
+}
+
+// onboardingHandler shows new members what is left to do for a healthy
+// membership. It only shows what is public anyway, so it needs no login and
+// can be linked from the approval message.
+func onboardingHandler(db *sql.DB, scanner *onboarding.Scanner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		site, err := onboarding.GetSite(db, id)
+		if errors.Is(err, onboarding.ErrNotFound) {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error fetching site %d: %v", id, err)
+			http.Error(w, "Error fetching site", http.StatusInternalServerError)
+			return
+		}
+
+		base := middleware.BaseURL(r)
+		page := onboardingPage{
+			Site:      site,
+			Checklist: onboarding.Build(site, scanner.Scan(site, base), base),
+			Base:      base,
+		}
+
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		render.HTML(w, http.StatusOK, t, "onboarding.html", page)
+	}
+}
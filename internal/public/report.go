@@ -0,0 +1,101 @@
+package public
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"webring/internal/api/middleware"
+	"webring/internal/archive"
+	"webring/internal/notify"
+	"webring/internal/render"
+	"webring/internal/report"
+
+	"github.com/gorilla/mux"
+)
+
+type reportPage struct {
+	Site     string
+	ID       int
+	Error    string
+	Sent     bool
+	Archived bool
+}
+
+func renderReport(w http.ResponseWriter, status int, data reportPage) {
+	templatesMu.RLock()
+	t := templates
+	templatesMu.RUnlock()
+
+	if t == nil {
+		log.Println("Templates not initialized")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data.Archived = archive.Enabled()
+	render.HTML(w, status, t, "report.html", data)
+}
+
+// reportSite looks up the member a report page is for.
+func reportSite(db *sql.DB, r *http.Request) (reportPage, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return reportPage{}, report.ErrSiteNotFound
+	}
+
+	page := reportPage{ID: id}
+	err = db.QueryRow("SELECT name FROM sites WHERE id = $1", id).Scan(&page.Site)
+	if errors.Is(err, sql.ErrNoRows) {
+		return reportPage{}, report.ErrSiteNotFound
+	}
+	return page, err
+}
+
+func reportFormHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, err := reportSite(db, r)
+		if errors.Is(err, report.ErrSiteNotFound) {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error fetching site: %v", err)
+			http.Error(w, "Error fetching site", http.StatusInternalServerError)
+			return
+		}
+		renderReport(w, http.StatusOK, page)
+	}
+}
+
+func submitReportHandler(db *sql.DB, notifier *notify.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, err := reportSite(db, r)
+		if errors.Is(err, report.ErrSiteNotFound) {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error fetching site: %v", err)
+			http.Error(w, "Error fetching site", http.StatusInternalServerError)
+			return
+		}
+
+		err = report.Submit(db, notifier, page.ID, r.FormValue("reason"), r.FormValue("contact"), middleware.ClientIP(r))
+		if errors.Is(err, report.ErrInvalid) {
+			page.Error = err.Error()
+			renderReport(w, http.StatusBadRequest, page)
+			return
+		}
+		if err != nil {
+			log.Printf("Error filing report against site %d: %v", page.ID, err)
+			http.Error(w, "Error filing report", http.StatusInternalServerError)
+			return
+		}
+
+		page.Sent = true
+		renderReport(w, http.StatusOK, page)
+	}
+}
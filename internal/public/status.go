@@ -0,0 +1,45 @@
+package public
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"webring/internal/render"
+	"webring/internal/settings"
+	"webring/internal/slo"
+)
+
+// statusHandler shows how many members are up and how the ring does against
+// its uptime objective, as HTML or, with ?format=json, as JSON.
+func statusHandler(db *sql.DB, st *settings.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := slo.Current(db, st)
+		if err != nil {
+			log.Printf("Error computing the uptime objective: %v", err)
+			http.Error(w, "Error fetching status", http.StatusInternalServerError)
+			return
+		}
+
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(status); err != nil {
+				log.Printf("Error encoding status: %v", err)
+			}
+			return
+		}
+
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		render.HTML(w, http.StatusOK, t, "status.html", status)
+	}
+}
@@ -0,0 +1,98 @@
+package public
+
+import (
+	"log"
+	"net/http"
+
+	"webring/internal/models"
+	"webring/internal/render"
+	"webring/internal/ring"
+	"webring/internal/tour"
+)
+
+type tourPage struct {
+	// Site is the member the visitor was sent to last, nil before the first,
+	// after the last or when it left the ring since.
+	Site     *models.PublicSite
+	Visited  int
+	Total    int
+	Started  bool
+	Finished bool
+}
+
+// tourHandler is the interstitial of the tour: where the visitor is and how
+// far they got, with the links to visit the member and to move on.
+func tourHandler(rc *ring.Cache, cookies *tour.Cookies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templatesMu.RLock()
+		t := templates
+		templatesMu.RUnlock()
+
+		if t == nil {
+			log.Println("Templates not initialized")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		sites, err := rc.Sites()
+		if err != nil {
+			log.Printf("Error fetching sites: %v", err)
+			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+			return
+		}
+		sites = tour.Members(sites)
+
+		p, ok := cookies.Get(r)
+		page := tourPage{
+			Visited: p.Count(sites),
+			Total:   len(sites),
+			Started: ok && p.Started(),
+		}
+		if i := ring.Index(sites, p.Current); i >= 0 {
+			page.Site = &sites[i]
+		}
+		page.Finished = page.Started && p.Current == 0 && page.Visited == page.Total
+
+		// The page depends on the cookie
+		w.Header().Set("Cache-Control", "no-store")
+		render.HTML(w, http.StatusOK, t, "tour.html", page)
+	}
+}
+
+// tourStartHandler starts the tour over.
+func tourStartHandler(cookies *tour.Cookies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookies.Set(w, tour.Progress{})
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, "/tour/next", http.StatusSeeOther)
+	}
+}
+
+// tourNextHandler moves the visitor to the next member they haven't seen and
+// back to the interstitial, which links to it. Going back from the member
+// returns to the interstitial without skipping anyone.
+func tourNextHandler(rc *ring.Cache, cookies *tour.Cookies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		p, ok := cookies.Get(r)
+		if !ok {
+			http.Redirect(w, r, "/tour", http.StatusSeeOther)
+			return
+		}
+
+		sites, err := rc.Sites()
+		if err != nil {
+			log.Printf("Error fetching sites: %v", err)
+			http.Error(w, "Error fetching sites", http.StatusInternalServerError)
+			return
+		}
+		sites = tour.Members(sites)
+		if i, ok := p.Next(sites); ok {
+			p.Visit(sites[i].ID)
+		} else {
+			p.Current = 0
+		}
+		cookies.Set(w, p)
+		http.Redirect(w, r, "/tour", http.StatusSeeOther)
+	}
+}
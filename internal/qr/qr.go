@@ -0,0 +1,199 @@
+// Package qr encodes short texts, such as URLs, as QR codes. It supports byte
+// mode at error correction level M in versions 1 to 10, which holds up to 213
+// bytes and is plenty for links to the ring.
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// QuietZone is the number of light modules drawn around the code.
+const QuietZone = 4
+
+// ErrTooLong is returned for texts that don't fit in the largest supported
+// version.
+var ErrTooLong = errors.New("qr: text too long")
+
+// blockLayout describes the error correction blocks of a version at level M.
+type blockLayout struct {
+	ecPerBlock         int
+	blocks1, dataLen1  int
+	blocks2, dataLen2  int
+	alignmentPositions []int
+}
+
+var versions = []blockLayout{
+	1:  {10, 1, 16, 0, 0, nil},
+	2:  {16, 1, 28, 0, 0, []int{6, 18}},
+	3:  {26, 1, 44, 0, 0, []int{6, 22}},
+	4:  {18, 2, 32, 0, 0, []int{6, 26}},
+	5:  {24, 2, 43, 0, 0, []int{6, 30}},
+	6:  {16, 4, 27, 0, 0, []int{6, 34}},
+	7:  {18, 4, 31, 0, 0, []int{6, 22, 38}},
+	8:  {22, 2, 38, 2, 39, []int{6, 24, 42}},
+	9:  {22, 3, 36, 2, 37, []int{6, 26, 46}},
+	10: {26, 4, 43, 1, 44, []int{6, 28, 50}},
+}
+
+func (l blockLayout) dataCodewords() int {
+	return l.blocks1*l.dataLen1 + l.blocks2*l.dataLen2
+}
+
+// Code is an encoded QR code.
+type Code struct {
+	Size    int
+	modules [][]bool
+}
+
+// Dark reports whether the module at column x and row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// Encode returns the smallest QR code holding text.
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	for version := 1; version < len(versions); version++ {
+		layout := versions[version]
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) > 8*layout.dataCodewords() {
+			continue
+		}
+		codewords := encodeData(data, countBits, layout.dataCodewords())
+		return build(version, interleave(codewords, layout)), nil
+	}
+	return nil, ErrTooLong
+}
+
+// encodeData lays out data in byte mode and pads it to capacity codewords.
+func encodeData(data []byte, countBits, capacity int) []byte {
+	var bb bitBuffer
+	bb.append(0b0100, 4)
+	bb.append(len(data), countBits)
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+
+	terminator := 8*capacity - len(bb)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bb.append(0, terminator)
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < 8*capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+	return bb.bytes()
+}
+
+// interleave splits the data codewords into blocks, adds the error
+// correction codewords and interleaves the result.
+func interleave(data []byte, layout blockLayout) []byte {
+	var blocks, ecBlocks [][]byte
+	offset := 0
+	for i := 0; i < layout.blocks1+layout.blocks2; i++ {
+		n := layout.dataLen1
+		if i >= layout.blocks1 {
+			n = layout.dataLen2
+		}
+		block := data[offset : offset+n]
+		offset += n
+		blocks = append(blocks, block)
+		ecBlocks = append(ecBlocks, reedSolomon(block, layout.ecPerBlock))
+	}
+
+	var result []byte
+	for i := 0; i < max(layout.dataLen1, layout.dataLen2); i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < layout.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+// build draws the function patterns and codewords and applies the mask with
+// the lowest penalty.
+func build(version int, codewords []byte) *Code {
+	m := newMatrix(version)
+	m.drawFunctionPatterns(versions[version].alignmentPositions)
+	m.drawCodewords(codewords)
+
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.drawFormatBits(mask)
+		if p := m.penalty(); bestPenalty < 0 || p < bestPenalty {
+			bestMask, bestPenalty = mask, p
+		}
+		// Masking is its own inverse
+		m.applyMask(mask)
+	}
+	m.applyMask(bestMask)
+	m.drawFormatBits(bestMask)
+
+	return &Code{Size: m.size, modules: m.modules}
+}
+
+// Image renders the code with scale pixels per module and a quiet zone.
+func (c *Code) Image(scale int) image.Image {
+	size := (c.Size + 2*QuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.Dark(x, y) {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex((x+QuietZone)*scale+dx, (y+QuietZone)*scale+dy, 1)
+				}
+			}
+		}
+	}
+	return img
+}
+
+// PNG encodes text as a QR code image with scale pixels per module.
+func PNG(text string, scale int) ([]byte, error) {
+	code, err := Encode(text)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code.Image(scale)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type bitBuffer []bool
+
+func (bb *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*bb = append(*bb, (value>>i)&1 == 1)
+	}
+}
+
+func (bb bitBuffer) bytes() []byte {
+	result := make([]byte, len(bb)/8)
+	for i, bit := range bb {
+		if bit {
+			result[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return result
+}
@@ -0,0 +1,311 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// totalModules is the number of data and error correction modules of each
+// version, codewords and remainder bits, from the specification.
+var totalModules = []int{
+	1: 26 * 8, 2: 44*8 + 7, 3: 70*8 + 7, 4: 100*8 + 7, 5: 134*8 + 7,
+	6: 172*8 + 7, 7: 196 * 8, 8: 242 * 8, 9: 292 * 8, 10: 346 * 8,
+}
+
+var alignmentCentres = [][]int{
+	1: nil, 2: {6, 18}, 3: {6, 22}, 4: {6, 26}, 5: {6, 30},
+	6: {6, 34}, 7: {6, 22, 38}, 8: {6, 24, 42}, 9: {6, 26, 46}, 10: {6, 28, 50},
+}
+
+func TestEncodeVersion(t *testing.T) {
+	for _, tc := range []struct {
+		length  int
+		version int
+	}{
+		{0, 1},
+		{14, 1},
+		{15, 2},
+		{26, 2},
+		{27, 3},
+		{62, 4},
+		{63, 5},
+		{106, 6},
+		{122, 7},
+		{123, 8},
+		{180, 9},
+		{181, 10},
+		{213, 10},
+	} {
+		text := strings.Repeat("a", tc.length)
+		code, err := Encode(text)
+		if err != nil {
+			t.Errorf("%d bytes: Encode = %v", tc.length, err)
+			continue
+		}
+		version, level, _, decoded := decode(t, code)
+		if version != tc.version {
+			t.Errorf("%d bytes: version %d, want %d", tc.length, version, tc.version)
+		}
+		if level != levelM {
+			t.Errorf("%d bytes: error correction level %02b, want M", tc.length, level)
+		}
+		if decoded != text {
+			t.Errorf("%d bytes: decoded %q", tc.length, decoded)
+		}
+	}
+
+	if _, err := Encode(strings.Repeat("a", 214)); !errors.Is(err, ErrTooLong) {
+		t.Errorf("214 bytes: Encode = %v, want ErrTooLong", err)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, text := range []string{
+		"https://webring.example.org/",
+		"https://webring.example.org/12/next?t=7.1704110400.c2lnbmF0dXJl",
+		"Ünïcödé, \x00 and \xff",
+	} {
+		code, err := Encode(text)
+		if err != nil {
+			t.Fatalf("Encode(%q) = %v", text, err)
+		}
+		if _, _, _, decoded := decode(t, code); decoded != text {
+			t.Errorf("Encode(%q) decodes to %q", text, decoded)
+		}
+	}
+}
+
+func TestReedSolomon(t *testing.T) {
+	// The 1-M example of the specification, "01234567" in numeric mode
+	data := []byte{0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11}
+	want := []byte{0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55}
+	if got := reedSolomon(data, 10); !bytes.Equal(got, want) {
+		t.Errorf("reedSolomon = % X, want % X", got, want)
+	}
+}
+
+const levelM = 0b00
+
+// decode reads code back the way a scanner would and returns its version,
+// error correction level, mask and text. It fails the test on anything a
+// scanner would reject.
+func decode(t *testing.T, code *Code) (version, level, mask int, text string) {
+	t.Helper()
+	version = (code.Size - 17) / 4
+	if version < 1 || version >= len(totalModules) || code.Size != 17+4*version {
+		t.Fatalf("size %d is not a supported version", code.Size)
+	}
+	size := code.Size
+
+	// Both copies of the format information must agree and be valid
+	var first, second int
+	firstAt := [][2]int{{8, 0}, {8, 1}, {8, 2}, {8, 3}, {8, 4}, {8, 5}, {8, 7}, {8, 8}, {7, 8}, {5, 8}, {4, 8}, {3, 8}, {2, 8}, {1, 8}, {0, 8}}
+	for i, p := range firstAt {
+		if code.Dark(p[0], p[1]) {
+			first |= 1 << i
+		}
+		x, y := size-1-i, 8
+		if i >= 8 {
+			x, y = 8, size-15+i
+		}
+		if code.Dark(x, y) {
+			second |= 1 << i
+		}
+	}
+	if first != second {
+		t.Fatalf("format information copies differ: %015b and %015b", first, second)
+	}
+	format := first ^ 0x5412
+	rem := format >> 10
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	if rem != format&0x3FF {
+		t.Fatalf("format information %015b fails its check", first)
+	}
+	level, mask = format>>13, (format>>10)&7
+	if !code.Dark(8, size-8) {
+		t.Errorf("dark module is light")
+	}
+
+	reserved := functionModules(version)
+	var bits []bool
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < size; vert++ {
+			y := vert
+			if upward {
+				y = size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if !reserved[y][x] {
+					bits = append(bits, code.Dark(x, y) != masked(mask, x, y))
+				}
+			}
+		}
+	}
+	if len(bits) != totalModules[version] {
+		t.Fatalf("version %d has %d data modules, want %d", version, len(bits), totalModules[version])
+	}
+
+	codewords := bitBuffer(bits[:len(bits)/8*8]).bytes()
+	data := deinterleave(t, codewords, versions[version])
+
+	// Byte mode, the length, the text, a terminator and the pad codewords
+	r := bitReader{data: data}
+	if m := r.read(4); m != 0b0100 {
+		t.Fatalf("mode %04b, want byte mode", m)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	n := r.read(countBits)
+	if 4+countBits+8*n > 8*len(data) {
+		t.Fatalf("length %d overflows the data codewords", n)
+	}
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = byte(r.read(8))
+	}
+	if rest := 8*len(data) - r.pos; rest > 0 {
+		if r.read(min(rest, 4)) != 0 {
+			t.Errorf("missing terminator")
+		}
+		r.pos = (r.pos + 7) / 8 * 8
+		for pad := 0xEC; r.pos < 8*len(data); pad ^= 0xEC ^ 0x11 {
+			if got := r.read(8); got != pad {
+				t.Errorf("pad codeword %02X, want %02X", got, pad)
+				break
+			}
+		}
+	}
+	return version, level, mask, string(out)
+}
+
+// functionModules marks the modules of the function patterns, format and
+// version information of version, which carry no data.
+func functionModules(version int) [][]bool {
+	size := 17 + 4*version
+	reserved := make([][]bool, size)
+	for y := range reserved {
+		reserved[y] = make([]bool, size)
+	}
+	fill := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				reserved[y][x] = true
+			}
+		}
+	}
+
+	// Finders with their separators and format information
+	fill(0, 0, 9, 9)
+	fill(size-8, 0, 8, 9)
+	fill(0, size-8, 9, 8)
+	fill(6, 0, 1, size)
+	fill(0, 6, size, 1)
+
+	// Alignment patterns, except where they would overlap a finder
+	centres := alignmentCentres[version]
+	for _, x := range centres {
+		for _, y := range centres {
+			if (x < 9 && y < 9) || (x < 9 && y >= size-8) || (x >= size-8 && y < 9) {
+				continue
+			}
+			fill(x-2, y-2, 5, 5)
+		}
+	}
+	if version >= 7 {
+		fill(size-11, 0, 3, 6)
+		fill(0, size-11, 6, 3)
+	}
+	return reserved
+}
+
+// masked reports whether mask inverts the module at column x, row y.
+func masked(mask, x, y int) bool {
+	i, j := y, x
+	switch mask {
+	case 0:
+		return (i+j)%2 == 0
+	case 1:
+		return i%2 == 0
+	case 2:
+		return j%3 == 0
+	case 3:
+		return (i+j)%3 == 0
+	case 4:
+		return (i/2+j/3)%2 == 0
+	case 5:
+		return (i*j)%2+(i*j)%3 == 0
+	case 6:
+		return ((i*j)%2+(i*j)%3)%2 == 0
+	default:
+		return ((i+j)%2+(i*j)%3)%2 == 0
+	}
+}
+
+// deinterleave splits codewords into their blocks, checks that every block
+// is a valid Reed-Solomon codeword and returns the data codewords in order.
+func deinterleave(t *testing.T, codewords []byte, layout blockLayout) []byte {
+	t.Helper()
+	count := layout.blocks1 + layout.blocks2
+	if total := layout.dataCodewords() + count*layout.ecPerBlock; total != len(codewords) {
+		t.Fatalf("layout holds %d codewords, the code %d", total, len(codewords))
+	}
+
+	blocks := make([][]byte, count)
+	i := 0
+	for k := 0; k < max(layout.dataLen1, layout.dataLen2); k++ {
+		for b := range blocks {
+			if b < layout.blocks1 && k >= layout.dataLen1 {
+				continue
+			}
+			blocks[b] = append(blocks[b], codewords[i])
+			i++
+		}
+	}
+	for k := 0; k < layout.ecPerBlock; k++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[i])
+			i++
+		}
+	}
+
+	var data []byte
+	for b, block := range blocks {
+		// A valid codeword vanishes at the roots of the generator
+		for root := 0; root < layout.ecPerBlock; root++ {
+			syndrome := 0
+			for _, c := range block {
+				syndrome = gfMul(syndrome, expTable[root]) ^ int(c)
+			}
+			if syndrome != 0 {
+				t.Fatalf("block %d fails its error correction check", b)
+			}
+		}
+		data = append(data, block[:len(block)-layout.ecPerBlock]...)
+	}
+	return data
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		v = v<<1 | int(r.data[r.pos/8]>>(7-r.pos%8)&1)
+		r.pos++
+	}
+	return v
+}
@@ -0,0 +1,58 @@
+package qr
+
+// Arithmetic in GF(256) with the QR code polynomial x^8+x^4+x^3+x^2+1.
+var expTable, logTable [256]int
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		logTable[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	expTable[255] = expTable[0]
+}
+
+func gfMul(a, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[(logTable[a]+logTable[b])%255]
+}
+
+// reedSolomon returns the n error correction codewords for data.
+func reedSolomon(data []byte, n int) []byte {
+	// Generator polynomial (x - a^0)(x - a^1)...(x - a^(n-1)), highest
+	// coefficient first and the leading 1 left out
+	generator := make([]int, n)
+	generator[n-1] = 1
+	root := 1
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			generator[j] = gfMul(generator[j], root)
+			if j+1 < n {
+				generator[j] ^= generator[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+
+	result := make([]int, n)
+	for _, b := range data {
+		factor := int(b) ^ result[0]
+		copy(result, result[1:])
+		result[n-1] = 0
+		for i := range result {
+			result[i] ^= gfMul(generator[i], factor)
+		}
+	}
+
+	ec := make([]byte, n)
+	for i, v := range result {
+		ec[i] = byte(v)
+	}
+	return ec
+}
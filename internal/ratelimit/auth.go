@@ -0,0 +1,184 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"webring/internal/api/middleware"
+	"webring/internal/clock"
+	"webring/internal/jobs"
+	"webring/internal/notify"
+)
+
+const (
+	defaultAuthFailureLimit = 10
+	defaultAuthWindow       = 15 * time.Minute
+	defaultAuthBlock        = 30 * time.Minute
+
+	// authFailureRetention is how long failures are kept in the database for
+	// later inspection.
+	authFailureRetention = 7 * 24 * time.Hour
+)
+
+// AuthGuard tracks failed dashboard logins per client IP. A client is blocked
+// for AUTH_BLOCK_DURATION on its AUTH_FAILURE_LIMIT-th failure within
+// AUTH_FAILURE_WINDOW, and an auth_failures notification is sent.
+type AuthGuard struct {
+	db       *sql.DB
+	notifier *notify.Notifier
+
+	limit    int
+	window   time.Duration
+	blockFor time.Duration
+
+	mu       sync.Mutex
+	failures map[string][]time.Time
+	blocked  map[string]time.Time
+
+	// Clock times the failure windows and blocks. Tests replace it before
+	// first use.
+	Clock clock.Clock
+}
+
+func NewAuthGuard(db *sql.DB, notifier *notify.Notifier) *AuthGuard {
+	limit, err := strconv.Atoi(os.Getenv("AUTH_FAILURE_LIMIT"))
+	if err != nil || limit <= 0 {
+		limit = defaultAuthFailureLimit
+	}
+	return &AuthGuard{
+		db:       db,
+		notifier: notifier,
+		limit:    limit,
+		window:   envDuration("AUTH_FAILURE_WINDOW", defaultAuthWindow),
+		blockFor: envDuration("AUTH_BLOCK_DURATION", defaultAuthBlock),
+		failures: make(map[string][]time.Time),
+		blocked:  make(map[string]time.Time),
+		Clock:    clock.Real,
+	}
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// BlockedFor returns how much longer the client of r is blocked, or zero.
+func (g *AuthGuard) BlockedFor(r *http.Request) time.Duration {
+	ip := middleware.ClientIP(r)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	until, ok := g.blocked[ip]
+	if !ok {
+		return 0
+	}
+	if remaining := clock.Until(g.Clock, until); remaining > 0 {
+		return remaining
+	}
+	delete(g.blocked, ip)
+	return 0
+}
+
+// Fail records a failed login attempt from the client of r.
+func (g *AuthGuard) Fail(r *http.Request, username string) {
+	ip := middleware.ClientIP(r)
+	now := g.Clock.Now()
+
+	_, err := g.db.Exec("INSERT INTO auth_failures (ip, username, user_agent) VALUES ($1, $2, $3)", ip, username, r.UserAgent())
+	if err != nil {
+		log.Printf("Error recording auth failure: %v", err)
+	}
+
+	exceeded, count := g.record(ip, now)
+	if exceeded {
+		log.Printf("Blocking %s for %s after %d failed logins", ip, g.blockFor, count)
+		g.notifier.Notify(notify.Event{
+			Type:    notify.EventAuthFailures,
+			Message: fmt.Sprintf("%d failed dashboard logins from %s within %s, blocked for %s", count, ip, g.window, g.blockFor),
+		})
+	}
+}
+
+// record counts a failure from ip at now and blocks ip if it reached the
+// limit. It returns whether it did, and the failures within the window.
+func (g *AuthGuard) record(ip string, now time.Time) (bool, int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	recent := g.failures[ip][:0]
+	for _, t := range g.failures[ip] {
+		if now.Sub(t) < g.window {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+	g.failures[ip] = recent
+
+	if len(recent) < g.limit {
+		return false, len(recent)
+	}
+	g.blocked[ip] = now.Add(g.blockFor)
+	delete(g.failures, ip)
+	return true, len(recent)
+}
+
+// Succeed forgets the failures of the client of r after a successful login.
+func (g *AuthGuard) Succeed(r *http.Request) {
+	ip := middleware.ClientIP(r)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.failures, ip)
+}
+
+// JobPrune is the periodic job removing old failures from the database.
+const JobPrune = "auth_failures_prune"
+
+// Start periodically forgets old failures and expired blocks. They are kept
+// in the memory of each instance, so every instance runs it.
+func (g *AuthGuard) Start(interval time.Duration) {
+	ticker := g.Clock.NewTicker(interval)
+	for range ticker.C() {
+		g.prune()
+	}
+}
+
+// Register removes old failures from the database every interval on one of
+// the instances.
+func (g *AuthGuard) Register(w *jobs.Worker, interval time.Duration) {
+	w.Every(JobPrune, interval, g.pruneStored)
+}
+
+func (g *AuthGuard) prune() {
+	now := g.Clock.Now()
+
+	g.mu.Lock()
+	for ip, times := range g.failures {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) >= g.window {
+			delete(g.failures, ip)
+		}
+	}
+	for ip, until := range g.blocked {
+		if now.After(until) {
+			delete(g.blocked, ip)
+		}
+	}
+	g.mu.Unlock()
+}
+
+func (g *AuthGuard) pruneStored() {
+	_, err := g.db.Exec("DELETE FROM auth_failures WHERE created_at < $1", g.Clock.Now().Add(-authFailureRetention))
+	if err != nil {
+		log.Printf("Error pruning auth failures: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"webring/internal/clock"
+)
+
+func testGuard() (*AuthGuard, *clock.Fake) {
+	c := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	return &AuthGuard{
+		limit:    3,
+		window:   15 * time.Minute,
+		blockFor: 30 * time.Minute,
+		failures: make(map[string][]time.Time),
+		blocked:  make(map[string]time.Time),
+		Clock:    c,
+	}, c
+}
+
+func TestAuthGuardBlocks(t *testing.T) {
+	g, c := testGuard()
+	r := httptest.NewRequest("POST", "/login", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+
+	for n := 1; n < 3; n++ {
+		if exceeded, count := g.record("192.0.2.1", c.Now()); exceeded || count != n {
+			t.Fatalf("failure %d: record = %v, %d", n, exceeded, count)
+		}
+		c.Advance(time.Minute)
+	}
+	if d := g.BlockedFor(r); d != 0 {
+		t.Fatalf("blocked for %s before the limit", d)
+	}
+
+	// The limit-th failure blocks
+	if exceeded, count := g.record("192.0.2.1", c.Now()); !exceeded || count != 3 {
+		t.Fatalf("failure 3: record = %v, %d; want blocked", exceeded, count)
+	}
+	if d := g.BlockedFor(r); d != 30*time.Minute {
+		t.Errorf("BlockedFor = %s, want 30m", d)
+	}
+
+	other := httptest.NewRequest("POST", "/login", nil)
+	other.RemoteAddr = "192.0.2.2:1234"
+	if d := g.BlockedFor(other); d != 0 {
+		t.Errorf("other client blocked for %s", d)
+	}
+
+	c.Advance(30 * time.Minute)
+	if d := g.BlockedFor(r); d != 0 {
+		t.Errorf("still blocked for %s after the block expired", d)
+	}
+}
+
+func TestAuthGuardWindow(t *testing.T) {
+	g, c := testGuard()
+
+	g.record("192.0.2.1", c.Now())
+	c.Advance(10 * time.Minute)
+	g.record("192.0.2.1", c.Now())
+
+	// The first failure falls out of the window
+	c.Advance(5 * time.Minute)
+	if exceeded, count := g.record("192.0.2.1", c.Now()); exceeded || count != 2 {
+		t.Errorf("record = %v, %d; want 2 failures within the window", exceeded, count)
+	}
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"webring/internal/api/middleware"
+	"webring/internal/apikey"
+)
+
+const (
+	defaultAnonymousLimit = 120
+	defaultKeyLimit       = 1200
+	defaultListLimit      = 10
+)
+
+// bucket is a token bucket refilled at a constant rate up to its capacity.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter rate limits the public API per client: anonymous clients by IP
+// (RATE_LIMIT_ANONYMOUS requests per minute) and clients with an API key by
+// key (RATE_LIMIT_API_KEY requests per minute).
+type Limiter struct {
+	keys *apikey.Store
+
+	anonymousPerMinute float64
+	keyPerMinute       float64
+	listPerMinute      float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewLimiter(keys *apikey.Store) *Limiter {
+	return &Limiter{
+		keys:               keys,
+		anonymousPerMinute: envLimit("RATE_LIMIT_ANONYMOUS", defaultAnonymousLimit),
+		keyPerMinute:       envLimit("RATE_LIMIT_API_KEY", defaultKeyLimit),
+		listPerMinute:      envLimit("RATE_LIMIT_LIST", defaultListLimit),
+		buckets:            make(map[string]*bucket),
+	}
+}
+
+func envLimit(key string, fallback int) float64 {
+	limit, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || limit <= 0 {
+		limit = fallback
+	}
+	return float64(limit)
+}
+
+// allow takes a token from the bucket of client and otherwise returns how
+// long until one is available.
+func (l *Limiter) allow(client string, perMinute float64) (bool, time.Duration) {
+	now := time.Now()
+	rate := perMinute / 60
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: perMinute, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(perMinute, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// Start drops idle buckets every interval so the map does not grow forever.
+func (l *Limiter) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		l.mu.Lock()
+		for client, b := range l.buckets {
+			// A bucket idle for a minute is full again, same as a new one
+			if time.Since(b.last) > time.Minute {
+				delete(l.buckets, client)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, perMinute := "ip:"+middleware.ClientIP(r), l.anonymousPerMinute
+		if key := apikey.FromRequest(r); key != "" {
+			id, ok := l.keys.Lookup(key)
+			if !ok {
+				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+			client, perMinute = "key:"+strconv.Itoa(id), l.keyPerMinute
+		}
+
+		if l.limit(w, client, perMinute) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// ListLimit holds anonymous clients to RATE_LIMIT_LIST requests per minute
+// (10 by default) across the endpoints it wraps while enabled() returns
+// true, on top of the general limit. It is meant for the endpoints listing
+// every member, which would otherwise let scrapers around the navigation
+// tokens. Clients with an API key are only held to the general limit.
+func (l *Limiter) ListLimit(enabled func() bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !enabled() || apikey.FromRequest(r) != "" || l.limit(w, "list:"+middleware.ClientIP(r), l.listPerMinute) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// limit takes a request from the bucket of client and answers 429 if it is
+// empty. It reports whether the request may go on.
+func (l *Limiter) limit(w http.ResponseWriter, client string, perMinute float64) bool {
+	allowed, wait := l.allow(client, perMinute)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(int(perMinute)))
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+	}
+	return allowed
+}
+
+// RequireKey rejects requests without a valid API key while required()
+// returns true. The rate limiting middleware has already validated the key
+// when one is present.
+func RequireKey(required func() bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if required() && apikey.FromRequest(r) == "" {
+				http.Error(w, "An API key is required for this endpoint", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
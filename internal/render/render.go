@@ -0,0 +1,62 @@
+// Package render writes HTML templates to responses. Templates are executed
+// into a buffer first, so a failing template results in an error page with
+// status 500 rather than half a page sent with status 200.
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+)
+
+// HTML executes the named template and writes it with the given status code.
+// If execution fails nothing of the template is sent; the error is logged
+// and an error page is written instead.
+func HTML(w http.ResponseWriter, code int, t *template.Template, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		log.Printf("Error rendering template %s: %v", name, err)
+		Error(w, http.StatusInternalServerError)
+		return
+	}
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	w.WriteHeader(code)
+	if _, err := buf.WriteTo(w); err != nil {
+		log.Printf("Error writing %s: %v", name, err)
+	}
+}
+
+// errorPage does not use the parsed templates, which may be what failed.
+const errorPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Webring - %[1]d %[2]s</title>
+    <link rel="stylesheet" href="/static/public.css">
+</head>
+<body>
+<main>
+    <h1>%[1]d %[2]s</h1>
+    <p class="empty">Something went wrong while rendering this page. Please try again later.</p>
+</main>
+<footer>
+    <a href="/">Back to the listing</a>
+</footer>
+</body>
+</html>
+`
+
+// Error writes a plain error page with the given status code.
+func Error(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	if _, err := fmt.Fprintf(w, errorPage, code, http.StatusText(code)); err != nil {
+		log.Printf("Error writing error page: %v", err)
+	}
+}
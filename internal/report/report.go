@@ -0,0 +1,114 @@
+package report
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"webring/internal/notify"
+)
+
+const (
+	maxReasonLength  = 2000
+	maxContactLength = 200
+)
+
+var (
+	ErrSiteNotFound = errors.New("site not found")
+	// ErrInvalid wraps the errors caused by bad input.
+	ErrInvalid = errors.New("invalid report")
+)
+
+type Entry struct {
+	ID         int
+	SiteID     int
+	SiteName   string
+	SiteURL    string
+	Reason     string
+	Contact    string
+	IP         string
+	CreatedAt  time.Time
+	ResolvedAt *time.Time
+}
+
+// Submit files a report against a member and notifies the admins through the
+// site_reported notification rules.
+func Submit(db *sql.DB, notifier *notify.Notifier, siteID int, reason, contact, ip string) error {
+	reason = strings.TrimSpace(reason)
+	contact = strings.TrimSpace(contact)
+	if reason == "" {
+		return fmt.Errorf("%w: reason is required", ErrInvalid)
+	}
+	if utf8.RuneCountInString(reason) > maxReasonLength {
+		return fmt.Errorf("%w: reason must be at most %d characters", ErrInvalid, maxReasonLength)
+	}
+	if utf8.RuneCountInString(contact) > maxContactLength {
+		return fmt.Errorf("%w: contact must be at most %d characters", ErrInvalid, maxContactLength)
+	}
+
+	var name, url string
+	err := db.QueryRow("SELECT name, url FROM sites WHERE id = $1", siteID).Scan(&name, &url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSiteNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("INSERT INTO reports (site_id, reason, contact, ip) VALUES ($1, $2, $3, $4)", siteID, reason, contact, ip)
+	if err != nil {
+		return err
+	}
+
+	message := reason
+	if contact != "" {
+		message += " (reporter: " + contact + ")"
+	}
+	notifier.Notify(notify.Event{
+		Type:     notify.EventSiteReported,
+		SiteID:   siteID,
+		SiteName: name,
+		SiteURL:  url,
+		Message:  message,
+	})
+	return nil
+}
+
+// List returns the open reports, oldest first, followed by the resolved
+// ones, newest first.
+func List(db *sql.DB) ([]Entry, error) {
+	rows, err := db.Query(`
+        SELECT r.id, r.site_id, s.name, s.url, r.reason, r.contact, r.ip, r.created_at, r.resolved_at
+        FROM reports r
+        JOIN sites s ON s.id = r.site_id
+        ORDER BY r.resolved_at IS NOT NULL, CASE WHEN r.resolved_at IS NULL THEN r.created_at END, r.resolved_at DESC
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.SiteID, &e.SiteName, &e.SiteURL, &e.Reason, &e.Contact, &e.IP, &e.CreatedAt, &e.ResolvedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func Resolve(db *sql.DB, id int) error {
+	_, err := db.Exec("UPDATE reports SET resolved_at = NOW() WHERE id = $1 AND resolved_at IS NULL", id)
+	return err
+}
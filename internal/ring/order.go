@@ -0,0 +1,110 @@
+package ring
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ordering strategies, selected with the ring_order setting or per request
+// with order= on the navigation endpoints.
+const (
+	OrderManual       = "manual"
+	OrderAlphabetical = "alphabetical"
+	OrderJoinDate     = "join_date"
+	// OrderNewest is the reverse of OrderJoinDate.
+	OrderNewest       = "newest"
+	OrderDailyShuffle = "daily_shuffle"
+)
+
+func ValidOrder(order string) bool {
+	switch order {
+	case "", OrderManual, OrderAlphabetical, OrderJoinDate, OrderNewest, OrderDailyShuffle:
+		return true
+	}
+	return false
+}
+
+// member is a site as loaded for ordering, whether it is up or not.
+type member struct {
+	id           int
+	name         string
+	displayOrder *int
+	createdAt    *time.Time
+	isUp         bool
+}
+
+// sortMembers orders members in place. Ties, and members without the sort
+// key, fall back to the ID so the ordering is always stable.
+func sortMembers(members []member, order string, now time.Time) {
+	var less func(a, b member) bool
+	switch order {
+	case OrderAlphabetical:
+		less = func(a, b member) bool {
+			an, bn := strings.ToLower(a.name), strings.ToLower(b.name)
+			if an != bn {
+				return an < bn
+			}
+			return a.id < b.id
+		}
+	case OrderJoinDate:
+		less = joinedBefore
+	case OrderNewest:
+		less = func(a, b member) bool {
+			return joinedBefore(b, a)
+		}
+	case OrderDailyShuffle:
+		day := now.UTC().Format("2006-01-02")
+		less = func(a, b member) bool {
+			ah, bh := shuffleKey(day, a.id), shuffleKey(day, b.id)
+			if ah != bh {
+				return ah < bh
+			}
+			return a.id < b.id
+		}
+	default:
+		less = func(a, b member) bool {
+			switch {
+			case a.displayOrder == nil && b.displayOrder == nil:
+				return a.id < b.id
+			case a.displayOrder == nil:
+				return false
+			case b.displayOrder == nil:
+				return true
+			case *a.displayOrder != *b.displayOrder:
+				return *a.displayOrder < *b.displayOrder
+			}
+			return a.id < b.id
+		}
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		return less(members[i], members[j])
+	})
+}
+
+// joinedBefore orders members by join date. Members with an unknown join
+// date predate the column, so they go first.
+func joinedBefore(a, b member) bool {
+	switch {
+	case a.createdAt == nil && b.createdAt == nil:
+		return a.id < b.id
+	case a.createdAt == nil:
+		return true
+	case b.createdAt == nil:
+		return false
+	case !a.createdAt.Equal(*b.createdAt):
+		return a.createdAt.Before(*b.createdAt)
+	}
+	return a.id < b.id
+}
+
+// shuffleKey gives every site a pseudo-random but reproducible rank for the
+// day, so all instances agree on the ordering without coordination.
+func shuffleKey(day string, id int) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(day + ":" + strconv.Itoa(id)))
+	return h.Sum64()
+}
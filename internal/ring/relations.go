@@ -0,0 +1,55 @@
+package ring
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// reservedRelations are the navigation endpoints built into the service,
+// which custom relations can't replace.
+var reservedRelations = map[string]bool{
+	"next": true, "prev": true, "random": true, "data": true,
+	"snippet": true, "card": true, "report": true, "neighbors": true,
+}
+
+var relationName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ParseRelations reads the custom_relations setting: one "name = target" pair
+// per line, where target is a path on this service or an absolute http(s)
+// URL. Targets may contain {id}, {name} and {url}, which are replaced with
+// the member's values, escaped; a target starting with {url} extends the
+// member's URL. Empty lines and lines starting with # are ignored.
+func ParseRelations(s string) (map[string]string, error) {
+	relations := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		name, target, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected name = target", line)
+		}
+		name, target = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(target)
+		switch {
+		case !relationName.MatchString(name):
+			return nil, fmt.Errorf("line %d: invalid relation name %q", line, name)
+		case reservedRelations[name]:
+			return nil, fmt.Errorf("line %d: %q is a built-in endpoint", line, name)
+		case !strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "{url}") &&
+			!strings.HasPrefix(target, "https://") && !strings.HasPrefix(target, "http://"):
+			return nil, fmt.Errorf("line %d: target must be a path, {url} or an http(s) URL", line)
+		}
+		relations[name] = target
+	}
+	return relations, scanner.Err()
+}
+
+func ValidateRelations(s string) error {
+	_, err := ParseRelations(s)
+	return err
+}
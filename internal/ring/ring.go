@@ -0,0 +1,413 @@
+package ring
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"webring/internal/archive"
+	"webring/internal/clock"
+	"webring/internal/models"
+	"webring/internal/settings"
+)
+
+var (
+	ErrNotFound = errors.New("site not found")
+	// ErrEnd is returned for steps past the ends of the ring when it doesn't
+	// wrap around.
+	ErrEnd = errors.New("end of the ring")
+)
+
+// cacheTTL bounds how stale the ring can get if an invalidation is missed.
+const cacheTTL = time.Minute
+
+// Cache holds the ordered list of sites that take part in navigation.
+type Cache struct {
+	db *sql.DB
+	st *settings.Store
+	// order overrides the ring_order setting, for the caches returned by
+	// WithOrder
+	order string
+
+	mu    sync.RWMutex
+	sites []models.PublicSite
+	// ranks holds the position of every site, including the ones that are
+	// down, so navigation from a down site knows where to continue.
+	ranks map[int]int
+	// sitesJSON is sites serialized once per load, so /sites serves the same
+	// bytes, and ETag, until the ring changes.
+	sitesJSON []byte
+	loadedAt  time.Time
+	// ordered holds the caches of other orderings, by order
+	ordered map[string]*Cache
+
+	// Clock and Rand drive the cache expiry, the daily shuffle and random
+	// navigation. Tests replace them before first use; the caches of other
+	// orderings share them.
+	Clock clock.Clock
+	Rand  clock.Rand
+}
+
+func NewCache(db *sql.DB, st *settings.Store) *Cache {
+	return &Cache{db: db, st: st, Clock: clock.Real, Rand: clock.Global}
+}
+
+// Sites returns the current ring ordering. The returned slice must not be
+// modified. An archived ring keeps the ordering loaded first until it is
+// invalidated.
+func (c *Cache) Sites() ([]models.PublicSite, error) {
+	c.mu.RLock()
+	sites, loadedAt := c.sites, c.loadedAt
+	c.mu.RUnlock()
+
+	if !loadedAt.IsZero() && (archive.Enabled() || clock.Since(c.Clock, loadedAt) < cacheTTL) {
+		return sites, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another goroutine may have reloaded while we were waiting for the lock
+	if !c.loadedAt.IsZero() && (archive.Enabled() || clock.Since(c.Clock, c.loadedAt) < cacheTTL) {
+		return c.sites, nil
+	}
+
+	// Members failing the HTTPS requirement past the grace period are left out
+	var httpsCutoff time.Time
+	if c.st.Bool(settings.RequireHTTPS) {
+		httpsCutoff = c.Clock.Now().Add(-c.st.Duration(settings.HTTPSGracePeriod, settings.DefaultHTTPSGracePeriod))
+	}
+
+	sites, ranks, err := loadSites(c.db, c.orderName(), httpsCutoff, c.Clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	sitesJSON, err := json.Marshal(sites)
+	if err != nil {
+		return nil, err
+	}
+	c.sites = sites
+	c.ranks = ranks
+	c.sitesJSON = append(sitesJSON, '\n')
+	c.loadedAt = c.Clock.Now()
+	return sites, nil
+}
+
+// SitesJSON returns the current ring ordering as JSON. The returned slice
+// must not be modified.
+func (c *Cache) SitesJSON() ([]byte, error) {
+	if _, err := c.Sites(); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sitesJSON, nil
+}
+
+// orderName returns the ordering of the cache.
+func (c *Cache) orderName() string {
+	if c.order != "" {
+		return c.order
+	}
+	if order := c.st.Get(settings.RingOrder); order != "" {
+		return order
+	}
+	return OrderManual
+}
+
+// WithOrder returns the ring in another ordering than the ring_order setting,
+// for widgets that traverse it differently. order must be valid; an empty
+// order, or the one the ring already uses, returns c.
+func (c *Cache) WithOrder(order string) *Cache {
+	if order == "" || order == c.orderName() {
+		return c
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ordered == nil {
+		c.ordered = make(map[string]*Cache)
+	}
+	oc, ok := c.ordered[order]
+	if !ok {
+		oc = &Cache{db: c.db, st: c.st, order: order, Clock: c.Clock, Rand: c.Rand}
+		c.ordered[order] = oc
+	}
+	return oc
+}
+
+// rank returns the position of id in the full ordering, including sites that
+// are down.
+func (c *Cache) rank(id int) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	r, ok := c.ranks[id]
+	return r, ok
+}
+
+// Invalidate forces the next call to Sites to reload from the database, in
+// every ordering.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadedAt = time.Time{}
+	for _, oc := range c.ordered {
+		oc.Invalidate()
+	}
+}
+
+// loadSites returns the members that take part in navigation, in order, and
+// the rank of every site. Sites with an HTTPS issue older than httpsCutoff are
+// treated like sites that are down; a zero cutoff disables this. Sites that
+// are down during a maintenance window stay in the ring if their owner asked
+// for it, marked with Maintenance. Sites on hiatus are left out.
+func loadSites(db *sql.DB, order string, httpsCutoff, now time.Time) ([]models.PublicSite, map[int]int, error) {
+	members, bySite, err := loadMembers(db, httpsCutoff, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	sites, ranks := arrange(members, bySite, order, now)
+	return sites, ranks, nil
+}
+
+// Preview returns the ring as it would be with site in it, placed by
+// displayOrder and the current ordering setting. site replaces the member
+// with the same ID, or joins the ring as a new member; either way it is
+// treated as up.
+func (c *Cache) Preview(site models.PublicSite, displayOrder *int) ([]models.PublicSite, error) {
+	var httpsCutoff time.Time
+	if c.st.Bool(settings.RequireHTTPS) {
+		httpsCutoff = c.Clock.Now().Add(-c.st.Duration(settings.HTTPSGracePeriod, settings.DefaultHTTPSGracePeriod))
+	}
+	now := c.Clock.Now()
+	members, bySite, err := loadMembers(c.db, httpsCutoff, now)
+	if err != nil {
+		return nil, err
+	}
+
+	candidate := member{id: site.ID, name: site.Name, displayOrder: displayOrder, createdAt: &now, isUp: true}
+	replaced := false
+	for i, m := range members {
+		if m.id == site.ID {
+			candidate.createdAt = m.createdAt
+			members[i] = candidate
+			replaced = true
+		}
+	}
+	if !replaced {
+		members = append(members, candidate)
+	}
+	bySite[site.ID] = site
+
+	sites, _ := arrange(members, bySite, c.st.Get(settings.RingOrder), now)
+	return sites, nil
+}
+
+// loadMembers loads every site, keyed by ID, together with what is needed to
+// order them. Maintenance windows and hiatuses are evaluated as of now.
+func loadMembers(db *sql.DB, httpsCutoff, now time.Time) ([]member, map[int]models.PublicSite, error) {
+	rows, err := db.Query(`
+		SELECT id, name, url, favicon, support_url, is_up, display_order, created_at, https_issue_since,
+		       (maintenance_keep_in_ring AND maintenance_start <= $1 AND $1 < maintenance_end) IS TRUE,
+		       (hiatus_until > $1) IS TRUE
+		FROM sites`, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	var members []member
+	bySite := make(map[int]models.PublicSite)
+	for rows.Next() {
+		var site models.PublicSite
+		var m member
+		var httpsIssueSince *time.Time
+		var keepInRing, onHiatus bool
+		if err := rows.Scan(&site.ID, &site.Name, &site.URL, &site.Favicon, &site.SupportURL, &m.isUp, &m.displayOrder, &m.createdAt, &httpsIssueSince, &keepInRing, &onHiatus); err != nil {
+			return nil, nil, err
+		}
+		if !m.isUp && keepInRing {
+			m.isUp = true
+			site.Maintenance = true
+		}
+		if onHiatus {
+			m.isUp = false
+		}
+		if !httpsCutoff.IsZero() && httpsIssueSince != nil && httpsIssueSince.Before(httpsCutoff) {
+			m.isUp = false
+		}
+		m.id, m.name = site.ID, site.Name
+		members = append(members, m)
+		bySite[site.ID] = site
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return members, bySite, nil
+}
+
+// arrange sorts members as of now and returns the ones that are up, in order,
+// and the rank of every member.
+func arrange(members []member, bySite map[int]models.PublicSite, order string, now time.Time) ([]models.PublicSite, map[int]int) {
+	sortMembers(members, order, now)
+
+	var sites []models.PublicSite
+	ranks := make(map[int]int, len(members))
+	for i, m := range members {
+		ranks[m.id] = i
+		if m.isUp {
+			sites = append(sites, bySite[m.id])
+		}
+	}
+	return sites, ranks
+}
+
+// Index returns the position of the site with the given ID in sites, or -1.
+func Index(sites []models.PublicSite, id int) int {
+	for i, site := range sites {
+		if site.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Wraps reports whether navigation wraps around the ends of the ring, which
+// it does unless the linear_navigation setting is on.
+func (c *Cache) Wraps() bool {
+	return !c.st.Bool(settings.LinearNavigation)
+}
+
+// NextIndex returns the index of the site following id in sites, which must
+// come from Sites. id does not have to be part of the ring: navigation from a
+// member that is currently down or on hiatus continues with the first site
+// after it. Past the last site it continues with the first, or returns ErrEnd
+// if the ring doesn't wrap around. IDs that are not members at all return
+// ErrNotFound.
+func (c *Cache) NextIndex(sites []models.PublicSite, id int) (int, error) {
+	if len(sites) == 0 {
+		return 0, ErrNotFound
+	}
+	if i := Index(sites, id); i >= 0 {
+		if i == len(sites)-1 && !c.Wraps() {
+			return 0, ErrEnd
+		}
+		return (i + 1) % len(sites), nil
+	}
+	r, ok := c.rank(id)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	for i, site := range sites {
+		if sr, _ := c.rank(site.ID); sr > r {
+			return i, nil
+		}
+	}
+	if !c.Wraps() {
+		return 0, ErrEnd
+	}
+	return 0, nil
+}
+
+// PrevIndex is the counterpart of NextIndex.
+func (c *Cache) PrevIndex(sites []models.PublicSite, id int) (int, error) {
+	if len(sites) == 0 {
+		return 0, ErrNotFound
+	}
+	if i := Index(sites, id); i >= 0 {
+		if i == 0 && !c.Wraps() {
+			return 0, ErrEnd
+		}
+		return (i - 1 + len(sites)) % len(sites), nil
+	}
+	r, ok := c.rank(id)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	for i := len(sites) - 1; i >= 0; i-- {
+		if sr, _ := c.rank(sites[i].ID); sr < r {
+			return i, nil
+		}
+	}
+	if !c.Wraps() {
+		return 0, ErrEnd
+	}
+	return len(sites) - 1, nil
+}
+
+// Data returns the neighbours of a site that is part of the ring. If the
+// ring doesn't wrap around, First or Last is set at its ends and the missing
+// neighbour is left empty.
+func Data(sites []models.PublicSite, id int, wrap bool) (*models.SiteData, error) {
+	i := Index(sites, id)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+
+	data := &models.SiteData{
+		Curr:     sites[i],
+		Position: i + 1,
+		Total:    len(sites),
+	}
+	if i > 0 || wrap {
+		data.Prev = sites[(i-1+len(sites))%len(sites)]
+	} else {
+		data.First = true
+	}
+	if i < len(sites)-1 || wrap {
+		data.Next = sites[(i+1)%len(sites)]
+	} else {
+		data.Last = true
+	}
+	return data, nil
+}
+
+// RandomIndex picks a random site other than id, with the cache's Rand.
+func (c *Cache) RandomIndex(sites []models.PublicSite, id int) (int, error) {
+	var candidates []int
+	for i, site := range sites {
+		if site.ID != id {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return 0, ErrNotFound
+	}
+	return candidates[c.Rand.Intn(len(candidates))], nil
+}
+
+// Neighbors returns up to depth sites in each direction from a site that is
+// part of the ring. Chains stop before they wrap around to the site itself,
+// and at the ends of the ring unless wrap is set.
+func Neighbors(sites []models.PublicSite, id int, depth int, wrap bool) (*models.Neighbors, error) {
+	i := Index(sites, id)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	depth = min(depth, len(sites)-1)
+
+	n := &models.Neighbors{
+		Curr:     sites[i],
+		Next:     make([]models.PublicSite, 0, depth),
+		Prev:     make([]models.PublicSite, 0, depth),
+		Position: i + 1,
+		Total:    len(sites),
+	}
+	for d := 1; d <= depth; d++ {
+		if wrap || i+d < len(sites) {
+			n.Next = append(n.Next, sites[(i+d)%len(sites)])
+		}
+		if wrap || i-d >= 0 {
+			n.Prev = append(n.Prev, sites[(i-d+len(sites))%len(sites)])
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,123 @@
+package ring
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"webring/internal/models"
+	"webring/internal/settings"
+)
+
+// testRing returns a cache over members 1 to 5 in order, with 3 down, and
+// the sites that take part in navigation.
+func testRing(linear bool) (*Cache, []models.PublicSite) {
+	values := map[string]string{}
+	if linear {
+		values[settings.LinearNavigation] = "true"
+	}
+	c := NewCache(nil, settings.NewStatic(values))
+	c.ranks = map[int]int{1: 0, 2: 1, 3: 2, 4: 3, 5: 4}
+	sites := []models.PublicSite{{ID: 1}, {ID: 2}, {ID: 4}, {ID: 5}}
+	return c, sites
+}
+
+func TestNavigationWraps(t *testing.T) {
+	c, sites := testRing(false)
+	for _, tc := range []struct {
+		id, next, prev int
+	}{
+		{1, 2, 5},
+		{2, 4, 1},
+		{5, 1, 4},
+		// 3 is down: navigation from it continues with its neighbours
+		{3, 4, 2},
+	} {
+		i, err := c.NextIndex(sites, tc.id)
+		if err != nil || sites[i].ID != tc.next {
+			t.Errorf("NextIndex(%d) = %v, %v; want site %d", tc.id, sites[i].ID, err, tc.next)
+		}
+		i, err = c.PrevIndex(sites, tc.id)
+		if err != nil || sites[i].ID != tc.prev {
+			t.Errorf("PrevIndex(%d) = %v, %v; want site %d", tc.id, sites[i].ID, err, tc.prev)
+		}
+	}
+}
+
+func TestNavigationLinear(t *testing.T) {
+	c, sites := testRing(true)
+	if _, err := c.NextIndex(sites, 5); !errors.Is(err, ErrEnd) {
+		t.Errorf("NextIndex(last) error = %v, want ErrEnd", err)
+	}
+	if _, err := c.PrevIndex(sites, 1); !errors.Is(err, ErrEnd) {
+		t.Errorf("PrevIndex(first) error = %v, want ErrEnd", err)
+	}
+	if i, err := c.NextIndex(sites, 4); err != nil || sites[i].ID != 5 {
+		t.Errorf("NextIndex(4) = %v, %v; want site 5", sites[i].ID, err)
+	}
+
+	// A down member at an end has no neighbour on that side either
+	c.ranks[6] = 5
+	if _, err := c.NextIndex(sites, 6); !errors.Is(err, ErrEnd) {
+		t.Errorf("NextIndex(down last) error = %v, want ErrEnd", err)
+	}
+
+	data, err := Data(sites, 5, c.Wraps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !data.Last || data.First || data.Next.ID != 0 || data.Prev.ID != 4 {
+		t.Errorf("Data(last) = %+v, want Last with only Prev set", data)
+	}
+	data, err = Data(sites, 1, c.Wraps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !data.First || data.Last || data.Prev.ID != 0 || data.Next.ID != 2 {
+		t.Errorf("Data(first) = %+v, want First with only Next set", data)
+	}
+}
+
+func TestNavigationUnknown(t *testing.T) {
+	c, sites := testRing(false)
+	if _, err := c.NextIndex(sites, 42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("NextIndex(unknown) error = %v, want ErrNotFound", err)
+	}
+	if _, err := c.PrevIndex(sites, 42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("PrevIndex(unknown) error = %v, want ErrNotFound", err)
+	}
+	if _, err := Data(sites, 3, true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Data(down) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRandomIndex(t *testing.T) {
+	picks := func() []int {
+		c, sites := testRing(false)
+		c.Rand = rand.New(rand.NewSource(1))
+		var ids []int
+		for n := 0; n < 20; n++ {
+			i, err := c.RandomIndex(sites, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sites[i].ID == 2 {
+				t.Fatal("RandomIndex picked the site itself")
+			}
+			ids = append(ids, sites[i].ID)
+		}
+		return ids
+	}
+
+	first, second := picks(), picks()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("picks differ with the same seed: %v and %v", first, second)
+		}
+	}
+
+	c, _ := testRing(false)
+	if _, err := c.RandomIndex([]models.PublicSite{{ID: 2}}, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RandomIndex(alone) error = %v, want ErrNotFound", err)
+	}
+}
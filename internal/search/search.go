@@ -0,0 +1,83 @@
+package search
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"unicode"
+
+	"webring/internal/models"
+)
+
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
+// document must stay in sync with the expression of sites_search_idx so the
+// index can be used. Punctuation in URLs is turned into spaces so that
+// "example.com/blog" matches "example" and "blog".
+const document = `to_tsvector('simple', name || ' ' || regexp_replace(url, '[^[:alnum:]]+', ' ', 'g'))`
+
+type Hit struct {
+	models.PublicSite
+	Rank float64 `json:"rank"`
+}
+
+type Result struct {
+	Total int   `json:"total"`
+	Hits  []Hit `json:"results"`
+}
+
+// Query builds a prefix tsquery from free-form input, so partial words match
+// while typing. It returns an empty string if the input has no searchable words.
+func Query(input string) string {
+	words := strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// Sites searches the names and URLs of the members that are up, best matches
+// first. page starts at 1.
+func Sites(db *sql.DB, input string, page, perPage int) (Result, error) {
+	result := Result{Hits: []Hit{}}
+	tsquery := Query(input)
+	if tsquery == "" {
+		return result, nil
+	}
+
+	err := db.QueryRow("SELECT COUNT(*) FROM sites WHERE is_up = true AND "+document+" @@ to_tsquery('simple', $1)", tsquery).Scan(&result.Total)
+	if err != nil {
+		return Result{}, err
+	}
+
+	rows, err := db.Query(`
+        SELECT id, name, url, favicon, ts_rank(`+document+`, query) AS rank
+        FROM sites, to_tsquery('simple', $1) query
+        WHERE is_up = true AND `+document+` @@ query
+        ORDER BY rank DESC, id
+        LIMIT $2 OFFSET $3
+    `, tsquery, perPage, (page-1)*perPage)
+	if err != nil {
+		return Result{}, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var hit Hit
+		if err := rows.Scan(&hit.ID, &hit.Name, &hit.URL, &hit.Favicon, &hit.Rank); err != nil {
+			return Result{}, err
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	return result, rows.Err()
+}
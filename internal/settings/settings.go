@@ -0,0 +1,389 @@
+package settings
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultHTTPSGracePeriod applies when https_grace_period is not set.
+const DefaultHTTPSGracePeriod = 14 * 24 * time.Hour
+
+const (
+	MaintenanceMode    = "maintenance_mode"
+	MaintenanceBanner  = "maintenance_banner"
+	NotificationRules  = "notification_rules"
+	EscalationPolicy   = "escalation_policy"
+	NotifyDedupeWindow = "notify_dedupe_window"
+	SLOTarget          = "slo_target"
+	SLOWindow          = "slo_window"
+	Timezone           = "timezone"
+	StripEmojiInNames  = "strip_emoji_in_names"
+
+	AllowedURLSchemes    = "allowed_url_schemes"
+	AllowOverlayNetworks = "allow_overlay_networks"
+	SchemeCheckStrategy  = "scheme_check_strategy"
+	RequireHTTPS         = "require_https"
+	HTTPSGracePeriod     = "https_grace_period"
+	CheckerDryRun        = "checker_dry_run"
+	FaviconFallback      = "favicon_fallback"
+
+	RingName       = "ring_name"
+	RingSupportURL = "ring_support_url"
+	RingOrder      = "ring_order"
+	// LinearNavigation stops next/prev at the ends of the ring instead of
+	// wrapping around.
+	LinearNavigation = "linear_navigation"
+
+	CustomRelations = "custom_relations"
+
+	HomeIntro = "home_intro"
+	HomeFAQ   = "home_faq"
+	HomeJoin  = "home_join"
+
+	RingDataRequiresKey = "ring_data_requires_key"
+	NavigationTokens    = "navigation_tokens"
+	PublicStatsFields   = "public_stats_fields"
+	RingFounded         = "ring_founded"
+	VisitorCounter      = "visitor_counter"
+	Guestbook           = "guestbook"
+)
+
+// Definition describes a setting that can be edited from the dashboard.
+type Definition struct {
+	Key         string
+	Label       string
+	Description string
+	Type        string // "bool", "text", "textarea" or "markdown"
+}
+
+var Definitions = []Definition{
+	{
+		Key:         MaintenanceMode,
+		Label:       "Maintenance mode",
+		Description: "Reject all write requests with 503 while keeping public pages and navigation available.",
+		Type:        "bool",
+	},
+	{
+		Key:         MaintenanceBanner,
+		Label:       "Maintenance banner",
+		Description: "Message shown on public pages. Leave empty to hide the banner.",
+		Type:        "text",
+	},
+	{
+		Key:         StripEmojiInNames,
+		Label:       "Strip emoji from site names",
+		Description: "Remove emoji and other pictographs from site names when they are saved.",
+		Type:        "bool",
+	},
+	{
+		Key:         AllowedURLSchemes,
+		Label:       "Allowed URL schemes",
+		Description: "Comma separated list of schemes members may use, e.g. https, http, gemini. Defaults to https, http.",
+		Type:        "text",
+	},
+	{
+		Key:         AllowOverlayNetworks,
+		Label:       "Allow overlay networks",
+		Description: "Accept .onion and .i2p members.",
+		Type:        "bool",
+	},
+	{
+		Key:         SchemeCheckStrategy,
+		Label:       "Check strategy per scheme",
+		Description: "Comma separated scheme=strategy pairs, e.g. gemini=skip, onion=proxy. Strategies: check (as-is), proxy (via CHECKER_OVERLAY_PROXY), skip (keep the current status). Overlay networks default to proxy when CHECKER_OVERLAY_PROXY is set and skip otherwise; schemes other than http and https default to skip.",
+		Type:        "text",
+	},
+	{
+		Key:         RequireHTTPS,
+		Label:       "Require HTTPS",
+		Description: "Flag members whose URL uses plain HTTP, fails TLS or redirects to HTTP, warn their owners through the site webhook and leave them out of the ring once the grace period is over. Overlay networks are exempt.",
+		Type:        "bool",
+	},
+	{
+		Key:         HTTPSGracePeriod,
+		Label:       "HTTPS grace period",
+		Description: "How long a member may fail the HTTPS requirement before it is left out of the ring, e.g. 72h. Defaults to 336h (14 days).",
+		Type:        "text",
+	},
+	{
+		Key:         CheckerDryRun,
+		Label:       "Checker dry run",
+		Description: "Run the uptime checks but only record their results in the uptime_checks_staging table, without changing site status, history or sending notifications. Useful when tuning timeouts, probes or the proxy.",
+		Type:        "bool",
+	},
+	{
+		Key:         FaviconFallback,
+		Label:       "Favicon fallback service",
+		Description: "Last resort when a member's favicon can't be scraped: duckduckgo or google. These services learn which hosts the ring links to, so leave empty to keep favicon fetching direct.",
+		Type:        "text",
+	},
+	{
+		Key:         RingName,
+		Label:       "Ring name",
+		Description: "Name of the webring shown on member preview cards.",
+		Type:        "text",
+	},
+	{
+		Key:         RingSupportURL,
+		Label:       "Ring support link",
+		Description: "Donation or sponsor link for the ring itself, shown in the directory footer. Leave empty to hide it.",
+		Type:        "text",
+	},
+	{
+		Key:         HomeIntro,
+		Label:       "Homepage introduction",
+		Description: "Markdown shown above the member list on the public homepage. Leave empty to hide it.",
+		Type:        "markdown",
+	},
+	{
+		Key:         HomeFAQ,
+		Label:       "Homepage FAQ",
+		Description: "Markdown shown below the introduction, e.g. a few \"## Question\" headings with answers. Leave empty to hide it.",
+		Type:        "markdown",
+	},
+	{
+		Key:         HomeJoin,
+		Label:       "How to join",
+		Description: "Markdown with join instructions, shown after the FAQ. Leave empty to hide it.",
+		Type:        "markdown",
+	},
+	{
+		Key:         RingOrder,
+		Label:       "Ring order",
+		Description: "How members are ordered for next/prev navigation: manual (by the Order column, then ID), alphabetical, join_date, newest (latest members first) or daily_shuffle (a new deterministic order every day, UTC). Defaults to manual.",
+		Type:        "text",
+	},
+	{
+		Key:         LinearNavigation,
+		Label:       "Stop at the ends of the ring",
+		Description: "Next from the last member and previous from the first lead back to the directory instead of wrapping around, for a linear tour. The data endpoints then return the directory in place of the missing neighbour.",
+		Type:        "bool",
+	},
+	{
+		Key:         CustomRelations,
+		Label:       "Custom navigation endpoints",
+		Description: "Extra /{id}/{name} redirects, one \"name = target\" per line, e.g. \"home = /\" or \"about = {url}/about\". Targets are paths on this service or http(s) URLs and may use {id}, {name} and {url} of the member. Built-in endpoints (next, prev, random, data, neighbors, snippet, card, report) can't be replaced.",
+		Type:        "textarea",
+	},
+	{
+		Key:         RingDataRequiresKey,
+		Label:       "Require an API key for /ring/data",
+		Description: "Only serve the whole-ring endpoint to clients with an API key from the API keys page.",
+		Type:        "bool",
+	},
+	{
+		Key:         NavigationTokens,
+		Label:       "Require navigation tokens",
+		Description: "Only answer the next/prev/random, neighbors and data endpoints of a member with a short-lived token, which the widget script hands out to pages on the member's own host. Static HTML snippets stop working; members need the widget. The page is recognized by its Referer header, which scripts can fake, so this keeps other sites from linking into the navigation but only slows down scrapers; the member lists and search are rate limited more tightly meanwhile.",
+		Type:        "bool",
+	},
+	{
+		Key:         PublicStatsFields,
+		Label:       "Public stats fields",
+		Description: "Comma separated list of fields returned by /stats: members, members_up, uptime_average, newest_member, founded, age_days. Leave empty to return all of them.",
+		Type:        "text",
+	},
+	{
+		Key:         RingFounded,
+		Label:       "Ring founded",
+		Description: "Date the ring was started (YYYY-MM-DD), used for the ring age in /stats. Defaults to the earliest known join date.",
+		Type:        "text",
+	},
+	{
+		Key:         Guestbook,
+		Label:       "Guestbook",
+		Description: "Let visitors sign the ring's guestbook at /guestbook. Entries are published once approved on the dashboard's Guestbook page; admins hear about new ones through guestbook_entry notification rules.",
+		Type:        "bool",
+	},
+	{
+		Key:         VisitorCounter,
+		Label:       "Count ring visitors",
+		Description: "Count the visitors sent to each member by the next, prev and random redirects, and the views of their /b/{id}.gif beacon, shown on the Visitors page. Daily unique visitors are estimated from IPs hashed with a salt that is thrown away at the end of the day; IPs are never stored.",
+		Type:        "bool",
+	},
+	{
+		Key:         Timezone,
+		Label:       "Timezone",
+		Description: "IANA time zone, e.g. Europe/Moscow, used for notification quiet hours and timestamps. Rules can override it with \"timezone\". Defaults to the server's local time.",
+		Type:        "text",
+	},
+	{
+		Key:         NotificationRules,
+		Label:       "Notification rules",
+		Description: `JSON array of rules, e.g. [{"event": "site_down", "channel": "webhook", "target": "https://...", "min_duration": "10m", "quiet_hours": "23:00-07:00"}]. Events: site_down, site_up, auth_failures (a client was blocked after repeated failed dashboard logins), site_reported (a visitor reported a member), https_issue (a member started failing the HTTPS requirement), slo_breach and slo_recovered (the ring fell below or is back within its uptime objective), guestbook_entry (a visitor signed the guestbook), hiatus_ending (a member returns from hiatus the next day). Channels: log, webhook, email, issue (opens and resolves issues, see Down escalation). Add "timezone" to evaluate quiet_hours in the recipient's time zone.`,
+		Type:        "textarea",
+	},
+	{
+		Key:         NotifyDedupeWindow,
+		Label:       "Notification dedupe window",
+		Description: "Send at most one notification of each event type per site within this window, e.g. 1h. Later ones are held back and summed up when the window ends, e.g. \"site is flapping: went down 7 times in the last 1h\" (event repeated, matched by the rules of the held back event). Rules with a min_duration still get every event. Leave empty to send everything.",
+		Type:        "text",
+	},
+	{
+		Key:         SLOTarget,
+		Label:       "Uptime objective",
+		Description: "Percentage of members that should be up at any time, e.g. 95. Shown on the dashboard and /status; when the ring stays below it for the alert window an slo_breach notification is sent, and slo_recovered once it is met again. Leave empty to disable.",
+		Type:        "text",
+	},
+	{
+		Key:         SLOWindow,
+		Label:       "Uptime objective alert window",
+		Description: "How long the ring has to stay below the objective before admins are alerted, e.g. 30m. Defaults to 15m.",
+		Type:        "text",
+	},
+	{
+		Key:         EscalationPolicy,
+		Label:       "Down escalation",
+		Description: `JSON object describing who hears about an outage and when, e.g. {"admins_after": "30m", "admins": [{"channel": "email", "target": "ops@example.com"}], "issue_after": "6h", "issue_webhook": "https://..."}. The owner's site webhook is told right away; admins only if the site is still down after admins_after, and an issue is opened through issue_webhook after issue_after. Both are told when the site recovers. The issue webhook receives {"action": "open" or "resolve", "title", "body", "labels", "site_id", "site_url", "since"}.`,
+		Type:        "textarea",
+	},
+}
+
+var errStatic = errors.New("settings: the store is not backed by the database")
+
+// Store keeps an in-memory copy of the settings table. Reads never touch the
+// database; writes go through to the database and update the cache.
+type Store struct {
+	db *sql.DB
+
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+func New(db *sql.DB) *Store {
+	s := &Store{db: db, values: make(map[string]string)}
+	if err := s.Reload(); err != nil {
+		log.Printf("Error loading settings: %v", err)
+	}
+	return s
+}
+
+// NewStatic returns a store holding values that is not backed by the
+// database, for tests and simulations. Writing to it fails.
+func NewStatic(values map[string]string) *Store {
+	s := &Store{values: make(map[string]string)}
+	for k, v := range values {
+		s.values[k] = v
+	}
+	return s
+}
+
+func (s *Store) Reload() error {
+	if s.db == nil {
+		return nil
+	}
+	rows, err := s.db.Query("SELECT key, value FROM settings")
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		values[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = values
+	return nil
+}
+
+func (s *Store) Get(key string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.values[key]
+}
+
+func (s *Store) Bool(key string) bool {
+	b, _ := strconv.ParseBool(s.Get(key))
+	return b
+}
+
+// Duration parses a setting such as "72h", returning fallback when it is
+// unset or invalid.
+func (s *Store) Duration(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(s.Get(key))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+func (s *Store) All() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	values := make(map[string]string, len(s.values))
+	for k, v := range s.values {
+		values[k] = v
+	}
+	return values
+}
+
+func (s *Store) Set(key, value string) error {
+	if s.db == nil {
+		return errStatic
+	}
+	_, err := s.db.Exec("INSERT INTO settings (key, value) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value", key, value)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	return nil
+}
+
+// SetAll stores several settings in one transaction, so either all of them
+// change or none does.
+func (s *Store) SetAll(values map[string]string) error {
+	if s.db == nil {
+		return errStatic
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for key, value := range values {
+		_, err := tx.Exec("INSERT INTO settings (key, value) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value", key, value)
+		if err != nil {
+			if rerr := tx.Rollback(); rerr != nil {
+				log.Printf("Error rolling back settings: %v", rerr)
+			}
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, value := range values {
+		s.values[key] = value
+	}
+	return nil
+}
+
+// ReadOnly reports whether write requests should currently be rejected.
+func (s *Store) ReadOnly() bool {
+	return s.Bool(MaintenanceMode)
+}
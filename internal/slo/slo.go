@@ -0,0 +1,141 @@
+// Package slo tracks the ring-wide uptime objective: the share of members
+// that should be up at any time. Every check round stores how many members
+// were up in ring_health, which the dashboard, the /status page and the
+// alerting in the checker read back.
+package slo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"webring/internal/settings"
+)
+
+// DefaultWindow is how long the ring has to stay below the target before
+// admins are alerted, when slo_window is not set.
+const DefaultWindow = 15 * time.Minute
+
+// Status is the current state of the ring against its objective.
+type Status struct {
+	// Target is the percentage of members that should be up; 0 when no
+	// objective is set.
+	Target float64       `json:"target,omitempty"`
+	Window time.Duration `json:"-"`
+
+	Members   int       `json:"members"`
+	Up        int       `json:"up"`
+	Percent   float64   `json:"percent"`
+	CheckedAt time.Time `json:"checked_at"`
+
+	// BelowSince is the first check of the current run below the target.
+	BelowSince *time.Time `json:"below_since,omitempty"`
+	// Breached is set once the ring has been below the target for Window.
+	Breached bool `json:"breached"`
+
+	// Compliance is the percentage of checks that met the target over the
+	// last day and week, or nil without an objective or history.
+	Compliance24h *float64 `json:"compliance_24h,omitempty"`
+	Compliance7d  *float64 `json:"compliance_7d,omitempty"`
+}
+
+// Enabled reports whether an objective is configured.
+func (s *Status) Enabled() bool {
+	return s.Target > 0
+}
+
+// ParseTarget reads the slo_target setting, a percentage such as "95" or
+// "99.5%". An empty value disables the objective.
+func ParseTarget(s string) (float64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "%")
+	if s == "" {
+		return 0, nil
+	}
+	target, err := strconv.ParseFloat(s, 64)
+	if err != nil || target <= 0 || target > 100 {
+		return 0, fmt.Errorf("invalid SLO target %q, expected a percentage between 0 and 100", s)
+	}
+	return target, nil
+}
+
+// ValidateTarget is used by the settings page.
+func ValidateTarget(s string) error {
+	_, err := ParseTarget(s)
+	return err
+}
+
+// Record stores the result of a check round.
+func Record(db *sql.DB, members, up int) error {
+	_, err := db.Exec("INSERT INTO ring_health (members, up) VALUES ($1, $2)", members, up)
+	return err
+}
+
+// Prune removes rounds older than before.
+func Prune(db *sql.DB, before time.Time) error {
+	_, err := db.Exec("DELETE FROM ring_health WHERE checked_at < $1", before)
+	return err
+}
+
+// Current returns the latest round measured against the configured
+// objective. It returns nil if no round has been recorded yet.
+func Current(db *sql.DB, st *settings.Store) (*Status, error) {
+	var s Status
+	err := db.QueryRow("SELECT members, up, checked_at FROM ring_health ORDER BY checked_at DESC LIMIT 1").
+		Scan(&s.Members, &s.Up, &s.CheckedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.Percent = 100
+	if s.Members > 0 {
+		s.Percent = round(float64(s.Up) * 100 / float64(s.Members))
+	}
+
+	// A malformed target is rejected by the settings page; treat it as unset
+	s.Target, _ = ParseTarget(st.Get(settings.SLOTarget))
+	if !s.Enabled() {
+		return &s, nil
+	}
+	s.Window = st.Duration(settings.SLOWindow, DefaultWindow)
+
+	err = db.QueryRow(`
+		SELECT MIN(checked_at) FROM ring_health
+		WHERE checked_at > COALESCE((SELECT MAX(checked_at) FROM ring_health WHERE up * 100.0 >= $1::float8 * members), '-infinity')`,
+		s.Target).Scan(&s.BelowSince)
+	if err != nil {
+		return nil, err
+	}
+	s.Breached = s.BelowSince != nil && s.CheckedAt.Sub(*s.BelowSince) >= s.Window
+
+	if s.Compliance24h, err = compliance(db, s.Target, 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if s.Compliance7d, err = compliance(db, s.Target, 7*24*time.Hour); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// compliance returns the percentage of rounds within the period that met the
+// target.
+func compliance(db *sql.DB, target float64, period time.Duration) (*float64, error) {
+	var pct *float64
+	err := db.QueryRow(`
+		SELECT AVG(CASE WHEN up * 100.0 >= $1::float8 * members THEN 100.0 ELSE 0 END)::float8
+		FROM ring_health WHERE checked_at > $2`, target, time.Now().Add(-period)).Scan(&pct)
+	if pct != nil {
+		*pct = round(*pct)
+	}
+	return pct, err
+}
+
+// round keeps one decimal, which is all the pages show.
+func round(pct float64) float64 {
+	return math.Round(pct*10) / 10
+}
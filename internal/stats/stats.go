@@ -0,0 +1,194 @@
+package stats
+
+import (
+	"database/sql"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"webring/internal/models"
+	"webring/internal/settings"
+)
+
+// cacheTTL keeps /stats cheap enough to be polled by badges and dashboards.
+const cacheTTL = 5 * time.Minute
+
+// uptimeWindow is the period the average uptime is computed over.
+const uptimeWindow = 30 * 24 * time.Hour
+
+// Field names, as used in the JSON response and the public_stats_fields setting.
+const (
+	FieldMembers       = "members"
+	FieldMembersUp     = "members_up"
+	FieldUptimeAverage = "uptime_average"
+	FieldNewestMember  = "newest_member"
+	FieldFounded       = "founded"
+	FieldAgeDays       = "age_days"
+)
+
+var Fields = []string{FieldMembers, FieldMembersUp, FieldUptimeAverage, FieldNewestMember, FieldFounded, FieldAgeDays}
+
+type Stats struct {
+	Members   int
+	MembersUp int
+	// UptimeAverage is the percentage of successful checks over the last 30
+	// days, or nil if there is no history yet.
+	UptimeAverage *float64
+	NewestMember  *models.PublicSite
+	Founded       *time.Time
+}
+
+// Cache computes the ring statistics at most once every five minutes.
+type Cache struct {
+	db *sql.DB
+	st *settings.Store
+
+	mu       sync.Mutex
+	stats    Stats
+	loadedAt time.Time
+}
+
+func NewCache(db *sql.DB, st *settings.Store) *Cache {
+	return &Cache{db: db, st: st}
+}
+
+func (c *Cache) Get() (Stats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.loadedAt.IsZero() && time.Since(c.loadedAt) < cacheTTL {
+		return c.stats, nil
+	}
+
+	s, err := c.load()
+	if err != nil {
+		return Stats{}, err
+	}
+	c.stats = s
+	c.loadedAt = time.Now()
+	return s, nil
+}
+
+// Public returns the statistics as a JSON-ready map restricted to the fields
+// enabled in the public_stats_fields setting (all of them when it is empty).
+func (c *Cache) Public() (map[string]interface{}, error) {
+	s, err := c.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	all := map[string]interface{}{
+		FieldMembers:       s.Members,
+		FieldMembersUp:     s.MembersUp,
+		FieldUptimeAverage: s.UptimeAverage,
+		FieldNewestMember:  s.NewestMember,
+		FieldFounded:       nil,
+		FieldAgeDays:       nil,
+	}
+	if s.Founded != nil {
+		all[FieldFounded] = s.Founded.Format("2006-01-02")
+		all[FieldAgeDays] = int(time.Since(*s.Founded).Hours() / 24)
+	}
+
+	enabled := c.enabledFields()
+	result := make(map[string]interface{}, len(enabled))
+	for _, field := range enabled {
+		if v, ok := all[field]; ok {
+			result[field] = v
+		}
+	}
+	return result, nil
+}
+
+func (c *Cache) enabledFields() []string {
+	var fields []string
+	for _, field := range strings.Split(c.st.Get(settings.PublicStatsFields), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return Fields
+	}
+	return fields
+}
+
+func (c *Cache) load() (Stats, error) {
+	var s Stats
+	err := c.db.QueryRow("SELECT COUNT(*), COUNT(*) FILTER (WHERE is_up) FROM sites").Scan(&s.Members, &s.MembersUp)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	s.UptimeAverage, err = uptime(c.db, 0)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	var newest models.PublicSite
+	err = c.db.QueryRow("SELECT id, name, url, favicon FROM sites WHERE is_up = true ORDER BY created_at DESC NULLS LAST, id DESC LIMIT 1").
+		Scan(&newest.ID, &newest.Name, &newest.URL, &newest.Favicon)
+	if err != nil && err != sql.ErrNoRows {
+		return Stats{}, err
+	}
+	if err == nil {
+		s.NewestMember = &newest
+	}
+
+	s.Founded, err = c.founded()
+	if err != nil {
+		return Stats{}, err
+	}
+	return s, nil
+}
+
+// founded returns the ring_founded setting, falling back to the join date of
+// the oldest member with a known one.
+func (c *Cache) founded() (*time.Time, error) {
+	if t, err := time.Parse("2006-01-02", c.st.Get(settings.RingFounded)); err == nil {
+		return &t, nil
+	}
+
+	var t sql.NullTime
+	if err := c.db.QueryRow("SELECT MIN(created_at) FROM sites").Scan(&t); err != nil {
+		return nil, err
+	}
+	if !t.Valid {
+		return nil, nil
+	}
+	return &t.Time, nil
+}
+
+// SiteUptime returns the uptime percentage of one site over the last 30 days,
+// or nil if it has no history yet.
+func SiteUptime(db *sql.DB, siteID int) (*float64, error) {
+	return uptime(db, siteID)
+}
+
+// uptime computes the percentage of successful checks over uptimeWindow for
+// one site, or for all sites when siteID is 0.
+func uptime(db *sql.DB, siteID int) (*float64, error) {
+	// Raw, hourly and daily history cover disjoint periods, so they can be
+	// summed without double counting.
+	since := time.Now().Add(-uptimeWindow)
+	var up, total sql.NullInt64
+	err := db.QueryRow(`
+        SELECT SUM(up), SUM(total) FROM (
+            SELECT COUNT(*) FILTER (WHERE is_up) AS up, COUNT(*) AS total FROM uptime_checks WHERE checked_at >= $1 AND ($2 = 0 OR site_id = $2)
+            UNION ALL
+            SELECT SUM(up_checks), SUM(checks) FROM uptime_hourly WHERE hour >= $1 AND ($2 = 0 OR site_id = $2)
+            UNION ALL
+            SELECT SUM(up_checks), SUM(checks) FROM uptime_daily WHERE day >= $1::date AND ($2 = 0 OR site_id = $2)
+        ) history
+    `, since, siteID).Scan(&up, &total)
+	if err != nil {
+		return nil, err
+	}
+	if !total.Valid || total.Int64 == 0 {
+		return nil, nil
+	}
+	avg := float64(up.Int64) / float64(total.Int64) * 100
+	avg = math.Round(avg*100) / 100
+	return &avg, nil
+}
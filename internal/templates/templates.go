@@ -0,0 +1,116 @@
+// Package templates parses the HTML templates of the dashboard and the public
+// pages, and holds the functions every template in the project can use.
+package templates
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"webring"
+)
+
+// FuncMap is available to the page templates and to the SVG templates of
+// the badge package.
+var FuncMap = template.FuncMap{
+	"add":      func(a, b int) int { return a + b },
+	"sub":      func(a, b int) int { return a - b },
+	"ago":      Ago,
+	"host":     Host,
+	"truncate": Truncate,
+	"percent":  Percent,
+	"attr":     Attr,
+}
+
+// Parse parses the dashboard and public page templates, which share one
+// namespace.
+func Parse() (*template.Template, error) {
+	return template.New("").Funcs(FuncMap).ParseFS(webring.Files, "internal/dashboard/templates/*.html", "internal/public/templates/*.html")
+}
+
+// Ago describes how long ago t was, e.g. "just now", "5m ago" or "3d ago".
+// Times more than a month back are shown as a date. It accepts a time.Time
+// or a *time.Time; nil and zero times give "never".
+func Ago(t interface{}) string {
+	var at time.Time
+	switch v := t.(type) {
+	case time.Time:
+		at = v
+	case *time.Time:
+		if v != nil {
+			at = *v
+		}
+	}
+	if at.IsZero() {
+		return "never"
+	}
+
+	d := time.Since(at)
+	switch {
+	case d < 0:
+		return "in the future"
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+	return at.Format("2006-01-02")
+}
+
+// Host returns the host name of a URL, or the URL itself if it has none.
+func Host(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return raw
+	}
+	return u.Hostname()
+}
+
+// Truncate shortens s to at most n characters, ending with an ellipsis when
+// something was cut. The length comes first so it works in pipelines:
+// {{.Name | truncate 28}}.
+func Truncate(n int, s string) string {
+	if n < 1 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+// Percent formats an uptime percentage with one decimal, e.g. "99.5%". It
+// accepts a float64 or a *float64; nil gives "unknown".
+func Percent(v interface{}) string {
+	switch p := v.(type) {
+	case float64:
+		return fmt.Sprintf("%.1f%%", p)
+	case *float64:
+		if p != nil && !math.IsNaN(*p) {
+			return fmt.Sprintf("%.1f%%", *p)
+		}
+	}
+	return "unknown"
+}
+
+// Attr renders a name="value" attribute pair with the value escaped, for
+// attributes whose name is only known at run time. Only data-* and aria-*
+// names made of letters, digits and dashes are accepted, so a value can't
+// end up in an event handler or a URL attribute; anything else renders
+// nothing.
+func Attr(name, value string) template.HTMLAttr {
+	if !strings.HasPrefix(name, "data-") && !strings.HasPrefix(name, "aria-") {
+		return ""
+	}
+	for _, r := range name {
+		if !(r == '-' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9') {
+			return ""
+		}
+	}
+	return template.HTMLAttr(name + `="` + template.HTMLEscapeString(value) + `"`)
+}
@@ -0,0 +1,205 @@
+// Package tour walks visitors through every member of the ring once, in ring
+// order. The progress is kept in a signed cookie: the member shown last and
+// the IDs of the members visited, sorted and stored as varint deltas, so a
+// member takes a byte or two however large the IDs are, as long as they are
+// not far apart. Remembering who was visited rather than a position keeps the
+// promise of visiting each member once when members join, leave or the ring is reordered mid-tour.
+package tour
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"webring/internal/models"
+)
+
+const (
+	cookieName = "webring_tour"
+	// cookieAge is how long an unfinished tour can be resumed.
+	cookieAge = 30 * 24 * time.Hour
+)
+
+// Progress is how far a visitor got.
+type Progress struct {
+	// Current is the member the visitor was sent to last, 0 before the first
+	// and after the last.
+	Current int
+	// visited holds the IDs of the members visited, in increasing order
+	visited []int
+}
+
+// Visited reports whether the member id was visited.
+func (p Progress) Visited(id int) bool {
+	i := sort.SearchInts(p.visited, id)
+	return i < len(p.visited) && p.visited[i] == id
+}
+
+// Visit records that the visitor is sent to the member id, which must be
+// positive.
+func (p *Progress) Visit(id int) {
+	p.Current = id
+	if id <= 0 || p.Visited(id) {
+		return
+	}
+	i := sort.SearchInts(p.visited, id)
+	p.visited = append(p.visited, 0)
+	copy(p.visited[i+1:], p.visited[i:])
+	p.visited[i] = id
+}
+
+// Started reports whether any member was visited.
+func (p Progress) Started() bool {
+	return len(p.visited) > 0
+}
+
+// encodeIDs writes sorted positive IDs as the varint deltas between them.
+func encodeIDs(ids []int) []byte {
+	var buf []byte
+	prev := 0
+	for _, id := range ids {
+		buf = binary.AppendUvarint(buf, uint64(id-prev))
+		prev = id
+	}
+	return buf
+}
+
+// decodeIDs reads IDs written by encodeIDs.
+func decodeIDs(buf []byte) ([]int, bool) {
+	var ids []int
+	prev := 0
+	for len(buf) > 0 {
+		delta, n := binary.Uvarint(buf)
+		if n <= 0 || delta == 0 || delta > math.MaxInt32 {
+			return nil, false
+		}
+		prev += int(delta)
+		ids = append(ids, prev)
+		buf = buf[n:]
+	}
+	return ids, true
+}
+
+// Members returns the members the tour visits: those with a positive ID, as
+// only those can be stored in the cookie.
+func Members(sites []models.PublicSite) []models.PublicSite {
+	members := make([]models.PublicSite, 0, len(sites))
+	for _, s := range sites {
+		if s.ID > 0 {
+			members = append(members, s)
+		}
+	}
+	return members
+}
+
+// Count returns how many of sites were visited.
+func (p Progress) Count(sites []models.PublicSite) int {
+	n := 0
+	for _, s := range sites {
+		if p.Visited(s.ID) {
+			n++
+		}
+	}
+	return n
+}
+
+// Next returns the index in sites, as returned by Members, of the member to
+// visit next: the first one after the current member in ring order that was
+// not visited yet. It returns false once every member was visited.
+func (p Progress) Next(sites []models.PublicSite) (int, bool) {
+	start := 0
+	for i, s := range sites {
+		if s.ID == p.Current {
+			start = i + 1
+			break
+		}
+	}
+	for k := range sites {
+		i := (start + k) % len(sites)
+		if !p.Visited(sites[i].ID) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Cookies stores progress in signed cookies.
+type Cookies struct {
+	secret []byte
+}
+
+// New reads TOUR_SECRET. Without a secret a random one is generated, which
+// restarts the tours in progress on restart.
+func New() *Cookies {
+	secret := []byte(os.Getenv("TOUR_SECRET"))
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("Failed to generate tour secret: %v", err)
+		}
+	}
+	return &Cookies{secret: secret}
+}
+
+// Get returns the progress stored in the request, false if there is none or
+// its signature doesn't match.
+func (c *Cookies) Get(r *http.Request) (Progress, bool) {
+	cookie, err := r.Cookie(cookieName)
+	if err != nil {
+		return Progress{}, false
+	}
+	i := strings.LastIndexByte(cookie.Value, '.')
+	if i < 0 {
+		return Progress{}, false
+	}
+	payload, sig := cookie.Value[:i], cookie.Value[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(c.sign(payload))) {
+		return Progress{}, false
+	}
+
+	current, visited, ok := strings.Cut(payload, ".")
+	if !ok {
+		return Progress{}, false
+	}
+	var p Progress
+	if p.Current, err = strconv.Atoi(current); err != nil {
+		return Progress{}, false
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(visited)
+	if err != nil {
+		return Progress{}, false
+	}
+	if p.visited, ok = decodeIDs(buf); !ok {
+		return Progress{}, false
+	}
+	return p, true
+}
+
+// Set stores p in the response.
+func (c *Cookies) Set(w http.ResponseWriter, p Progress) {
+	payload := strconv.Itoa(p.Current) + "." + base64.RawURLEncoding.EncodeToString(encodeIDs(p.visited))
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    payload + "." + c.sign(payload),
+		Path:     "/tour",
+		MaxAge:   int(cookieAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (c *Cookies) sign(payload string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
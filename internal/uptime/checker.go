@@ -2,10 +2,9 @@ package uptime
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"net/url"
 	"os"
 	"strconv"
@@ -13,17 +12,47 @@ import (
 	"sync"
 	"time"
 
+	"webring/internal/clock"
+	"webring/internal/database"
+	"webring/internal/jobs"
 	"webring/internal/models"
+	"webring/internal/monitor"
+	"webring/internal/notify"
+	"webring/internal/outbox"
+	"webring/internal/ring"
+	"webring/internal/settings"
+	"webring/internal/slo"
 )
 
+// JobCheck is the periodic job running a check round.
+const JobCheck = "uptime_check"
+
 type Checker struct {
-	db         *sql.DB
+	db *sql.DB
+	// reads lists the sites to check, from a replica if one is configured
+	reads      *sql.DB
+	ring       *ring.Cache
+	settings   *settings.Store
 	proxy      *url.URL
 	proxyAlive bool
-	debug      bool
+
+	// overlayProxy is the gateway (e.g. a Tor SOCKS proxy) used for sites
+	// whose strategy is "proxy".
+	overlayProxy *url.URL
+	debug        bool
+	// disabled turns the checker off for staging and load tests, so site
+	// statuses stay as they are and member sites are not contacted.
+	disabled bool
+	probers  map[string]Prober
+
+	// Clock dates the check results. Tests replace it before the first
+	// round.
+	Clock clock.Clock
 }
 
-func NewChecker(db *sql.DB) *Checker {
+// NewChecker creates the uptime checker. Notifications about status changes
+// go through the outbox.
+func NewChecker(db, reads *sql.DB, rc *ring.Cache, st *settings.Store) *Checker {
 	var proxyURL *url.URL
 	if proxyStr := os.Getenv("CHECKER_PROXY"); proxyStr != "" {
 		var err error
@@ -35,14 +64,38 @@ func NewChecker(db *sql.DB) *Checker {
 		}
 	}
 
+	var overlayProxyURL *url.URL
+	if proxyStr := os.Getenv("CHECKER_OVERLAY_PROXY"); proxyStr != "" {
+		var err error
+		overlayProxyURL, err = url.Parse(proxyStr)
+		if err != nil {
+			log.Printf("Warning: Invalid overlay proxy URL provided (%s): %v. Overlay sites will not be checked.", proxyStr, err)
+		}
+	}
+
 	debug, _ := strconv.ParseBool(os.Getenv("CHECKER_DEBUG"))
+	disabled, _ := strconv.ParseBool(os.Getenv("CHECKER_DISABLED"))
 
-	return &Checker{
+	c := &Checker{
 		db:         db,
+		reads:      reads,
+		ring:       rc,
+		settings:   st,
 		proxy:      proxyURL,
 		proxyAlive: true,
 		debug:      debug,
+		disabled:   disabled,
+
+		overlayProxy: overlayProxyURL,
+		Clock:        clock.Real,
+	}
+	c.probers = map[string]Prober{
+		CheckHTTP:    httpProber{c},
+		CheckTCP:     tcpProber{c},
+		CheckPing:    pingProber{c},
+		CheckKeyword: keywordProber{c},
 	}
+	return c
 }
 
 func (c *Checker) debugLog(format string, args ...interface{}) {
@@ -51,19 +104,34 @@ func (c *Checker) debugLog(format string, args ...interface{}) {
 	}
 }
 
-func (c *Checker) Start() {
+// Register schedules the check rounds on w, so only one instance checks
+// the sites at a time.
+func (c *Checker) Register(w *jobs.Worker) {
+	if c.disabled {
+		log.Println("Checker disabled by CHECKER_DISABLED, site statuses will not change")
+		return
+	}
 	fmt.Println("Starting checker...")
 	if c.debug {
 		log.Printf("[DEBUG] Checker started with proxy: %v, debug mode: true", c.proxy != nil)
 	}
 	// Default to checking every 5 minutes. If CHECKER_DEBUG == true, we check every 5 seconds for quicker testing.
-	ticker := time.NewTicker(5 * time.Minute)
+	interval := 5 * time.Minute
 	if c.debug {
-		ticker = time.NewTicker(5 * time.Second)
-	}
-	for range ticker.C {
-		c.checkAllSites()
+		interval = 5 * time.Second
 	}
+	w.Every(JobCheck, interval, c.checkAllSites)
+}
+
+type checkResult struct {
+	site         models.Site
+	isUp         bool
+	responseTime float64
+	errorMsg     string
+
+	// httpsIssue is only meaningful when httpsChecked is set
+	httpsIssue   string
+	httpsChecked bool
 }
 
 func (c *Checker) checkAllSites() {
@@ -73,179 +141,401 @@ func (c *Checker) checkAllSites() {
 		return
 	}
 
+	// Sites whose scheme can't be checked keep their current status
+	checkable := sites[:0]
+	for _, site := range sites {
+		if c.strategyFor(site) == StrategySkip {
+			c.debugLog("Skipping check of %s due to its scheme policy", site.URL)
+			continue
+		}
+		checkable = append(checkable, site)
+	}
+	sites = checkable
+
 	c.debugLog("Starting check of %d sites", len(sites))
 
+	if c.proxy == nil {
+		c.debugLog("No proxy configured, checking sites directly")
+		c.applyResults(c.checkSites(sites, false))
+		return
+	}
+
 	// If a proxy is configured, first attempt checks using the proxy
-	if c.proxy != nil {
-		proxySuccess := false
-		allProxyErrors := true
-
-		var wg sync.WaitGroup
-		var mutex sync.Mutex
-
-		for _, site := range sites {
-			wg.Add(1)
-			go func(s models.Site) {
-				defer wg.Done()
-
-				c.debugLog("Checking site %s (ID: %d) via proxy", s.URL, s.ID)
-				isUp, responseTime, errorMsg := c.doCheckSite(s, true)
-
-				mutex.Lock()
-				if isUp {
-					c.debugLog("Site %s is up (proxy), response time: %.2fs", s.URL, responseTime)
-					proxySuccess = true
-					allProxyErrors = false
-				} else {
-					c.debugLog("Site %s is down (proxy): %s", s.URL, errorMsg)
-					// If the error does NOT look like a proxy problem, mark that not all errors are proxy-only
-					if !strings.Contains(errorMsg, "cannot connect to proxy") &&
-						!strings.Contains(errorMsg, "proxy refused connection") &&
-						!strings.Contains(errorMsg, "no route to host") {
-						c.debugLog("Error for %s appears to be site-specific, not proxy-related", s.URL)
-						allProxyErrors = false
-					}
-				}
-				mutex.Unlock()
-
-				c.updateSiteStatus(s.ID, isUp, responseTime)
-				if !isUp {
-					c.logError(s.URL, errorMsg)
-				}
-			}(site)
+	results := c.checkSites(sites, true)
+
+	proxySuccess := false
+	allProxyErrors := true
+	for _, res := range results {
+		if res.isUp {
+			proxySuccess = true
+			allProxyErrors = false
+			continue
 		}
-		wg.Wait()
-
-		// If *every* site failed due to what looks like a proxy error, assume proxy is down
-		c.proxyAlive = proxySuccess || !allProxyErrors
-		if !c.proxyAlive {
-			log.Printf("Proxy appears to be down, retrying with direct connections")
-			c.debugLog("All sites failed with proxy errors, switching to direct connections")
-
-			var wg2 sync.WaitGroup
-			for _, site := range sites {
-				wg2.Add(1)
-				go func(s models.Site) {
-					defer wg2.Done()
-
-					c.debugLog("Retrying site %s (ID: %d) without proxy", s.URL, s.ID)
-					isUp, responseTime, errorMsg := c.doCheckSite(s, false)
-
-					if isUp {
-						c.debugLog("Site %s is up (direct), response time: %.2fs", s.URL, responseTime)
-					} else {
-						c.debugLog("Site %s is down (direct): %s", s.URL, errorMsg)
-					}
-
-					c.updateSiteStatus(s.ID, isUp, responseTime)
-					if !isUp {
-						c.logError(s.URL, errorMsg)
-					}
-				}(site)
-			}
-			wg2.Wait()
-
-		} else {
-			c.debugLog("Proxy is working correctly, no need for direct connection retries")
+		// If the error does NOT look like a proxy problem, mark that not all errors are proxy-only
+		if !strings.Contains(res.errorMsg, "cannot connect to proxy") &&
+			!strings.Contains(res.errorMsg, "proxy refused connection") &&
+			!strings.Contains(res.errorMsg, "no route to host") {
+			c.debugLog("Error for %s appears to be site-specific, not proxy-related", res.site.URL)
+			allProxyErrors = false
 		}
+	}
+
+	// If *every* site failed due to what looks like a proxy error, assume proxy is down.
+	// Results are only stored once we know which ones to trust, so a dead proxy
+	// does not cause every site to flap down and back up.
+	c.proxyAlive = proxySuccess || !allProxyErrors
+	if !c.proxyAlive {
+		log.Printf("Proxy appears to be down, retrying with direct connections")
+		c.debugLog("All sites failed with proxy errors, switching to direct connections")
+		results = c.checkSites(sites, false)
 	} else {
-		c.debugLog("No proxy configured, checking sites directly")
-		var wg sync.WaitGroup
-		for _, site := range sites {
-			wg.Add(1)
-			go func(s models.Site) {
-				defer wg.Done()
-
-				c.debugLog("Checking site %s (ID: %d) directly", s.URL, s.ID)
-				isUp, responseTime, errorMsg := c.doCheckSite(s, false)
-
-				if isUp {
-					c.debugLog("Site %s is up, response time: %.2fs", s.URL, responseTime)
-				} else {
-					c.debugLog("Site %s is down: %s", s.URL, errorMsg)
-				}
-
-				c.updateSiteStatus(s.ID, isUp, responseTime)
-				if !isUp {
-					c.logError(s.URL, errorMsg)
-				}
-			}(site)
-		}
-		wg.Wait()
+		c.debugLog("Proxy is working correctly, no need for direct connection retries")
 	}
+
+	c.applyResults(results)
 }
 
-// doCheckSite attempts a HEAD request to the site.
-// `useProxy == true` uses the configured proxy (if any), else direct request.
-func (c *Checker) doCheckSite(site models.Site, useProxy bool) (bool, float64, string) {
-	transport := &http.Transport{
-		TLSHandshakeTimeout: 10 * time.Second,
-		DisableKeepAlives:   false,
-		MaxIdleConns:        100,
-		IdleConnTimeout:     90 * time.Second,
+// checkSites checks all sites concurrently and returns the results in the
+// same order as sites.
+func (c *Checker) checkSites(sites []models.Site, useProxy bool) []checkResult {
+	results := make([]checkResult, len(sites))
+	requireHTTPS := c.settings.Bool(settings.RequireHTTPS)
+
+	var wg sync.WaitGroup
+	for i, site := range sites {
+		wg.Add(1)
+		go func(i int, s models.Site) {
+			defer wg.Done()
+
+			c.debugLog("Checking site %s (ID: %d, proxy: %v)", s.URL, s.ID, useProxy)
+			isUp, responseTime, errorMsg := c.doCheckSite(s, useProxy)
+
+			if isUp {
+				c.debugLog("Site %s is up (proxy: %v), response time: %.2fs", s.URL, useProxy, responseTime)
+			} else {
+				c.debugLog("Site %s is down (proxy: %v): %s", s.URL, useProxy, errorMsg)
+			}
+
+			results[i] = checkResult{site: s, isUp: isUp, responseTime: responseTime, errorMsg: errorMsg}
+			if requireHTTPS {
+				results[i].httpsIssue, results[i].httpsChecked = c.httpsIssue(s, useProxy)
+			}
+		}(i, site)
 	}
+	wg.Wait()
 
-	if useProxy && c.proxy != nil {
-		transport.Proxy = http.ProxyURL(c.proxy)
+	return results
+}
+
+func (c *Checker) applyResults(results []checkResult) {
+	if c.settings.Bool(settings.CheckerDryRun) {
+		c.stageResults(results)
+		return
 	}
 
-	client := &http.Client{
-		Timeout:   10 * time.Second,
-		Transport: transport,
+	requireHTTPS := c.settings.Bool(settings.RequireHTTPS)
+	if !requireHTTPS {
+		c.clearHTTPSIssues()
 	}
 
-	siteUrl := site.URL
-	if !hasProtocol(siteUrl) {
-		siteUrl = "https://" + siteUrl
+	for _, res := range results {
+		c.applyResult(res, requireHTTPS)
 	}
+	c.ring.Invalidate()
+	c.trackSLO(results)
+}
 
-	c.debugLog("Making request to %s (proxy: %v)", siteUrl, useProxy)
-	start := time.Now()
-	resp, err := client.Head(siteUrl)
-	elapsed := time.Since(start).Seconds()
+func (c *Checker) applyResult(res checkResult, requireHTTPS bool) {
+	c.updateSiteStatus(res)
+	if requireHTTPS {
+		c.updateHTTPSStatus(res)
+	}
+	c.recordCheck(res)
+	if !res.isUp {
+		c.logError(res.site, res.errorMsg)
+	}
+}
 
+// CheckSite checks one site right away, e.g. when it returns from hiatus,
+// without waiting for the next round. It doesn't count towards the uptime
+// objective.
+func (c *Checker) CheckSite(id int) error {
+	if c.disabled {
+		return nil
+	}
+	sites, err := c.getSites(c.db, "WHERE id = $2", id)
 	if err != nil {
-		errorMsg := fmt.Sprintf("Error checking site: %v", err)
-		c.debugLog("Request failed for %s: %v (took %.2fs)", siteUrl, err, elapsed)
-		return false, elapsed, errorMsg
+		return err
+	}
+	if len(sites) == 0 || c.strategyFor(sites[0]) == StrategySkip {
+		return nil
+	}
+
+	results := c.checkSites(sites, c.proxy != nil && c.proxyAlive)
+	if c.settings.Bool(settings.CheckerDryRun) {
+		c.stageResults(results)
+		return nil
 	}
-	defer func(Body io.ReadCloser) {
-		if cerr := Body.Close(); cerr != nil {
-			c.debugLog("Error closing response body for %s: %v", siteUrl, cerr)
+	c.applyResult(results[0], c.settings.Bool(settings.RequireHTTPS))
+	c.ring.Invalidate()
+	return nil
+}
+
+// trackSLO records how many members were up in this round and alerts admins
+// when the ring has been below its uptime objective for the alert window.
+// Sites in a maintenance window don't count either way.
+func (c *Checker) trackSLO(results []checkResult) {
+	members, up := 0, 0
+	for _, res := range results {
+		if res.site.InMaintenance {
+			continue
+		}
+		members++
+		if res.isUp {
+			up++
 		}
-	}(resp.Body)
+	}
+	// Compare with the status before this round rather than keeping it in
+	// memory, so restarts and other instances don't alert twice.
+	before, err := slo.Current(c.db, c.settings)
+	if err != nil {
+		log.Printf("Error computing the uptime objective: %v", err)
+		return
+	}
+	if err := slo.Record(c.db, members, up); err != nil {
+		log.Printf("Error recording ring health: %v", err)
+		return
+	}
 
-	c.debugLog("Request to %s completed with status %d (took %.2fs)", siteUrl, resp.StatusCode, elapsed)
-	// Treat any 5xx as "down," 4xx is considered "up" from the server's standpoint
-	return resp.StatusCode < 500, elapsed, ""
+	status, err := slo.Current(c.db, c.settings)
+	if err != nil {
+		log.Printf("Error computing the uptime objective: %v", err)
+		return
+	}
+	wasBreached := before != nil && before.Enabled() && before.Breached
+	if status == nil || !status.Enabled() || status.Breached == wasBreached {
+		return
+	}
+
+	event := notify.Event{
+		Type:    notify.EventSLORecovered,
+		Message: fmt.Sprintf("%.1f%% of members up, objective %g%%", status.Percent, status.Target),
+		Time:    status.CheckedAt,
+	}
+	if status.Breached {
+		event.Type = notify.EventSLOBreach
+		event.Since = *status.BelowSince
+	}
+	if err := outbox.Enqueue(c.db, outbox.EventNotify, event); err != nil {
+		log.Printf("Error queueing uptime objective notification: %v", err)
+	}
 }
 
-func (c *Checker) updateSiteStatus(id int, isUp bool, responseTime float64) {
-	_, err := c.db.Exec("UPDATE sites SET is_up = $1, last_check = $2 WHERE id = $3", isUp, responseTime, id)
+// doCheckSite runs the prober configured for the site.
+// `useProxy == true` uses the configured proxy (if any), else direct request.
+func (c *Checker) doCheckSite(site models.Site, useProxy bool) (bool, float64, string) {
+	prober, ok := c.probers[site.CheckType]
+	if !ok {
+		prober = c.probers[CheckHTTP]
+	}
+	return prober.Probe(site, useProxy)
+}
+
+// updateSiteStatus stores the result of a check and, when the site changed
+// state, queues a notification event in the same transaction. The site list
+// may come from a replica that lags behind, so the state the transition is
+// decided on is read again from the primary, locked: a change is announced
+// once, by the round that made it, to the notification rules and to the
+// site's monitor alike.
+func (c *Checker) updateSiteStatus(res checkResult) {
+	site := res.site
+	now := c.Clock.Now()
+
+	var delivery *monitor.Delivery
+	changed := false
+	err := database.InTx(c.db, func(tx *sql.Tx) error {
+		err := tx.QueryRow("SELECT is_up, down_since FROM sites WHERE id = $1 FOR UPDATE", site.ID).Scan(&site.IsUp, &site.DownSince)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Removed since the round started
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		changed = site.IsUp != res.isUp
+		delivery = monitorDelivery(site, res, now)
+
+		query, args, event := statusChange(site, res, now)
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+		// State changes reach the site's monitor even during maintenance,
+		// and are retried through the outbox like notifications
+		if delivery != nil && changed {
+			if err := outbox.Enqueue(tx, outbox.EventMonitor, delivery); err != nil {
+				return err
+			}
+		}
+		if event == nil {
+			return nil
+		}
+		return outbox.Enqueue(tx, outbox.EventNotify, event)
+	})
 	if err != nil {
 		log.Printf("Error updating site status: %v", err)
+		return
+	}
+
+	if delivery != nil && !changed {
+		// Heartbeats are superseded by the next check, so they are not retried
+		go func() {
+			if err := monitor.Send(*delivery); err != nil {
+				log.Printf("Error sending heartbeat for %s to its monitor: %v", site.URL, err)
+			}
+		}()
 	}
 }
 
-func (c *Checker) logError(siteURL, errorMsg string) {
-	f, err := os.OpenFile("checker_error.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// statusChange returns the update storing a check result for site, whose
+// IsUp and DownSince are the stored state, and the event to send when the
+// site changed state, if any.
+func statusChange(site models.Site, res checkResult, now time.Time) (string, []interface{}, *notify.Event) {
+	query := "UPDATE sites SET is_up = $1, last_check = $2 WHERE id = $3"
+	args := []interface{}{res.isUp, res.responseTime, site.ID}
+	switch {
+	case site.IsUp && !res.isUp:
+		query = "UPDATE sites SET is_up = $1, last_check = $2, down_since = $4 WHERE id = $3"
+		args = append(args, now)
+		if site.InMaintenance {
+			log.Printf("%s went down during its maintenance window, not notifying", site.URL)
+			return query, args, nil
+		}
+		return query, args, &notify.Event{
+			Type:     notify.EventSiteDown,
+			SiteID:   site.ID,
+			SiteName: site.Name,
+			SiteURL:  site.URL,
+			Message:  res.errorMsg,
+			Time:     now,
+			Since:    now,
+		}
+	case !site.IsUp && res.isUp:
+		query = "UPDATE sites SET is_up = $1, last_check = $2, down_since = NULL WHERE id = $3"
+		if downDuringMaintenance(site) {
+			log.Printf("%s is back up after maintenance, not notifying", site.URL)
+			return query, args, nil
+		}
+		event := &notify.Event{
+			Type:     notify.EventSiteUp,
+			SiteID:   site.ID,
+			SiteName: site.Name,
+			SiteURL:  site.URL,
+			Time:     now,
+		}
+		if site.DownSince != nil {
+			event.Since = *site.DownSince
+		}
+		return query, args, event
+	}
+	return query, args, nil
+}
+
+// monitorDelivery returns the result of a check as sent to the site's
+// monitor, or nil if it has none. site holds the stored state the result
+// is compared with.
+func monitorDelivery(site models.Site, res checkResult, now time.Time) *monitor.Delivery {
+	if site.MonitorURL == nil || *site.MonitorURL == "" {
+		return nil
+	}
+
+	eventType := monitor.EventHeartbeat
+	switch {
+	case site.IsUp && !res.isUp:
+		eventType = monitor.EventSiteDown
+	case !site.IsUp && res.isUp:
+		eventType = monitor.EventSiteUp
+	}
+	return &monitor.Delivery{
+		Kind: site.MonitorKind,
+		URL:  *site.MonitorURL,
+		Event: monitor.Event{
+			Type:         eventType,
+			SiteID:       site.ID,
+			SiteName:     site.Name,
+			SiteURL:      site.URL,
+			IsUp:         res.isUp,
+			Error:        res.errorMsg,
+			Time:         now,
+			ResponseTime: res.responseTime,
+			Maintenance:  site.InMaintenance,
+		},
+	}
+}
+
+// downDuringMaintenance reports whether the site went down within its
+// maintenance window, so that coming back up is not announced either.
+func downDuringMaintenance(site models.Site) bool {
+	if site.DownSince == nil || site.MaintenanceStart == nil || site.MaintenanceEnd == nil {
+		return false
+	}
+	return !site.DownSince.Before(*site.MaintenanceStart) && site.DownSince.Before(*site.MaintenanceEnd)
+}
+
+// recordCheck appends the result to the uptime history.
+func (c *Checker) recordCheck(res checkResult) {
+	_, err := c.db.Exec("INSERT INTO uptime_checks (site_id, is_up, response_time) VALUES ($1, $2, $3)", res.site.ID, res.isUp, res.responseTime)
 	if err != nil {
-		log.Printf("Error opening log file: %v", err)
-		return
+		log.Printf("Error recording uptime check: %v", err)
 	}
-	defer func(f *os.File) {
-		if cerr := f.Close(); cerr != nil {
-			log.Printf("Error closing log file: %v", cerr)
+}
+
+// stageResults records results in uptime_checks_staging only, so probes can
+// be tried out without touching site status, history or notifications.
+func (c *Checker) stageResults(results []checkResult) {
+	changes := 0
+	for _, res := range results {
+		statusChange := res.site.IsUp != res.isUp
+		if statusChange {
+			changes++
+			status := "down"
+			if res.isUp {
+				status = "up"
+			}
+			log.Printf("[dry run] %s would go %s %s", res.site.URL, status, res.errorMsg)
 		}
-	}(f)
+		_, err := c.db.Exec("INSERT INTO uptime_checks_staging (site_id, is_up, response_time, error, status_change) VALUES ($1, $2, $3, $4, $5)",
+			res.site.ID, res.isUp, res.responseTime, res.errorMsg, statusChange)
+		if err != nil {
+			log.Printf("Error recording staged uptime check: %v", err)
+		}
+	}
+	log.Printf("[dry run] Checked %d sites, %d would change status", len(results), changes)
+}
 
-	if _, werr := f.WriteString(fmt.Sprintf("%s failed to respond: %s\n", siteURL, errorMsg)); werr != nil {
-		log.Printf("Error writing to log file: %v", werr)
+// logError stores a failed check in check_errors for the dashboard's
+// errors page.
+func (c *Checker) logError(site models.Site, errorMsg string) {
+	_, err := c.db.Exec("INSERT INTO check_errors (site_id, category, message) VALUES ($1, $2, $3)", site.ID, categorize(errorMsg), errorMsg)
+	if err != nil {
+		log.Printf("Error recording check error for %s: %v", site.URL, err)
 	}
 }
 
+// getAllSites returns the sites to check, leaving out the ones on hiatus.
 func (c *Checker) getAllSites() ([]models.Site, error) {
-	rows, err := c.db.Query("SELECT id, url FROM sites")
+	return c.getSites(c.reads, "WHERE hiatus_until IS NULL OR hiatus_until <= $1")
+}
+
+// getSites loads the sites matching where, with maintenance windows evaluated
+// as of the checker's clock. The clock is $1, so where numbers its own
+// arguments from $2.
+func (c *Checker) getSites(db *sql.DB, where string, args ...interface{}) ([]models.Site, error) {
+	args = append([]interface{}{c.Clock.Now()}, args...)
+	rows, err := db.Query(`
+		SELECT id, name, url, is_up, down_since, check_type, check_target, https_issue,
+		       maintenance_start, maintenance_end, (maintenance_start <= $1 AND $1 < maintenance_end) IS TRUE,
+		       monitor_url, monitor_kind
+		FROM sites `+where, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -258,14 +548,11 @@ func (c *Checker) getAllSites() ([]models.Site, error) {
 	var sites []models.Site
 	for rows.Next() {
 		var site models.Site
-		if err := rows.Scan(&site.ID, &site.URL); err != nil {
+		if err := rows.Scan(&site.ID, &site.Name, &site.URL, &site.IsUp, &site.DownSince, &site.CheckType, &site.CheckTarget, &site.HTTPSIssue,
+			&site.MaintenanceStart, &site.MaintenanceEnd, &site.InMaintenance, &site.MonitorURL, &site.MonitorKind); err != nil {
 			return nil, err
 		}
 		sites = append(sites, site)
 	}
 	return sites, nil
 }
-
-func hasProtocol(u string) bool {
-	return len(u) > 8 && (strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://"))
-}
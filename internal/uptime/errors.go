@@ -0,0 +1,94 @@
+package uptime
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+)
+
+// Error categories stored with every failed check.
+const (
+	ErrorTimeout    = "timeout"
+	ErrorDNS        = "dns"
+	ErrorTLS        = "tls"
+	ErrorConnection = "connection"
+	ErrorProxy      = "proxy"
+	ErrorHTTPStatus = "http_status"
+	ErrorKeyword    = "keyword"
+	ErrorOther      = "other"
+)
+
+var ErrorCategories = []string{
+	ErrorTimeout, ErrorDNS, ErrorTLS, ErrorConnection, ErrorProxy, ErrorHTTPStatus, ErrorKeyword, ErrorOther,
+}
+
+// maxErrorListSize caps the number of errors shown on the dashboard.
+const maxErrorListSize = 500
+
+// CheckError is a failed check as stored in check_errors.
+type CheckError struct {
+	ID        int
+	SiteID    int
+	SiteName  string
+	SiteURL   string
+	Category  string
+	Message   string
+	CreatedAt time.Time
+}
+
+// categorize maps a prober error message to one of the error categories.
+func categorize(errorMsg string) string {
+	msg := strings.ToLower(errorMsg)
+	switch {
+	case strings.HasPrefix(msg, "server returned status code"):
+		return ErrorHTTPStatus
+	case strings.HasPrefix(msg, "keyword"):
+		return ErrorKeyword
+	case strings.Contains(msg, "proxy"):
+		return ErrorProxy
+	case strings.Contains(msg, "no such host"), strings.Contains(msg, "server misbehaving"):
+		return ErrorDNS
+	case strings.Contains(msg, "timeout"), strings.Contains(msg, "deadline exceeded"):
+		return ErrorTimeout
+	case strings.Contains(msg, "x509"), strings.Contains(msg, "tls"), strings.Contains(msg, "certificate"):
+		return ErrorTLS
+	case strings.Contains(msg, "connection refused"), strings.Contains(msg, "connection reset"),
+		strings.Contains(msg, "no route to host"), strings.Contains(msg, "network is unreachable"), strings.Contains(msg, "eof"):
+		return ErrorConnection
+	default:
+		return ErrorOther
+	}
+}
+
+// ListErrors returns the most recent check errors, newest first. A zero
+// siteID or an empty category matches everything.
+func ListErrors(db *sql.DB, siteID int, category string) ([]CheckError, error) {
+	rows, err := db.Query(`
+        SELECT e.id, e.site_id, s.name, s.url, e.category, e.message, e.created_at
+        FROM check_errors e
+        JOIN sites s ON s.id = e.site_id
+        WHERE ($1 = 0 OR e.site_id = $1) AND ($2 = '' OR e.category = $2)
+        ORDER BY e.created_at DESC, e.id DESC
+        LIMIT $3
+    `, siteID, category, maxErrorListSize)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	var errs []CheckError
+	for rows.Next() {
+		var e CheckError
+		if err := rows.Scan(&e.ID, &e.SiteID, &e.SiteName, &e.SiteURL, &e.Category, &e.Message, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		errs = append(errs, e)
+	}
+	return errs, rows.Err()
+}
@@ -0,0 +1,62 @@
+package uptime
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// Resolutions of exported history rows, as kept by the retention.
+const (
+	ResolutionCheck = "check"
+	ResolutionHour  = "hour"
+	ResolutionDay   = "day"
+)
+
+// HistoryRow is one row of a site's uptime history: a single check, or an
+// hourly or daily aggregate once the retention has downsampled it.
+type HistoryRow struct {
+	Start      time.Time `json:"start"`
+	Resolution string    `json:"resolution"`
+	Checks     int       `json:"checks"`
+	UpChecks   int       `json:"up_checks"`
+	// ResponseTime is the average response time in seconds.
+	ResponseTime float64 `json:"response_time"`
+}
+
+// ExportHistory calls fn for every history row of a site in [from, to),
+// oldest first, without loading the whole range into memory. It stops at the
+// first error from fn.
+func ExportHistory(db *sql.DB, siteID int, from, to time.Time, fn func(HistoryRow) error) error {
+	rows, err := db.Query(`
+        SELECT checked_at, $4::text, 1, CASE WHEN is_up THEN 1 ELSE 0 END, response_time
+        FROM uptime_checks WHERE site_id = $1 AND checked_at >= $2 AND checked_at < $3
+        UNION ALL
+        SELECT hour, $5::text, checks, up_checks, avg_response_time
+        FROM uptime_hourly WHERE site_id = $1 AND hour >= $2 AND hour < $3
+        UNION ALL
+        SELECT day::timestamp, $6::text, checks, up_checks, avg_response_time
+        FROM uptime_daily WHERE site_id = $1 AND day >= $2::date AND day < $3
+        ORDER BY 1
+    `, siteID, from, to, ResolutionCheck, ResolutionHour, ResolutionDay)
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var row HistoryRow
+		if err := rows.Scan(&row.Start, &row.Resolution, &row.Checks, &row.UpChecks, &row.ResponseTime); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
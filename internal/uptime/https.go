@@ -0,0 +1,106 @@
+package uptime
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"time"
+
+	"webring/internal/database"
+	"webring/internal/models"
+	"webring/internal/notify"
+	"webring/internal/outbox"
+	"webring/internal/settings"
+	"webring/internal/validate"
+)
+
+// httpsIssue returns why the site fails the HTTPS requirement, or "" if it
+// passes. ok is false when the check could not tell, e.g. because the site
+// did not respond at all; the previous state is kept then.
+func (c *Checker) httpsIssue(site models.Site, useProxy bool) (issue string, ok bool) {
+	u, err := url.Parse(validate.WithScheme(site.URL))
+	if err != nil {
+		return "", false
+	}
+	switch validate.Network(u) {
+	case "http":
+		return "URL does not use HTTPS", true
+	case "https":
+	default:
+		// Overlay networks and other schemes are exempt
+		return "", true
+	}
+
+	client := c.httpClient(c.proxyFor(site, useProxy))
+	resp, err := client.Head(u.String())
+	if err != nil {
+		if categorize(err.Error()) == ErrorTLS {
+			return fmt.Sprintf("TLS error: %v", err), true
+		}
+		return "", false
+	}
+	defer func(Body io.ReadCloser) {
+		if cerr := Body.Close(); cerr != nil {
+			c.debugLog("Error closing response body for %s: %v", u, cerr)
+		}
+	}(resp.Body)
+
+	if resp.Request.URL.Scheme == "http" {
+		return fmt.Sprintf("Redirects to %s", resp.Request.URL), true
+	}
+	return "", true
+}
+
+// updateHTTPSStatus stores the HTTPS state of a site and warns the owner when
+// it starts failing the requirement.
+func (c *Checker) updateHTTPSStatus(res checkResult) {
+	if !res.httpsChecked {
+		return
+	}
+	site := res.site
+
+	var err error
+	switch {
+	case res.httpsIssue == "" && site.HTTPSIssue != nil:
+		_, err = c.db.Exec("UPDATE sites SET https_issue = NULL, https_issue_since = NULL WHERE id = $1", site.ID)
+	case res.httpsIssue != "" && site.HTTPSIssue == nil:
+		now := time.Now()
+		deadline := now.Add(c.settings.Duration(settings.HTTPSGracePeriod, settings.DefaultHTTPSGracePeriod))
+		err = database.InTx(c.db, func(tx *sql.Tx) error {
+			// The state read with the site list may lag behind the
+			// primary; only the round that records the issue warns
+			result, err := tx.Exec("UPDATE sites SET https_issue = $1, https_issue_since = $2 WHERE id = $3 AND https_issue IS NULL", res.httpsIssue, now, site.ID)
+			if err != nil {
+				return err
+			}
+			if n, err := result.RowsAffected(); err != nil || n == 0 {
+				return err
+			}
+			return outbox.Enqueue(tx, outbox.EventNotify, notify.Event{
+				Type:     notify.EventHTTPSIssue,
+				SiteID:   site.ID,
+				SiteName: site.Name,
+				SiteURL:  site.URL,
+				Message:  fmt.Sprintf("%s. The site will be left out of the ring after %s unless this is fixed", res.httpsIssue, deadline.Format("2006-01-02 15:04 MST")),
+				Time:     now,
+				Since:    now,
+			})
+		})
+	case res.httpsIssue != "" && *site.HTTPSIssue != res.httpsIssue:
+		_, err = c.db.Exec("UPDATE sites SET https_issue = $1 WHERE id = $2", res.httpsIssue, site.ID)
+	}
+	if err != nil {
+		log.Printf("Error updating HTTPS status of %s: %v", site.URL, err)
+	}
+}
+
+// clearHTTPSIssues forgets all HTTPS issues once the requirement is turned
+// off, so turning it on again starts a fresh grace period.
+func (c *Checker) clearHTTPSIssues() {
+	_, err := c.db.Exec("UPDATE sites SET https_issue = NULL, https_issue_since = NULL WHERE https_issue IS NOT NULL")
+	if err != nil {
+		log.Printf("Error clearing HTTPS issues: %v", err)
+	}
+}
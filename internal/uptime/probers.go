@@ -0,0 +1,250 @@
+package uptime
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"webring/internal/httpclient"
+	"webring/internal/models"
+	"webring/internal/validate"
+)
+
+const (
+	CheckHTTP    = "http"
+	CheckTCP     = "tcp"
+	CheckPing    = "ping"
+	CheckKeyword = "keyword"
+)
+
+var CheckTypes = []string{CheckHTTP, CheckTCP, CheckPing, CheckKeyword}
+
+const (
+	probeTimeout = 10 * time.Second
+	// maxKeywordBody limits how much of a page is searched for the keyword.
+	maxKeywordBody = 1 << 20
+)
+
+// Prober checks whether a site is up. It returns the result, the time the
+// check took in seconds and an error message when the site is down.
+type Prober interface {
+	Probe(site models.Site, useProxy bool) (bool, float64, string)
+}
+
+func (c *Checker) httpClient(proxy *url.URL) *http.Client {
+	return httpclient.New("uptime", httpclient.Options{Timeout: probeTimeout, Proxy: proxy})
+}
+
+func siteURL(site models.Site) string {
+	return validate.WithScheme(site.URL)
+}
+
+// httpProber sends a HEAD request to the site.
+type httpProber struct{ c *Checker }
+
+func (p httpProber) Probe(site models.Site, useProxy bool) (bool, float64, string) {
+	c := p.c
+	client := c.httpClient(c.proxyFor(site, useProxy))
+	siteUrl := siteURL(site)
+
+	c.debugLog("Making request to %s (proxy: %v)", siteUrl, useProxy)
+	start := time.Now()
+	resp, err := client.Head(siteUrl)
+	elapsed := time.Since(start).Seconds()
+
+	if err != nil {
+		errorMsg := fmt.Sprintf("Error checking site: %v", err)
+		c.debugLog("Request failed for %s: %v (took %.2fs)", siteUrl, err, elapsed)
+		return false, elapsed, errorMsg
+	}
+	defer func(Body io.ReadCloser) {
+		if cerr := Body.Close(); cerr != nil {
+			c.debugLog("Error closing response body for %s: %v", siteUrl, cerr)
+		}
+	}(resp.Body)
+
+	c.debugLog("Request to %s completed with status %d (took %.2fs)", siteUrl, resp.StatusCode, elapsed)
+	// Treat any 5xx as "down," 4xx is considered "up" from the server's standpoint
+	return resp.StatusCode < 500, elapsed, ""
+}
+
+// keywordProber fetches the page and requires check_target to appear in the body.
+type keywordProber struct{ c *Checker }
+
+func (p keywordProber) Probe(site models.Site, useProxy bool) (bool, float64, string) {
+	c := p.c
+	client := c.httpClient(c.proxyFor(site, useProxy))
+	siteUrl := siteURL(site)
+
+	start := time.Now()
+	resp, err := client.Get(siteUrl)
+	if err != nil {
+		return false, time.Since(start).Seconds(), fmt.Sprintf("Error checking site: %v", err)
+	}
+	defer func(Body io.ReadCloser) {
+		if cerr := Body.Close(); cerr != nil {
+			c.debugLog("Error closing response body for %s: %v", siteUrl, cerr)
+		}
+	}(resp.Body)
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxKeywordBody))
+	elapsed := time.Since(start).Seconds()
+	if err != nil {
+		return false, elapsed, fmt.Sprintf("Error reading response: %v", err)
+	}
+	if resp.StatusCode >= 500 {
+		return false, elapsed, fmt.Sprintf("Server returned status code %d", resp.StatusCode)
+	}
+	if site.CheckTarget != "" && !bytes.Contains(body, []byte(site.CheckTarget)) {
+		return false, elapsed, fmt.Sprintf("Keyword %q not found on page", site.CheckTarget)
+	}
+	return true, elapsed, ""
+}
+
+// tcpProber connects to host:port, where the port comes from check_target or
+// defaults to the port of the site URL. The proxy is not used.
+type tcpProber struct{ c *Checker }
+
+func (p tcpProber) Probe(site models.Site, _ bool) (bool, float64, string) {
+	address, err := tcpAddress(site)
+	if err != nil {
+		return false, 0, err.Error()
+	}
+
+	p.c.debugLog("Connecting to %s", address)
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", address, probeTimeout)
+	elapsed := time.Since(start).Seconds()
+	if err != nil {
+		return false, elapsed, fmt.Sprintf("Error connecting to %s: %v", address, err)
+	}
+	if cerr := conn.Close(); cerr != nil {
+		p.c.debugLog("Error closing connection to %s: %v", address, cerr)
+	}
+	return true, elapsed, ""
+}
+
+func tcpAddress(site models.Site) (string, error) {
+	u, err := url.Parse(siteURL(site))
+	if err != nil {
+		return "", fmt.Errorf("invalid site URL: %v", err)
+	}
+
+	port := site.CheckTarget
+	if port == "" {
+		port = u.Port()
+	}
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// pingProber sends a single ICMP echo request. Raw ICMP sockets need
+// CAP_NET_RAW (or root); without it every ping check reports the site down
+// with a permission error.
+type pingProber struct{ c *Checker }
+
+func (p pingProber) Probe(site models.Site, _ bool) (bool, float64, string) {
+	u, err := url.Parse(siteURL(site))
+	if err != nil {
+		return false, 0, fmt.Sprintf("invalid site URL: %v", err)
+	}
+
+	start := time.Now()
+	err = ping(u.Hostname())
+	elapsed := time.Since(start).Seconds()
+	if err != nil {
+		return false, elapsed, fmt.Sprintf("Error pinging %s: %v", u.Hostname(), err)
+	}
+	return true, elapsed, ""
+}
+
+func ping(host string) error {
+	addr, err := net.ResolveIPAddr("ip4", host)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	id := uint16(os.Getpid() & 0xffff)
+	seq := uint16(time.Now().UnixNano() & 0xffff)
+	msg := make([]byte, 16)
+	msg[0] = 8 // echo request
+	binary.BigEndian.PutUint16(msg[4:], id)
+	binary.BigEndian.PutUint16(msg[6:], seq)
+	copy(msg[8:], "webring!")
+	binary.BigEndian.PutUint16(msg[2:], icmpChecksum(msg))
+
+	if err := conn.SetDeadline(time.Now().Add(probeTimeout)); err != nil {
+		return err
+	}
+	if _, err := conn.WriteTo(msg, addr); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(reply)
+		if err != nil {
+			return err
+		}
+		if n < 8 || from.String() != addr.String() {
+			continue
+		}
+		// The kernel may hand us the IPv4 header as well
+		data := reply[:n]
+		if data[0]>>4 == 4 {
+			headerLen := int(data[0]&0x0f) * 4
+			if n < headerLen+8 {
+				continue
+			}
+			data = data[headerLen:]
+		}
+		if data[0] == 0 && binary.BigEndian.Uint16(data[4:]) == id && binary.BigEndian.Uint16(data[6:]) == seq {
+			return nil
+		}
+		if data[0] == 3 {
+			return errors.New("destination unreachable")
+		}
+	}
+}
+
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
+
+// ValidCheckType reports whether t is one of CheckTypes.
+func ValidCheckType(t string) bool {
+	for _, checkType := range CheckTypes {
+		if checkType == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package uptime
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"webring/internal/jobs"
+	"webring/internal/slo"
+)
+
+const (
+	defaultRawRetentionDays    = 7
+	defaultHourlyRetentionDays = 90
+)
+
+// Retention keeps the uptime history bounded: raw checks older than
+// UPTIME_RAW_RETENTION_DAYS are folded into hourly aggregates, and hourly
+// aggregates older than UPTIME_HOURLY_RETENTION_DAYS into daily ones.
+type Retention struct {
+	db         *sql.DB
+	rawDays    int
+	hourlyDays int
+}
+
+func NewRetention(db *sql.DB) *Retention {
+	return &Retention{
+		db:         db,
+		rawDays:    envDays("UPTIME_RAW_RETENTION_DAYS", defaultRawRetentionDays),
+		hourlyDays: envDays("UPTIME_HOURLY_RETENTION_DAYS", defaultHourlyRetentionDays),
+	}
+}
+
+func envDays(key string, fallback int) int {
+	days, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || days <= 0 {
+		return fallback
+	}
+	return days
+}
+
+// JobRetention is the periodic job downsampling the history.
+const JobRetention = "uptime_retention"
+
+// Register downsamples the history every interval on one of the instances.
+func (rt *Retention) Register(w *jobs.Worker, interval time.Duration) {
+	w.Every(JobRetention, interval, rt.Run)
+}
+
+func (rt *Retention) Run() {
+	if err := rt.downsampleRaw(); err != nil {
+		log.Printf("Error downsampling raw uptime checks: %v", err)
+	}
+	if err := rt.downsampleHourly(); err != nil {
+		log.Printf("Error downsampling hourly uptime data: %v", err)
+	}
+	// Dry-run results and check errors are kept as long as raw checks
+	cutoff := time.Now().AddDate(0, 0, -rt.rawDays)
+	if _, err := rt.db.Exec("DELETE FROM uptime_checks_staging WHERE checked_at < $1", cutoff); err != nil {
+		log.Printf("Error pruning staged uptime checks: %v", err)
+	}
+	if _, err := rt.db.Exec("DELETE FROM check_errors WHERE created_at < $1", cutoff); err != nil {
+		log.Printf("Error pruning check errors: %v", err)
+	}
+	// Ring health is kept as long as hourly aggregates
+	if err := slo.Prune(rt.db, time.Now().AddDate(0, 0, -rt.hourlyDays)); err != nil {
+		log.Printf("Error pruning ring health: %v", err)
+	}
+}
+
+func (rt *Retention) downsampleRaw() error {
+	// Cut on an hour boundary so no hour is split between raw and aggregated data
+	cutoff := time.Now().AddDate(0, 0, -rt.rawDays).Truncate(time.Hour)
+
+	tx, err := rt.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+        INSERT INTO uptime_hourly (site_id, hour, checks, up_checks, avg_response_time)
+        SELECT site_id, date_trunc('hour', checked_at), COUNT(*), COUNT(*) FILTER (WHERE is_up), AVG(response_time)
+        FROM uptime_checks
+        WHERE checked_at < $1
+        GROUP BY 1, 2
+        ON CONFLICT (site_id, hour) DO UPDATE SET
+            avg_response_time = (uptime_hourly.avg_response_time * uptime_hourly.checks + EXCLUDED.avg_response_time * EXCLUDED.checks)
+                                / (uptime_hourly.checks + EXCLUDED.checks),
+            checks = uptime_hourly.checks + EXCLUDED.checks,
+            up_checks = uptime_hourly.up_checks + EXCLUDED.up_checks
+    `, cutoff)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM uptime_checks WHERE checked_at < $1", cutoff); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (rt *Retention) downsampleHourly() error {
+	now := time.Now()
+	cutoff := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -rt.hourlyDays)
+
+	tx, err := rt.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+        INSERT INTO uptime_daily (site_id, day, checks, up_checks, avg_response_time)
+        SELECT site_id, hour::date, SUM(checks), SUM(up_checks), SUM(avg_response_time * checks) / SUM(checks)
+        FROM uptime_hourly
+        WHERE hour < $1
+        GROUP BY 1, 2
+        ON CONFLICT (site_id, day) DO UPDATE SET
+            avg_response_time = (uptime_daily.avg_response_time * uptime_daily.checks + EXCLUDED.avg_response_time * EXCLUDED.checks)
+                                / (uptime_daily.checks + EXCLUDED.checks),
+            checks = uptime_daily.checks + EXCLUDED.checks,
+            up_checks = uptime_daily.up_checks + EXCLUDED.up_checks
+    `, cutoff)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM uptime_hourly WHERE hour < $1", cutoff); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,74 @@
+package uptime
+
+import (
+	"net/url"
+	"strings"
+
+	"webring/internal/models"
+	"webring/internal/settings"
+	"webring/internal/validate"
+)
+
+// Strategies decide how sites are checked depending on their scheme or
+// overlay network.
+const (
+	StrategyCheck = "check"
+	StrategyProxy = "proxy"
+	StrategySkip  = "skip"
+)
+
+// strategyFor returns the strategy configured for the site's network, falling
+// back to checking http(s) sites directly, routing overlay networks through
+// the overlay proxy when one is configured and skipping everything else.
+func (c *Checker) strategyFor(site models.Site) string {
+	u, err := url.Parse(validate.WithScheme(site.URL))
+	if err != nil {
+		return StrategyCheck
+	}
+	network := validate.Network(u)
+
+	strategy, ok := parseStrategies(c.settings.Get(settings.SchemeCheckStrategy))[network]
+	if !ok {
+		switch network {
+		case "http", "https":
+			strategy = StrategyCheck
+		case validate.NetworkOnion, validate.NetworkI2P:
+			strategy = StrategyProxy
+		default:
+			strategy = StrategySkip
+		}
+	}
+
+	if strategy == StrategyProxy && c.overlayProxy == nil {
+		return StrategySkip
+	}
+	return strategy
+}
+
+// proxyFor returns the proxy to use for the site, if any.
+func (c *Checker) proxyFor(site models.Site, useProxy bool) *url.URL {
+	if c.strategyFor(site) == StrategyProxy {
+		return c.overlayProxy
+	}
+	if useProxy {
+		return c.proxy
+	}
+	return nil
+}
+
+func parseStrategies(s string) map[string]string {
+	strategies := make(map[string]string)
+	for _, pair := range validate.ParseList(s) {
+		network, strategy, found := strings.Cut(pair, "=")
+		if !found {
+			continue
+		}
+		network = strings.TrimSpace(network)
+		strategy = strings.TrimSpace(strategy)
+		switch strategy {
+		case StrategyCheck, StrategyProxy, StrategySkip:
+			strategies[network] = strategy
+		}
+	}
+	return strategies
+}
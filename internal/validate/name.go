@@ -0,0 +1,84 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxNameLength        = 100
+	MaxDescriptionLength = 300
+)
+
+var (
+	ErrNameEmpty          = errors.New("name is required")
+	ErrNameTooLong        = errors.New("name is too long")
+	ErrDescriptionTooLong = errors.New("description is too long")
+)
+
+// SiteName cleans up a site name so that any script can be used safely:
+// invalid UTF-8, control and invisible formatting characters (bidi
+// overrides, zero-width spaces, ...) are removed and whitespace is collapsed.
+// With stripEmoji, pictographs and their modifiers are removed as well.
+func SiteName(name string, stripEmoji bool) (string, error) {
+	name = clean(name, stripEmoji)
+	if name == "" {
+		return "", ErrNameEmpty
+	}
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		return "", ErrNameTooLong
+	}
+	return name, nil
+}
+
+// Description cleans up a site description like a name. It may be empty.
+func Description(description string) (string, error) {
+	description = clean(description, false)
+	if utf8.RuneCountInString(description) > MaxDescriptionLength {
+		return "", ErrDescriptionTooLong
+	}
+	return description, nil
+}
+
+func clean(name string, stripEmoji bool) string {
+	name = strings.ToValidUTF8(name, "")
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		case r == '\u200d':
+			// The zero-width joiner is only meaningful inside emoji sequences
+			if !stripEmoji {
+				b.WriteRune(r)
+			}
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			continue
+		case stripEmoji && isEmoji(r):
+			continue
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // pictographs, emoticons, transport, flags, ...
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // miscellaneous symbols and dingbats
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F: // variation selectors
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // tag sequences used by subdivision flags
+		return true
+	case r == 0x20E3: // combining enclosing keycap
+		return true
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSiteName(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		in         string
+		stripEmoji bool
+		want       string
+		err        error
+	}{
+		{"plain", "My Site", false, "My Site", nil},
+		{"other scripts", "Мой сайт · 私のサイト", false, "Мой сайт · 私のサイト", nil},
+		{"right to left", "موقعي", false, "موقعي", nil},
+		{"whitespace", "  My \t Site\n", false, "My Site", nil},
+		{"control characters", "My\x00\x07 Site\x7f", false, "My Site", nil},
+		{"invalid UTF-8", "My \xff\xfeSite", false, "My Site", nil},
+		{"bidi override", "evil\u202egnp.exe", false, "evilgnp.exe", nil},
+		{"bidi embedding", "\u202bname\u202c", false, "name", nil},
+		{"bidi isolates", "\u2066a\u2067b\u2068c\u2069", false, "abc", nil},
+		{"bidi marks", "a\u200eb\u200fc\u061c", false, "abc", nil},
+		{"zero-width space", "Zero\u200bWidth", false, "ZeroWidth", nil},
+		{"zero-width non-joiner", "a\u200cb", false, "ab", nil},
+		{"word joiner", "a\u2060b", false, "ab", nil},
+		{"byte order mark", "\ufeffSite", false, "Site", nil},
+		{"soft hyphen", "Web\u00adring", false, "Webring", nil},
+		{"zero-width joiner kept in emoji", "Team 👩\u200d💻", false, "Team 👩\u200d💻", nil},
+		{"emoji kept", "Site 🌈", false, "Site 🌈", nil},
+		{"emoji stripped", "🌈 Site ☀\ufe0f", true, "Site", nil},
+		{"emoji sequence stripped", "Team 👩\u200d💻", true, "Team", nil},
+		{"flag stripped", "Site 🇫🇷", true, "Site", nil},
+		{"keycap stripped", "Site 1\ufe0f\u20e3", true, "Site 1", nil},
+		{"empty", "", false, "", ErrNameEmpty},
+		{"only whitespace", " \t\n", false, "", ErrNameEmpty},
+		{"only invisible", "\u200b\u202e\u2066", false, "", ErrNameEmpty},
+		{"only emoji", "🌈🌈", true, "", ErrNameEmpty},
+		{"at the limit", strings.Repeat("a", MaxNameLength), false, strings.Repeat("a", MaxNameLength), nil},
+		{"over the limit", strings.Repeat("a", MaxNameLength+1), false, "", ErrNameTooLong},
+		{"limit in characters", strings.Repeat("я", MaxNameLength), false, strings.Repeat("я", MaxNameLength), nil},
+		{"limit after cleaning", strings.Repeat("a\u200b", MaxNameLength), false, strings.Repeat("a", MaxNameLength), nil},
+		{"limit after collapsing", strings.Repeat("a", MaxNameLength-2) + "      b", false, strings.Repeat("a", MaxNameLength-2) + " b", nil},
+	} {
+		got, err := SiteName(tc.in, tc.stripEmoji)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: SiteName(%q) error = %v, want %v", tc.name, tc.in, err, tc.err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: SiteName(%q) = %q, want %q", tc.name, tc.in, got, tc.want)
+		}
+	}
+}
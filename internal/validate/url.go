@@ -0,0 +1,105 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	NetworkOnion = "onion"
+	NetworkI2P   = "i2p"
+)
+
+var DefaultAllowedSchemes = []string{"https", "http"}
+
+// URLPolicy describes which member URLs the ring accepts.
+type URLPolicy struct {
+	AllowedSchemes []string
+	AllowOverlay   bool
+}
+
+// WithScheme adds https:// to URLs given without a scheme.
+func WithScheme(raw string) string {
+	if strings.Contains(raw, "://") {
+		return raw
+	}
+	return "https://" + raw
+}
+
+// Network classifies a URL for policy decisions: overlay hosts (.onion,
+// .i2p) are reported as such, everything else by its scheme.
+func Network(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case strings.HasSuffix(host, ".onion"):
+		return NetworkOnion
+	case strings.HasSuffix(host, ".i2p"):
+		return NetworkI2P
+	default:
+		return strings.ToLower(u.Scheme)
+	}
+}
+
+// SiteURL validates a member URL against the policy and returns it in
+// normalized form.
+func SiteURL(raw string, policy URLPolicy) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("URL is required")
+	}
+
+	u, err := url.Parse(WithScheme(raw))
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %v", err)
+	}
+	if u.Host == "" {
+		return "", errors.New("URL must include a host")
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	allowed := policy.AllowedSchemes
+	if len(allowed) == 0 {
+		allowed = DefaultAllowedSchemes
+	}
+	if !contains(allowed, scheme) {
+		return "", fmt.Errorf("the %s:// scheme is not accepted (allowed: %s)", scheme, strings.Join(allowed, ", "))
+	}
+
+	if network := Network(u); (network == NetworkOnion || network == NetworkI2P) && !policy.AllowOverlay {
+		return "", fmt.Errorf(".%s addresses are not accepted", network)
+	}
+
+	u.Scheme = scheme
+	return u.String(), nil
+}
+
+// SupportURL validates an optional donation or sponsor link. Unlike member
+// URLs these must always be plain web links.
+func SupportURL(raw string) (string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return "", nil
+	}
+	return SiteURL(raw, URLPolicy{AllowedSchemes: DefaultAllowedSchemes})
+}
+
+// ParseList splits a comma separated setting into lower-cased items.
+func ParseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package visits
+
+import (
+	"math"
+	"math/bits"
+)
+
+// precision is the number of hash bits that pick a register. 2^10 registers
+// of one byte keep a day of a site in 1 KiB with a standard error of about
+// 3%.
+const (
+	precision = 10
+	registers = 1 << precision
+)
+
+// sketch is a HyperLogLog sketch: it estimates how many distinct hashes were
+// added without keeping them.
+type sketch []byte
+
+func newSketch() sketch {
+	return make(sketch, registers)
+}
+
+// add records a 64-bit hash. The first bits pick the register, which keeps
+// the longest run of leading zeros seen in the rest.
+func (s sketch) add(h uint64) {
+	i := h >> (64 - precision)
+	rank := byte(bits.LeadingZeros64(h<<precision|1<<(precision-1)) + 1)
+	if rank > s[i] {
+		s[i] = rank
+	}
+}
+
+// merge folds other into s, as if its hashes had been added to s.
+func (s sketch) merge(other sketch) {
+	for i := range s {
+		if i < len(other) && other[i] > s[i] {
+			s[i] = other[i]
+		}
+	}
+}
+
+// estimate returns the approximate number of distinct hashes added.
+func (s sketch) estimate() int64 {
+	sum := 0.0
+	zeros := 0
+	for _, r := range s {
+		sum += math.Pow(2, -float64(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	m := float64(len(s))
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	// Few visitors leave most registers empty, where linear counting is
+	// more accurate
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(e))
+}
@@ -0,0 +1,301 @@
+// Package visits counts the visitors the ring sends to each member through
+// its next, prev and random redirects, and the impressions of the beacon
+// members can embed next to their widget. Daily unique visitors are estimated
+// with a HyperLogLog sketch of the visitor's IP hashed with a salt for the
+// day; neither IPs nor their hashes are stored, and the salt is deleted once
+// the day is over, so the sketches can't be matched against an address
+// later.
+package visits
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"webring/internal/database"
+	"webring/internal/settings"
+)
+
+// keepDays is how long the daily counts are kept.
+const keepDays = 30
+
+type dayKey struct {
+	siteID int
+	day    string
+}
+
+type tally struct {
+	hits        int64
+	impressions int64
+	sketch      sketch
+}
+
+// Counter records visits in memory; Flush adds them to the database.
+type Counter struct {
+	db *sql.DB
+	st *settings.Store
+
+	mu      sync.Mutex
+	pending map[dayKey]*tally
+	salts   map[string][]byte
+}
+
+func New(db *sql.DB, st *settings.Store) *Counter {
+	return &Counter{db: db, st: st, pending: make(map[dayKey]*tally), salts: make(map[string][]byte)}
+}
+
+// Record counts a visit to a member from the client with the given IP, when
+// the visitor counter is enabled.
+func (c *Counter) Record(siteID int, ip string) {
+	if !c.st.Bool(settings.VisitorCounter) {
+		return
+	}
+	day := time.Now().UTC().Format(time.DateOnly)
+	salt, err := c.salt(day)
+	if err != nil {
+		log.Printf("Error loading the visitor salt: %v", err)
+		return
+	}
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(ip))
+	sum := h.Sum(nil)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.tally(siteID, day)
+	t.hits++
+	t.sketch.add(binary.BigEndian.Uint64(sum[:8]))
+}
+
+// RecordImpression counts a view of a member's beacon, when the visitor
+// counter is enabled.
+func (c *Counter) RecordImpression(siteID int) {
+	if !c.st.Bool(settings.VisitorCounter) {
+		return
+	}
+	day := time.Now().UTC().Format(time.DateOnly)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tally(siteID, day).impressions++
+}
+
+// tally returns the pending counts of a site for a day. c.mu must be held.
+func (c *Counter) tally(siteID int, day string) *tally {
+	key := dayKey{siteID: siteID, day: day}
+	t, ok := c.pending[key]
+	if !ok {
+		t = &tally{sketch: newSketch()}
+		c.pending[key] = t
+	}
+	return t
+}
+
+// salt returns the salt of a day, shared by every instance through the
+// visitor_salts table.
+func (c *Counter) salt(day string) ([]byte, error) {
+	c.mu.Lock()
+	salt, ok := c.salts[day]
+	c.mu.Unlock()
+	if ok {
+		return salt, nil
+	}
+
+	salt = make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	// Another instance may have created it first
+	_, err := c.db.Exec("INSERT INTO visitor_salts (day, salt) VALUES ($1, $2) ON CONFLICT (day) DO NOTHING", day, salt)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.db.QueryRow("SELECT salt FROM visitor_salts WHERE day = $1", day).Scan(&salt); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Only today's salt is needed from now on
+	c.salts = map[string][]byte{day: salt}
+	return salt, nil
+}
+
+// Start flushes the counts every interval until the process exits.
+func (c *Counter) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		c.Flush()
+	}
+}
+
+// Flush merges the visits recorded since the last flush into the daily
+// counts, and removes old counts and the salts of past days.
+func (c *Counter) Flush() {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[dayKey]*tally)
+	c.mu.Unlock()
+
+	for key, t := range pending {
+		if err := c.save(key, t); err != nil {
+			log.Printf("Error saving visits of site %d: %v", key.siteID, err)
+		}
+	}
+
+	today := time.Now().UTC()
+	if _, err := c.db.Exec("DELETE FROM visitor_salts WHERE day < $1", today.Format(time.DateOnly)); err != nil {
+		log.Printf("Error removing old visitor salts: %v", err)
+	}
+	if _, err := c.db.Exec("DELETE FROM site_visits WHERE day < $1", today.AddDate(0, 0, -keepDays).Format(time.DateOnly)); err != nil {
+		log.Printf("Error removing old visits: %v", err)
+	}
+}
+
+func (c *Counter) save(key dayKey, t *tally) error {
+	return database.InTx(c.db, func(tx *sql.Tx) error {
+		var stored []byte
+		err := tx.QueryRow("SELECT registers FROM site_visits WHERE site_id = $1 AND day = $2 FOR UPDATE", key.siteID, key.day).Scan(&stored)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		t.sketch.merge(stored)
+
+		// Sites removed in the meantime get no row
+		_, err = tx.Exec(`
+			INSERT INTO site_visits (site_id, day, hits, impressions, registers) SELECT id, $2, $3, $4, $5 FROM sites WHERE id = $1
+			ON CONFLICT (site_id, day) DO UPDATE SET
+				hits = site_visits.hits + EXCLUDED.hits,
+				impressions = site_visits.impressions + EXCLUDED.impressions,
+				registers = EXCLUDED.registers`,
+			key.siteID, key.day, t.hits, t.impressions, []byte(t.sketch))
+		return err
+	})
+}
+
+// Visitors are the visits the ring sent to a member, and the impressions of
+// its beacon. The week covers the last seven days including today; its
+// uniques are the sum of the daily ones, so someone coming back on another
+// day is counted again.
+type Visitors struct {
+	SiteID           int
+	SiteName         string
+	Today            int64
+	TodayHits        int64
+	TodayImpressions int64
+	Week             int64
+	WeekHits         int64
+	WeekImpressions  int64
+}
+
+// List returns the visitors of every member, most visited this week first.
+// Visits not flushed yet are left out.
+func List(db *sql.DB) ([]Visitors, error) {
+	today := time.Now().UTC()
+	rows, err := db.Query(`
+		SELECT s.id, s.name, v.day, v.hits, v.impressions, v.registers
+		FROM sites s JOIN site_visits v ON v.site_id = s.id
+		WHERE v.day >= $1
+		ORDER BY s.id, v.day`, today.AddDate(0, 0, -6).Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}(rows)
+
+	var list []Visitors
+	for rows.Next() {
+		var id int
+		var name string
+		var day time.Time
+		var hits, impressions int64
+		var stored []byte
+		if err := rows.Scan(&id, &name, &day, &hits, &impressions, &stored); err != nil {
+			return nil, err
+		}
+		if len(list) == 0 || list[len(list)-1].SiteID != id {
+			list = append(list, Visitors{SiteID: id, SiteName: name})
+		}
+		v := &list[len(list)-1]
+		uniques := sketch(stored).estimate()
+		v.Week += uniques
+		v.WeekHits += hits
+		v.WeekImpressions += impressions
+		if day.Format(time.DateOnly) == today.Format(time.DateOnly) {
+			v.Today = uniques
+			v.TodayHits = hits
+			v.TodayImpressions = impressions
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Week > list[j].Week })
+	return list, nil
+}
+
+// Move adds the visits of the site from to the site to, within tx, for a
+// merge of duplicate members. Visitors counted for both on the same day are
+// counted once.
+func Move(tx *sql.Tx, from, to int) error {
+	type day struct {
+		day         time.Time
+		hits        int64
+		impressions int64
+		registers   []byte
+	}
+	rows, err := tx.Query("SELECT day, hits, impressions, registers FROM site_visits WHERE site_id = $1", from)
+	if err != nil {
+		return err
+	}
+	var days []day
+	for rows.Next() {
+		var d day
+		if err := rows.Scan(&d.day, &d.hits, &d.impressions, &d.registers); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		days = append(days, d)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, d := range days {
+		var stored []byte
+		err := tx.QueryRow("SELECT registers FROM site_visits WHERE site_id = $1 AND day = $2 FOR UPDATE", to, d.day).Scan(&stored)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		merged := newSketch()
+		merged.merge(stored)
+		merged.merge(d.registers)
+
+		_, err = tx.Exec(`
+			INSERT INTO site_visits (site_id, day, hits, impressions, registers) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (site_id, day) DO UPDATE SET
+				hits = site_visits.hits + EXCLUDED.hits,
+				impressions = site_visits.impressions + EXCLUDED.impressions,
+				registers = EXCLUDED.registers`,
+			to, d.day, d.hits, d.impressions, []byte(merged))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
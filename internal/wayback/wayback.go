@@ -0,0 +1,131 @@
+package wayback
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"webring/internal/httpclient"
+	"webring/internal/jobs"
+)
+
+const (
+	availabilityAPI = "https://archive.org/wayback/available"
+
+	defaultThreshold = 24 * time.Hour
+	// recheckInterval limits how often the archive is asked about the same site.
+	recheckInterval = 24 * time.Hour
+)
+
+type availabilityResponse struct {
+	ArchivedSnapshots struct {
+		Closest *struct {
+			Available bool   `json:"available"`
+			URL       string `json:"url"`
+			Status    string `json:"status"`
+		} `json:"closest"`
+	} `json:"archived_snapshots"`
+}
+
+// Lookup returns the URL of the latest Internet Archive snapshot of siteURL,
+// or an empty string if there is none.
+func Lookup(siteURL string) (string, error) {
+	client := httpclient.New("wayback", httpclient.Options{Timeout: 15 * time.Second})
+
+	resp, err := client.Get(availabilityAPI + "?url=" + url.QueryEscape(siteURL))
+	if err != nil {
+		return "", err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Printf("Failed to close response body: %v", err)
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("wayback availability API returned status code %d", resp.StatusCode)
+	}
+
+	var data availabilityResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", err
+	}
+
+	closest := data.ArchivedSnapshots.Closest
+	if closest == nil || !closest.Available {
+		return "", nil
+	}
+	return closest.URL, nil
+}
+
+// Updater looks up archived copies of sites that have been down for longer
+// than WAYBACK_THRESHOLD (a Go duration, 24h by default).
+type Updater struct {
+	db        *sql.DB
+	threshold time.Duration
+}
+
+func NewUpdater(db *sql.DB) *Updater {
+	threshold, err := time.ParseDuration(os.Getenv("WAYBACK_THRESHOLD"))
+	if err != nil || threshold <= 0 {
+		threshold = defaultThreshold
+	}
+	return &Updater{db: db, threshold: threshold}
+}
+
+// JobUpdate is the periodic job looking up archived copies.
+const JobUpdate = "wayback_update"
+
+// Register looks up archived copies every interval on one of the instances.
+func (u *Updater) Register(w *jobs.Worker, interval time.Duration) {
+	w.Every(JobUpdate, interval, u.Run)
+}
+
+func (u *Updater) Run() {
+	rows, err := u.db.Query(`
+        SELECT id, url FROM sites
+        WHERE is_up = false AND down_since < $1
+          AND (archive_checked_at IS NULL OR archive_checked_at < $2)
+    `, time.Now().Add(-u.threshold), time.Now().Add(-recheckInterval))
+	if err != nil {
+		log.Printf("Error fetching down sites for archive lookup: %v", err)
+		return
+	}
+
+	type site struct {
+		id  int
+		url string
+	}
+	var sites []site
+	for rows.Next() {
+		var s site
+		if err := rows.Scan(&s.id, &s.url); err != nil {
+			log.Printf("Error scanning site: %v", err)
+			continue
+		}
+		sites = append(sites, s)
+	}
+	if err := rows.Close(); err != nil {
+		log.Printf("Error closing rows: %v", err)
+	}
+
+	for _, s := range sites {
+		archiveURL, err := Lookup(s.url)
+		if err != nil {
+			log.Printf("Error looking up archived copy of %s: %v", s.url, err)
+			continue
+		}
+
+		_, err = u.db.Exec("UPDATE sites SET archive_url = NULLIF($1, ''), archive_checked_at = NOW() WHERE id = $2", archiveURL, s.id)
+		if err != nil {
+			log.Printf("Error storing archived copy of %s: %v", s.url, err)
+		}
+	}
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS blocklist;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE blocklist (
+                       id SERIAL PRIMARY KEY,
+                       kind TEXT NOT NULL CHECK (kind IN ('ip', 'cidr', 'user_agent')),
+                       value TEXT NOT NULL,
+                       reason TEXT,
+                       created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                       UNIQUE (kind, value)
+);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE sites DROP COLUMN down_since;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE sites ADD COLUMN down_since TIMESTAMP;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE sites DROP COLUMN notes;
\ No newline at end of file
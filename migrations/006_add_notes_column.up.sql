@@ -0,0 +1 @@
+ALTER TABLE sites ADD COLUMN notes TEXT NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE sites DROP COLUMN webhook_secret;
+ALTER TABLE sites DROP COLUMN webhook_url;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE sites ADD COLUMN webhook_url TEXT;
+ALTER TABLE sites ADD COLUMN webhook_secret TEXT;
\ No newline at end of file
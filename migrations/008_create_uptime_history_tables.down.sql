@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS uptime_daily;
+DROP TABLE IF EXISTS uptime_hourly;
+DROP TABLE IF EXISTS uptime_checks;
\ No newline at end of file
@@ -0,0 +1,28 @@
+CREATE TABLE uptime_checks (
+                       id BIGSERIAL PRIMARY KEY,
+                       site_id INTEGER NOT NULL REFERENCES sites (id) ON DELETE CASCADE,
+                       checked_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                       is_up BOOLEAN NOT NULL,
+                       response_time FLOAT NOT NULL
+);
+
+CREATE INDEX uptime_checks_site_id_checked_at_idx ON uptime_checks (site_id, checked_at);
+CREATE INDEX uptime_checks_checked_at_idx ON uptime_checks (checked_at);
+
+CREATE TABLE uptime_hourly (
+                       site_id INTEGER NOT NULL REFERENCES sites (id) ON DELETE CASCADE,
+                       hour TIMESTAMP NOT NULL,
+                       checks INTEGER NOT NULL,
+                       up_checks INTEGER NOT NULL,
+                       avg_response_time FLOAT NOT NULL,
+                       PRIMARY KEY (site_id, hour)
+);
+
+CREATE TABLE uptime_daily (
+                       site_id INTEGER NOT NULL REFERENCES sites (id) ON DELETE CASCADE,
+                       day DATE NOT NULL,
+                       checks INTEGER NOT NULL,
+                       up_checks INTEGER NOT NULL,
+                       avg_response_time FLOAT NOT NULL,
+                       PRIMARY KEY (site_id, day)
+);
\ No newline at end of file
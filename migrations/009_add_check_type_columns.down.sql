@@ -0,0 +1,2 @@
+ALTER TABLE sites DROP COLUMN check_target;
+ALTER TABLE sites DROP COLUMN check_type;
\ No newline at end of file
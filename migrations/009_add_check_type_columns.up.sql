@@ -0,0 +1,2 @@
+ALTER TABLE sites ADD COLUMN check_type TEXT NOT NULL DEFAULT 'http' CHECK (check_type IN ('http', 'tcp', 'ping', 'keyword'));
+ALTER TABLE sites ADD COLUMN check_target TEXT NOT NULL DEFAULT '';
\ No newline at end of file
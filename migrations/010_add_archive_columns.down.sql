@@ -0,0 +1,2 @@
+ALTER TABLE sites DROP COLUMN archive_checked_at;
+ALTER TABLE sites DROP COLUMN archive_url;
\ No newline at end of file
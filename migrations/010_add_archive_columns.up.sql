@@ -0,0 +1,2 @@
+ALTER TABLE sites ADD COLUMN archive_url TEXT;
+ALTER TABLE sites ADD COLUMN archive_checked_at TIMESTAMP;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE sites DROP COLUMN created_at;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- Existing members keep NULL: their join date is unknown
+ALTER TABLE sites ADD COLUMN created_at TIMESTAMP;
+ALTER TABLE sites ALTER COLUMN created_at SET DEFAULT NOW();
\ No newline at end of file
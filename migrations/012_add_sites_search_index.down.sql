@@ -0,0 +1 @@
+DROP INDEX sites_search_idx;
\ No newline at end of file
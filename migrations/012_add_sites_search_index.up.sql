@@ -0,0 +1 @@
+CREATE INDEX sites_search_idx ON sites USING GIN (to_tsvector('simple', name || ' ' || regexp_replace(url, '[^[:alnum:]]+', ' ', 'g')));
\ No newline at end of file
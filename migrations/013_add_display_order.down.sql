@@ -0,0 +1 @@
+ALTER TABLE sites DROP COLUMN display_order;
\ No newline at end of file
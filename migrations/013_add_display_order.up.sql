@@ -0,0 +1 @@
+ALTER TABLE sites ADD COLUMN display_order INTEGER;
\ No newline at end of file
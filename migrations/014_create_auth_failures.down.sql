@@ -0,0 +1 @@
+DROP TABLE auth_failures;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE auth_failures (
+                       id BIGSERIAL PRIMARY KEY,
+                       ip TEXT NOT NULL,
+                       username TEXT NOT NULL,
+                       user_agent TEXT NOT NULL,
+                       created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX auth_failures_created_at_idx ON auth_failures (created_at);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE sites DROP COLUMN support_url;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE sites ADD COLUMN support_url TEXT;
\ No newline at end of file
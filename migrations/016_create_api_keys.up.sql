@@ -0,0 +1,9 @@
+CREATE TABLE api_keys (
+                       id SERIAL PRIMARY KEY,
+                       name TEXT NOT NULL,
+                       key_hash TEXT NOT NULL UNIQUE,
+                       prefix TEXT NOT NULL,
+                       requests BIGINT NOT NULL DEFAULT 0,
+                       last_used_at TIMESTAMP,
+                       created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE reports;
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE reports (
+                       id SERIAL PRIMARY KEY,
+                       site_id INTEGER NOT NULL REFERENCES sites (id) ON DELETE CASCADE,
+                       reason TEXT NOT NULL,
+                       contact TEXT NOT NULL DEFAULT '',
+                       ip TEXT NOT NULL,
+                       created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                       resolved_at TIMESTAMP
+);
+
+CREATE INDEX reports_site_id_idx ON reports (site_id);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE uptime_checks_staging;
\ No newline at end of file
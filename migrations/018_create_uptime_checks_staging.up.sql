@@ -0,0 +1,11 @@
+CREATE TABLE uptime_checks_staging (
+                       id BIGSERIAL PRIMARY KEY,
+                       site_id INTEGER NOT NULL REFERENCES sites (id) ON DELETE CASCADE,
+                       checked_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                       is_up BOOLEAN NOT NULL,
+                       response_time FLOAT NOT NULL,
+                       error TEXT NOT NULL DEFAULT '',
+                       status_change BOOLEAN NOT NULL
+);
+
+CREATE INDEX uptime_checks_staging_checked_at_idx ON uptime_checks_staging (checked_at);
\ No newline at end of file
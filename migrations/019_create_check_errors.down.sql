@@ -0,0 +1 @@
+DROP TABLE check_errors;
\ No newline at end of file
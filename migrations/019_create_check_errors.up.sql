@@ -0,0 +1,10 @@
+CREATE TABLE check_errors (
+                       id BIGSERIAL PRIMARY KEY,
+                       site_id INTEGER NOT NULL REFERENCES sites (id) ON DELETE CASCADE,
+                       category VARCHAR(32) NOT NULL,
+                       message TEXT NOT NULL,
+                       created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX check_errors_site_created_idx ON check_errors (site_id, created_at);
+CREATE INDEX check_errors_created_idx ON check_errors (created_at);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE sites DROP COLUMN https_issue_since;
+ALTER TABLE sites DROP COLUMN https_issue;
\ No newline at end of file
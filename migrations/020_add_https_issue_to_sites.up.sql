@@ -0,0 +1,2 @@
+ALTER TABLE sites ADD COLUMN https_issue TEXT;
+ALTER TABLE sites ADD COLUMN https_issue_since TIMESTAMP;
\ No newline at end of file
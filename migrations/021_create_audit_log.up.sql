@@ -0,0 +1,11 @@
+CREATE TABLE audit_log (
+                       id SERIAL PRIMARY KEY,
+                       site_id INTEGER,
+                       site_name VARCHAR(255) NOT NULL DEFAULT '',
+                       action VARCHAR(32) NOT NULL,
+                       old_value TEXT NOT NULL DEFAULT '',
+                       new_value TEXT NOT NULL DEFAULT '',
+                       created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE sites DROP COLUMN maintenance_keep_in_ring;
+ALTER TABLE sites DROP COLUMN maintenance_end;
+ALTER TABLE sites DROP COLUMN maintenance_start;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE sites ADD COLUMN maintenance_start TIMESTAMP;
+ALTER TABLE sites ADD COLUMN maintenance_end TIMESTAMP;
+ALTER TABLE sites ADD COLUMN maintenance_keep_in_ring BOOLEAN NOT NULL DEFAULT false;
\ No newline at end of file
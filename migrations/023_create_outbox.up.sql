@@ -0,0 +1,13 @@
+CREATE TABLE outbox (
+                       id BIGSERIAL PRIMARY KEY,
+                       event_type VARCHAR(64) NOT NULL,
+                       payload JSONB NOT NULL,
+                       attempts INTEGER NOT NULL DEFAULT 0,
+                       last_error TEXT,
+                       next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                       delivered_at TIMESTAMP,
+                       failed_at TIMESTAMP,
+                       created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (next_attempt_at) WHERE delivered_at IS NULL AND failed_at IS NULL;
\ No newline at end of file
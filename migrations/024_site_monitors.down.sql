@@ -0,0 +1,2 @@
+ALTER TABLE sites DROP COLUMN monitor_kind;
+ALTER TABLE sites DROP COLUMN monitor_url;
\ No newline at end of file
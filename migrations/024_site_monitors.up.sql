@@ -0,0 +1,2 @@
+ALTER TABLE sites ADD COLUMN monitor_url TEXT;
+ALTER TABLE sites ADD COLUMN monitor_kind TEXT NOT NULL DEFAULT 'webhook';
\ No newline at end of file
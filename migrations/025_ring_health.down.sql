@@ -0,0 +1 @@
+DROP TABLE ring_health;
\ No newline at end of file
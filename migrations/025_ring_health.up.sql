@@ -0,0 +1,8 @@
+CREATE TABLE ring_health (
+                       id BIGSERIAL PRIMARY KEY,
+                       checked_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                       members INTEGER NOT NULL,
+                       up INTEGER NOT NULL
+);
+
+CREATE INDEX ring_health_checked_at_idx ON ring_health (checked_at);
\ No newline at end of file
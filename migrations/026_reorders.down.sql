@@ -0,0 +1 @@
+DROP TABLE reorders;
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE reorders (
+                       id SERIAL PRIMARY KEY,
+                       previous JSONB NOT NULL,
+                       source VARCHAR(32) NOT NULL,
+                       created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                       undone_at TIMESTAMP
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE notification_dedupe;
\ No newline at end of file
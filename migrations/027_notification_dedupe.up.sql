@@ -0,0 +1,7 @@
+CREATE TABLE notification_dedupe (
+                       site_id INTEGER NOT NULL REFERENCES sites (id) ON DELETE CASCADE,
+                       event_type VARCHAR(32) NOT NULL,
+                       window_start TIMESTAMP NOT NULL DEFAULT NOW(),
+                       held INTEGER NOT NULL DEFAULT 0,
+                       PRIMARY KEY (site_id, event_type)
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TRIGGER sites_changed ON sites;
+DROP FUNCTION notify_sites_changed();
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE FUNCTION notify_sites_changed() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('sites_changed', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER sites_changed
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON sites
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_sites_changed();
\ No newline at end of file
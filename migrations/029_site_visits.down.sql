@@ -0,0 +1,2 @@
+DROP TABLE visitor_salts;
+DROP TABLE site_visits;
\ No newline at end of file
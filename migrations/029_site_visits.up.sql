@@ -0,0 +1,12 @@
+CREATE TABLE site_visits (
+                       site_id INTEGER NOT NULL REFERENCES sites (id) ON DELETE CASCADE,
+                       day DATE NOT NULL,
+                       hits BIGINT NOT NULL DEFAULT 0,
+                       registers BYTEA NOT NULL,
+                       PRIMARY KEY (site_id, day)
+);
+
+CREATE TABLE visitor_salts (
+                       day DATE PRIMARY KEY,
+                       salt BYTEA NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE guestbook_entries;
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE guestbook_entries (
+                       id SERIAL PRIMARY KEY,
+                       name TEXT NOT NULL,
+                       website TEXT NOT NULL DEFAULT '',
+                       message TEXT NOT NULL,
+                       ip TEXT NOT NULL,
+                       created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                       approved_at TIMESTAMP
+);
+
+CREATE INDEX guestbook_entries_approved_at_idx ON guestbook_entries (approved_at);
\ No newline at end of file
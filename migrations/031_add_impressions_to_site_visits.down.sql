@@ -0,0 +1 @@
+ALTER TABLE site_visits DROP COLUMN impressions;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE site_visits ADD COLUMN impressions BIGINT NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TABLE jobs;
+
+ALTER TABLE sites DROP COLUMN hiatus_until;
\ No newline at end of file
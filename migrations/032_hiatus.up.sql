@@ -0,0 +1,11 @@
+ALTER TABLE sites ADD COLUMN hiatus_until TIMESTAMP;
+
+CREATE TABLE jobs (
+                       id BIGSERIAL PRIMARY KEY,
+                       kind VARCHAR(64) NOT NULL,
+                       site_id INTEGER REFERENCES sites (id) ON DELETE CASCADE,
+                       run_at TIMESTAMP NOT NULL,
+                       created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX jobs_run_at_idx ON jobs (run_at);
\ No newline at end of file
@@ -0,0 +1,11 @@
+DROP INDEX jobs_periodic_kind_idx;
+
+DELETE FROM jobs WHERE interval_seconds IS NOT NULL;
+
+ALTER TABLE jobs DROP COLUMN locked_until;
+ALTER TABLE jobs DROP COLUMN locked_by;
+ALTER TABLE jobs DROP COLUMN failed_at;
+ALTER TABLE jobs DROP COLUMN last_error;
+ALTER TABLE jobs DROP COLUMN attempts;
+ALTER TABLE jobs DROP COLUMN interval_seconds;
+ALTER TABLE jobs DROP COLUMN payload;
\ No newline at end of file
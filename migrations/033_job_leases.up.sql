@@ -0,0 +1,9 @@
+ALTER TABLE jobs ADD COLUMN payload JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE jobs ADD COLUMN interval_seconds INTEGER;
+ALTER TABLE jobs ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN last_error TEXT;
+ALTER TABLE jobs ADD COLUMN failed_at TIMESTAMP;
+ALTER TABLE jobs ADD COLUMN locked_by TEXT;
+ALTER TABLE jobs ADD COLUMN locked_until TIMESTAMP;
+
+CREATE UNIQUE INDEX jobs_periodic_kind_idx ON jobs (kind) WHERE interval_seconds IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE sites DROP COLUMN feed_url;
+ALTER TABLE sites DROP COLUMN description;
\ No newline at end of file
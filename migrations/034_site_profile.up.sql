@@ -0,0 +1,2 @@
+ALTER TABLE sites ADD COLUMN description TEXT;
+ALTER TABLE sites ADD COLUMN feed_url TEXT;
\ No newline at end of file
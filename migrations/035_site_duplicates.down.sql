@@ -0,0 +1 @@
+DROP TABLE site_duplicates;
\ No newline at end of file
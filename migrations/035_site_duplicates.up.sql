@@ -0,0 +1,9 @@
+CREATE TABLE site_duplicates (
+                       site_id INTEGER NOT NULL REFERENCES sites (id) ON DELETE CASCADE,
+                       other_id INTEGER NOT NULL REFERENCES sites (id) ON DELETE CASCADE,
+                       host TEXT NOT NULL,
+                       found_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                       dismissed BOOLEAN NOT NULL DEFAULT FALSE,
+                       PRIMARY KEY (site_id, other_id),
+                       CHECK (site_id < other_id)
+);
\ No newline at end of file
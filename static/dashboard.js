@@ -0,0 +1,80 @@
+// Submits forms marked with data-async in the background and updates the page
+// in place. Without JavaScript the forms fall back to the regular
+// POST-and-redirect flow.
+//
+// data-async="remove"  removes the element data-target on success
+// data-async="replace" replaces data-target with the HTML fragment returned
+// data-confirm         asks for confirmation first, on any form
+//
+// Buttons with a formtarget, such as the previews, submit normally.
+document.addEventListener('submit', async (event) => {
+    const form = event.target;
+    if (form.dataset.confirm && !confirm(form.dataset.confirm)) {
+        event.preventDefault();
+        return;
+    }
+
+    const mode = form.dataset.async;
+    if (!mode || event.submitter?.hasAttribute('formtarget')) {
+        return;
+    }
+    event.preventDefault();
+
+    const target = document.getElementById(form.dataset.target);
+    target?.classList.add('pending');
+
+    try {
+        const response = await fetch(form.action, {
+            method: 'POST',
+            // FormData picks up inputs attached with the form attribute as well
+            body: new URLSearchParams(new FormData(form)),
+            headers: {'X-Requested-With': 'fetch'},
+        });
+        if (!response.ok) {
+            alert(await response.text());
+            return;
+        }
+
+        if (mode === 'remove') {
+            target?.remove();
+        } else if (mode === 'replace' && target) {
+            target.outerHTML = await response.text();
+        }
+    } catch (err) {
+        alert('Request failed: ' + err.message);
+    } finally {
+        target?.classList.remove('pending');
+    }
+});
+
+// Renders a preview of textareas marked with data-preview (the id of the
+// preview element) while they are edited.
+const renderPreview = async (textarea) => {
+    const preview = document.getElementById(textarea.dataset.preview);
+    if (!preview) {
+        return;
+    }
+    try {
+        const response = await fetch('/dashboard/settings/preview', {
+            method: 'POST',
+            body: new URLSearchParams({markdown: textarea.value}),
+        });
+        if (response.ok) {
+            preview.innerHTML = await response.text();
+        }
+    } catch (err) {
+        // Keep the previous preview
+    }
+};
+
+const previewTimers = new WeakMap();
+document.addEventListener('input', (event) => {
+    const textarea = event.target;
+    if (!textarea.dataset?.preview) {
+        return;
+    }
+    clearTimeout(previewTimers.get(textarea));
+    previewTimers.set(textarea, setTimeout(() => renderPreview(textarea), 300));
+});
+
+document.querySelectorAll('textarea[data-preview]').forEach(renderPreview);
@@ -0,0 +1,107 @@
+// Keyboard shortcuts for the dashboard, backed by the quick action endpoints
+// of the admin API. They are ignored while typing in a form field.
+//
+// /    jump to a site by ID or name
+// j k  select the next or previous site row
+// m    toggle the maintenance window of the selected site
+// a    approve the newest guestbook entry waiting for approval
+// ?    list the shortcuts
+const shortcutsHelp = [
+    '/  jump to a site by ID or name',
+    'j  select the next site',
+    'k  select the previous site',
+    'm  toggle maintenance of the selected site',
+    'a  approve the newest guestbook entry',
+    '?  show this help',
+].join('\n');
+
+const quickAction = async (method, path) => {
+    const response = await fetch('/api/v1/admin/quick' + path, {method});
+    const body = await response.json().catch(() => ({}));
+    if (!response.ok) {
+        throw new Error(body.error || response.statusText);
+    }
+    return body;
+};
+
+const siteRows = () => [...document.querySelectorAll('tr[id^="site-"]')];
+
+// The selected row is the one in the URL fragment, as after a jump
+const selectedRow = () => {
+    const row = location.hash && document.getElementById(location.hash.slice(1));
+    return row?.matches('tr[id^="site-"]') ? row : null;
+};
+
+const selectRow = (row) => {
+    history.replaceState(null, '', '#' + row.id);
+    document.querySelectorAll('tr.selected').forEach((r) => r.classList.remove('selected'));
+    row.classList.add('selected');
+    row.scrollIntoView({block: 'nearest'});
+};
+
+const shortcuts = {
+    '/': async () => {
+        const q = prompt('Jump to site (ID or name)');
+        if (!q) {
+            return;
+        }
+        const site = await quickAction('GET', '/jump?q=' + encodeURIComponent(q));
+        const row = document.getElementById('site-' + site.id);
+        if (row) {
+            selectRow(row);
+        } else {
+            location.href = site.dashboard_url;
+        }
+    },
+    'j': () => moveSelection(1),
+    'k': () => moveSelection(-1),
+    'm': async () => {
+        const row = selectedRow();
+        if (!row) {
+            return;
+        }
+        const site = await quickAction('POST', '/sites/' + row.id.slice('site-'.length) + '/maintenance');
+        alert(site.in_maintenance ? site.name + ' is in maintenance' : 'Maintenance of ' + site.name + ' ended');
+        // Reload to show the new window in the row
+        location.reload();
+    },
+    'a': async () => {
+        const entry = await quickAction('POST', '/approve');
+        alert('Approved the guestbook entry of ' + entry.name);
+        if (location.pathname === '/dashboard/guestbook') {
+            location.reload();
+        }
+    },
+    '?': () => alert(shortcutsHelp),
+};
+
+const moveSelection = (step) => {
+    const rows = siteRows();
+    if (rows.length === 0) {
+        return;
+    }
+    const i = rows.indexOf(selectedRow());
+    const next = i < 0 ? (step > 0 ? 0 : rows.length - 1) : Math.min(Math.max(i + step, 0), rows.length - 1);
+    selectRow(rows[next]);
+};
+
+document.addEventListener('keydown', async (event) => {
+    if (event.ctrlKey || event.metaKey || event.altKey || event.target.closest('input, textarea, select, [contenteditable]')) {
+        return;
+    }
+    const action = shortcuts[event.key];
+    if (!action) {
+        return;
+    }
+    event.preventDefault();
+    try {
+        await action();
+    } catch (err) {
+        alert(err.message);
+    }
+});
+
+const initial = selectedRow();
+if (initial) {
+    selectRow(initial);
+}
@@ -0,0 +1,117 @@
+// Webring navigation for member sites, served at /widget.js:
+//
+//   <script src="https://ring.example/widget.js?slug=12&theme=dark" async></script>
+//
+// slug is the member's ID as used in /{id}/data. theme is light, dark or auto
+// (the default), which follows the visitor's color scheme. Both can also be
+// given as data-slug and data-theme attributes on the script tag. The links
+// are rendered in place of the script, in a shadow root so the member's
+// styles and the widget's don't mix.
+//
+// When the ring requires navigation tokens, the server passes the member's
+// token as the argument of this function, for pages on the member's host.
+((issued) => {
+    const script = document.currentScript;
+    if (!script) {
+        return;
+    }
+    const src = new URL(script.src);
+    const base = src.origin + src.pathname.replace(/\/widget\.js$/, '');
+    const slug = src.searchParams.get('slug') || script.dataset.slug;
+    const theme = src.searchParams.get('theme') || script.dataset.theme || 'auto';
+    if (!slug) {
+        console.warn('webring widget: the slug parameter is missing');
+        return;
+    }
+
+    const host = document.createElement('span');
+    script.replaceWith(host);
+    const root = host.attachShadow({mode: 'open'});
+
+    const style = document.createElement('style');
+    style.textContent = `
+        nav {
+            --bg: #ffffff;
+            --fg: #18181b;
+            --muted: #52525b;
+            --accent: #1e3a8a;
+            display: inline-flex;
+            flex-wrap: wrap;
+            align-items: center;
+            gap: .75rem;
+            padding: .5rem .875rem;
+            border: 1px solid color-mix(in srgb, var(--fg) 15%, transparent);
+            border-radius: 6px;
+            background: var(--bg);
+            color: var(--fg);
+            font: 14px/1.4 Inter, system-ui, sans-serif;
+        }
+        nav.dark {
+            --bg: #09090b;
+            --fg: #f4f4f5;
+            --muted: #a1a1aa;
+            --accent: #dbeafe;
+        }
+        @media (prefers-color-scheme: dark) {
+            nav.auto {
+                --bg: #09090b;
+                --fg: #f4f4f5;
+                --muted: #a1a1aa;
+                --accent: #dbeafe;
+            }
+        }
+        a {
+            color: var(--accent);
+            text-decoration: none;
+        }
+        a:hover {
+            text-decoration: underline;
+        }
+        small {
+            color: var(--muted);
+        }
+    `;
+    root.append(style);
+
+    const nav = document.createElement('nav');
+    nav.className = ['light', 'dark'].includes(theme) ? theme : 'auto';
+    nav.setAttribute('aria-label', 'Webring');
+    root.append(nav);
+
+    const link = (href, text, rel) => {
+        const a = document.createElement('a');
+        a.href = href;
+        a.textContent = text;
+        if (rel) {
+            a.rel = rel;
+        }
+        return a;
+    };
+
+    const id = encodeURIComponent(slug);
+    const query = issued ? `?token=${encodeURIComponent(issued)}` : '';
+    fetch(`${base}/${id}/data${query}`)
+        .then((response) => {
+            if (!response.ok) {
+                throw new Error(response.statusText);
+            }
+            return response.json();
+        })
+        .then((data) => {
+            const current = data.token || issued;
+            const token = current ? `?token=${encodeURIComponent(current)}` : '';
+            const position = document.createElement('small');
+            position.textContent = `${data.position} of ${data.total}`;
+            nav.append(
+                link(`${base}/${id}/prev${token}`, `← ${data.prev.name}`, 'prev'),
+                link(`${base}/`, 'Webring'),
+                link(`${base}/${id}/random${token}`, 'Random'),
+                link(`${base}/${id}/next${token}`, `${data.next.name} →`, 'next'),
+                position,
+            );
+        })
+        .catch((err) => {
+            console.warn('webring widget:', err);
+            nav.append(link(`${base}/`, 'Webring'));
+        });
+})(/* token */ null);